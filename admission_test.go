@@ -0,0 +1,514 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/mytest-apiserver/pkg/quota"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/utils/ptr"
+)
+
+// newAdmissionTestServer returns a server running the admission chain of newAdmissionOptions
+// against a fake core API server holding objects
+func newAdmissionTestServer(t *testing.T, objects ...runtime.Object) *httptest.Server {
+	t.Helper()
+	return newAdmissionTestServerWithOptions(t, newAdmissionOptions(), objects...)
+}
+
+// newAdmissionTestServerWithOptions returns a server running the admission chain of options
+// against a fake core API server holding objects
+func newAdmissionTestServerWithOptions(t *testing.T, options *genericoptions.AdmissionOptions,
+	objects ...runtime.Object) *httptest.Server {
+	t.Helper()
+	client := fake.NewClientset(objects...)
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+
+	config := NewConfig()
+	// Admission reviews name the user making the request
+	config.GenericConfig.Authentication.Authenticator = authenticator.RequestFunc(
+		func(*http.Request) (*authenticator.Response, bool, error) {
+			return &authenticator.Response{User: &user.DefaultInfo{Name: "test"}}, true, nil
+		})
+	config.GenericConfig.Authorization.Authorizer = authorizerfactory.NewAlwaysAllowAuthorizer()
+	config.GenericConfig.LoopbackClientConfig = &restclient.Config{}
+	config.GenericConfig.ExternalAddress = "127.0.0.1:443"
+	config.GenericConfig.SharedInformerFactory = informerFactory
+	initializers, err := config.admissionInitializers(config.GenericConfig)
+	if err != nil {
+		t.Fatalf("Failed to create admission initializers: %v", err)
+	}
+	if err := options.ApplyTo(&config.GenericConfig.Config, informerFactory, client,
+		dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), utilfeature.DefaultFeatureGate, initializers...); err != nil {
+		t.Fatalf("Failed to configure admission: %v", err)
+	}
+	server, err := config.Complete().New()
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	// The server starts the informers once it runs, which the test server never does
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+
+	ts := httptest.NewServer(server.GenericAPIServer.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func namespace(name string, phase corev1.NamespacePhase) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NamespaceStatus{Phase: phase},
+	}
+}
+
+func TestAdmission_NamespaceLifecycle(t *testing.T) {
+	ts := newAdmissionTestServer(t, namespace("default", corev1.NamespaceActive),
+		namespace("terminating", corev1.NamespaceTerminating))
+
+	for _, tc := range []struct {
+		namespace string
+		want      int
+	}{
+		{"default", http.StatusCreated},
+		{"terminating", http.StatusForbidden},
+		{"missing", http.StatusNotFound},
+	} {
+		code, obj := doRequest(t, ts, http.MethodPost, "/apis/things.myorg.io/v1alpha1/namespaces/"+tc.namespace+"/widgets",
+			"application/json", `{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"w"}}`)
+		if code != tc.want {
+			t.Errorf("Expected %d creating a widget in namespace %s, got %d: %v", tc.want, tc.namespace, code, obj)
+		}
+	}
+}
+
+// webhookServer serves a mutating webhook that annotates widgets and a validating webhook that
+// rejects widgets labelled deny=true, recording every request the validating webhook reviews
+type webhookServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	reviews []string
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	t.Helper()
+	s := &webhookServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mutate", func(w http.ResponseWriter, r *http.Request) {
+		s.serve(t, w, r, func(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) {
+			response.Patch = []byte(`[{"op":"add","path":"/metadata/annotations","value":{"mutated":"true"}}]`)
+			response.PatchType = ptr.To(admissionv1.PatchTypeJSONPatch)
+		})
+	})
+	mux.HandleFunc("/validate", func(w http.ResponseWriter, r *http.Request) {
+		s.serve(t, w, r, func(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) {
+			object := request.Object.Raw
+			if request.Operation == admissionv1.Delete {
+				object = request.OldObject.Raw
+			}
+			var widget metav1.PartialObjectMetadata
+			if err := json.Unmarshal(object, &widget); err != nil {
+				t.Errorf("Failed to decode reviewed widget: %v", err)
+			}
+
+			// Deleting a collection reviews each widget without naming it in the request
+			review := string(request.Operation) + " " + widget.Name
+			if request.SubResource != "" {
+				review += "/" + request.SubResource
+			}
+			s.mu.Lock()
+			s.reviews = append(s.reviews, review)
+			s.mu.Unlock()
+
+			if widget.Labels["deny"] == "true" {
+				response.Allowed = false
+				response.Result = &metav1.Status{Message: "widget is labelled deny"}
+			}
+		})
+	})
+	s.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) serve(t *testing.T, w http.ResponseWriter, r *http.Request,
+	review func(*admissionv1.AdmissionRequest, *admissionv1.AdmissionResponse)) {
+	var in admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Request == nil {
+		t.Errorf("Failed to decode admission review: %v", err)
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+	response := &admissionv1.AdmissionResponse{UID: in.Request.UID, Allowed: true}
+	review(in.Request, response)
+	out := admissionv1.AdmissionReview{TypeMeta: in.TypeMeta, Response: response}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		t.Errorf("Failed to encode admission review: %v", err)
+	}
+}
+
+// recorded returns the requests the validating webhook reviewed
+func (s *webhookServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.reviews...)
+}
+
+// clientConfig returns the client configuration for calling path on the server
+func (s *webhookServer) clientConfig(path string) admissionregistrationv1.WebhookClientConfig {
+	return admissionregistrationv1.WebhookClientConfig{
+		URL:      ptr.To(s.URL + path),
+		CABundle: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}),
+	}
+}
+
+func widgetRule(operations []admissionregistrationv1.OperationType, resources ...string) admissionregistrationv1.RuleWithOperations {
+	return admissionregistrationv1.RuleWithOperations{
+		Operations: operations,
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{"things.myorg.io"},
+			APIVersions: []string{"*"},
+			Resources:   resources,
+		},
+	}
+}
+
+func TestAdmission_Webhooks(t *testing.T) {
+	webhooks := newWebhookServer(t)
+	sideEffects := ptr.To(admissionregistrationv1.SideEffectClassNone)
+	ts := newAdmissionTestServer(t, namespace("default", corev1.NamespaceActive),
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "widgets"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{{
+				Name:         "mutate.things.myorg.io",
+				ClientConfig: webhooks.clientConfig("/mutate"),
+				Rules: []admissionregistrationv1.RuleWithOperations{widgetRule([]admissionregistrationv1.OperationType{
+					admissionregistrationv1.Create, admissionregistrationv1.Update}, "widgets")},
+				MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+				NamespaceSelector:       &metav1.LabelSelector{},
+				ObjectSelector:          &metav1.LabelSelector{},
+				SideEffects:             sideEffects,
+				AdmissionReviewVersions: []string{"v1"},
+			}},
+		},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "widgets"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+				Name:         "validate.things.myorg.io",
+				ClientConfig: webhooks.clientConfig("/validate"),
+				Rules: []admissionregistrationv1.RuleWithOperations{widgetRule([]admissionregistrationv1.OperationType{
+					admissionregistrationv1.OperationAll}, "widgets", "widgets/status")},
+				MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+				NamespaceSelector:       &metav1.LabelSelector{},
+				ObjectSelector:          &metav1.LabelSelector{},
+				SideEffects:             sideEffects,
+				AdmissionReviewVersions: []string{"v1"},
+			}},
+		})
+
+	// Every write goes through the webhooks, whichever verb and version it uses. The writes
+	// before the apply set the size it applies, as a defaulted size would belong to their
+	// field manager and conflict with it.
+	for _, step := range []struct {
+		method, path, contentType, body string
+		want                            int
+	}{
+		{http.MethodPost, widgetsPath, "application/json",
+			`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"w","size":3}}`, http.StatusCreated},
+		{http.MethodPut, widgetsPath + "/w", "application/json",
+			`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w","resourceVersion":"$RV"},"spec":{"name":"w","size":3,"description":"put"}}`, http.StatusOK},
+		{http.MethodPatch, v1beta1WidgetsPath + "/w", "application/merge-patch+json",
+			`{"spec":{"description":"patched"}}`, http.StatusOK},
+		{http.MethodPatch, widgetsPath + "/w?fieldManager=test", "application/apply-patch+yaml",
+			`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"w","size":3}}`, http.StatusOK},
+		{http.MethodPatch, widgetsPath + "/w/status", "application/merge-patch+json",
+			`{"status":{"phase":"Ready"}}`, http.StatusOK},
+		{http.MethodPost, widgetsPath, "application/json",
+			`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"denied","labels":{"deny":"true"}},"spec":{"name":"denied"}}`, http.StatusBadRequest},
+		{http.MethodPatch, widgetsPath + "/w", "application/merge-patch+json",
+			`{"metadata":{"labels":{"deny":"true"}}}`, http.StatusBadRequest},
+		{http.MethodDelete, widgetsPath + "/w", "", "", http.StatusOK},
+		{http.MethodPost, widgetsPath, "application/json",
+			`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w2"},"spec":{"name":"w2"}}`, http.StatusCreated},
+		{http.MethodDelete, widgetsPath, "", "", http.StatusOK},
+	} {
+		body := step.body
+		if strings.Contains(body, "$RV") {
+			_, current := doRequest(t, ts, http.MethodGet, step.path, "", "")
+			body = strings.ReplaceAll(body, "$RV", fmt.Sprint(current["metadata"].(map[string]interface{})["resourceVersion"]))
+		}
+		code, obj := doRequest(t, ts, step.method, step.path, step.contentType, body)
+		if code != step.want {
+			t.Fatalf("Expected %d for %s %s, got %d: %v", step.want, step.method, step.path, code, obj)
+		}
+		if step.method == http.MethodPost && code == http.StatusCreated {
+			annotations, _ := obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+			if annotations["mutated"] != "true" {
+				t.Errorf("Expected the mutating webhook to annotate the widget, got %v", obj)
+			}
+		}
+		if code == http.StatusBadRequest && !strings.Contains(fmt.Sprint(obj["message"]), "widget is labelled deny") {
+			t.Errorf("Expected the validating webhook to deny the request, got %v", obj)
+		}
+	}
+
+	want := []string{"CREATE w", "UPDATE w", "UPDATE w", "UPDATE w", "UPDATE w/status", "CREATE denied", "UPDATE w",
+		"DELETE w", "CREATE w2", "DELETE w2"}
+	if got := webhooks.recorded(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the validating webhook to review %v, got %v", want, got)
+	}
+}
+
+func TestAdmission_ValidatingAdmissionPolicy(t *testing.T) {
+	ts := newAdmissionTestServer(t, namespace("default", corev1.NamespaceActive),
+		&admissionregistrationv1.ValidatingAdmissionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "widget-names"},
+			Spec: admissionregistrationv1.ValidatingAdmissionPolicySpec{
+				FailurePolicy: ptr.To(admissionregistrationv1.Fail),
+				MatchConstraints: &admissionregistrationv1.MatchResources{
+					NamespaceSelector: &metav1.LabelSelector{},
+					ObjectSelector:    &metav1.LabelSelector{},
+					MatchPolicy:       ptr.To(admissionregistrationv1.Equivalent),
+					ResourceRules: []admissionregistrationv1.NamedRuleWithOperations{{
+						RuleWithOperations: widgetRule([]admissionregistrationv1.OperationType{
+							admissionregistrationv1.Create, admissionregistrationv1.Update}, "widgets"),
+					}},
+				},
+				Validations: []admissionregistrationv1.Validation{{
+					Expression: "object.spec.name != 'forbidden'",
+					Message:    "widgets must not be called forbidden",
+				}},
+			},
+		},
+		&admissionregistrationv1.ValidatingAdmissionPolicyBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "widget-names"},
+			Spec: admissionregistrationv1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName:        "widget-names",
+				ValidationActions: []admissionregistrationv1.ValidationAction{admissionregistrationv1.Deny},
+			},
+		})
+
+	// Policies are compiled in the background once they are listed
+	forbidden := `{"apiVersion":"things.myorg.io/v1beta1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"forbidden"}}`
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		code, obj := doRequest(t, ts, http.MethodPost, v1beta1WidgetsPath+"?dryRun=All", "application/json", forbidden)
+		if code == http.StatusUnprocessableEntity && strings.Contains(fmt.Sprint(obj["message"]), "widgets must not be called forbidden") {
+			break
+		}
+		if code != http.StatusCreated || time.Now().After(deadline) {
+			t.Fatalf("Expected the policy to reject the widget, got %d: %v", code, obj)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	code, obj := doRequest(t, ts, http.MethodPost, widgetsPath, "application/json",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"allowed"}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected an allowed widget to be created, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, widgetsPath+"/w", "application/merge-patch+json", `{"spec":{"name":"forbidden"}}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected the policy to reject the update, got %d: %v", code, obj)
+	}
+}
+
+// pluginAdmissionOptions returns the admission options of the server with plugins enabled and
+// configured by config, an AdmissionConfiguration file
+func pluginAdmissionOptions(t *testing.T, config string, plugins ...string) *genericoptions.AdmissionOptions {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "admission.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("Failed to write admission configuration: %v", err)
+	}
+	options := newAdmissionOptions()
+	options.EnablePlugins = plugins
+	options.ConfigFile = path
+	return options
+}
+
+func TestAdmission_WidgetSizeLimit(t *testing.T) {
+	ts := newAdmissionTestServerWithOptions(t, pluginAdmissionOptions(t, `
+apiVersion: apiserver.config.k8s.io/v1
+kind: AdmissionConfiguration
+plugins:
+- name: WidgetSizeLimit
+  configuration:
+    apiVersion: admission.things.myorg.io/v1alpha1
+    kind: WidgetSizeLimitConfiguration
+    default: "1"
+    namespaces:
+      default: "5"
+`, "WidgetSizeLimit"), namespace("default", corev1.NamespaceActive), namespace("other", corev1.NamespaceActive))
+
+	create := func(path, name, size string) (int, map[string]interface{}) {
+		return doRequest(t, ts, http.MethodPost, path, "application/json",
+			`{"metadata":{"name":"`+name+`"},"spec":{"name":"`+name+`","size":"`+size+`"}}`)
+	}
+	if code, obj := create(v1beta1WidgetsPath, "a", "3"); code != http.StatusCreated {
+		t.Fatalf("Expected a widget within the limit to be created, got %d: %v", code, obj)
+	}
+	code, obj := create(v1beta1WidgetsPath, "b", "2500m")
+	if code != http.StatusForbidden || !strings.Contains(fmt.Sprint(obj["message"]), "over its limit of 5") {
+		t.Fatalf("Expected a widget over the limit of the namespace to be forbidden, got %d: %v", code, obj)
+	}
+	if code, obj := create(v1beta1WidgetsPath, "b", "2"); code != http.StatusCreated {
+		t.Fatalf("Expected a widget up to the limit to be created, got %d: %v", code, obj)
+	}
+
+	// Growing a widget counts against the limit, anything else does not
+	code, obj = doRequest(t, ts, http.MethodPatch, v1beta1WidgetsPath+"/b", "application/merge-patch+json", `{"spec":{"size":"3"}}`)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected growing a widget over the limit to be forbidden, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, v1beta1WidgetsPath+"/b", "application/merge-patch+json", `{"spec":{"description":"same size"}}`)
+	if code != http.StatusOK {
+		t.Errorf("Expected an update keeping the size to succeed, got %d: %v", code, obj)
+	}
+
+	// Namespaces without a limit of their own have the default one
+	otherPath := "/apis/things.myorg.io/v1beta1/namespaces/other/widgets"
+	if code, obj := create(otherPath, "a", "2"); code != http.StatusForbidden {
+		t.Errorf("Expected a widget over the default limit to be forbidden, got %d: %v", code, obj)
+	}
+	if code, obj := create(otherPath, "a", "1"); code != http.StatusCreated {
+		t.Errorf("Expected a widget within the default limit to be created, got %d: %v", code, obj)
+	}
+}
+
+func TestAdmission_UniqueGadgetType(t *testing.T) {
+	ts := newAdmissionTestServerWithOptions(t, pluginAdmissionOptions(t, `
+apiVersion: apiserver.config.k8s.io/v1
+kind: AdmissionConfiguration
+plugins:
+- name: UniqueGadgetType
+  configuration:
+    apiVersion: admission.things.myorg.io/v1alpha1
+    kind: UniqueGadgetTypeConfiguration
+    types: [sensor]
+`, "UniqueGadgetType"), namespace("default", corev1.NamespaceActive))
+
+	create := func(name, typ string, enabled bool) (int, map[string]interface{}) {
+		return doRequest(t, ts, http.MethodPost, v1alpha1GadgetsPath, "application/json",
+			fmt.Sprintf(`{"metadata":{"name":%q},"spec":{"type":%q,"version":"1.0.0","enabled":%t}}`, name, typ, enabled))
+	}
+	for _, tc := range []struct {
+		name, typ string
+		enabled   bool
+		want      int
+	}{
+		{"sensor-1", "sensor", true, http.StatusCreated},
+		{"sensor-2", "sensor", true, http.StatusForbidden},
+		{"sensor-3", "sensor", false, http.StatusCreated},
+		{"actuator-1", "actuator", true, http.StatusCreated},
+		// Types not listed in the configuration are not limited
+		{"actuator-2", "actuator", true, http.StatusCreated},
+	} {
+		if code, obj := create(tc.name, tc.typ, tc.enabled); code != tc.want {
+			t.Errorf("Expected %d creating gadget %s, got %d: %v", tc.want, tc.name, code, obj)
+		}
+	}
+
+	code, obj := doRequest(t, ts, http.MethodPatch, v1alpha1GadgetsPath+"/sensor-3", "application/merge-patch+json", `{"spec":{"enabled":true}}`)
+	if code != http.StatusForbidden || !strings.Contains(fmt.Sprint(obj["message"]), "gadget sensor-1 is already an enabled sensor gadget") {
+		t.Errorf("Expected enabling a second sensor to be forbidden, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, v1alpha1GadgetsPath+"/sensor-1", "application/merge-patch+json", `{"spec":{"enabled":false}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected disabling the sensor to succeed, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, v1alpha1GadgetsPath+"/sensor-3", "application/merge-patch+json", `{"spec":{"enabled":true}}`)
+	if code != http.StatusOK {
+		t.Errorf("Expected enabling another sensor once the first is disabled to succeed, got %d: %v", code, obj)
+	}
+}
+
+func TestAdmission_ResourceQuota(t *testing.T) {
+	hard := corev1.ResourceList{
+		"count/widgets.things.myorg.io": resource.MustParse("2"),
+		quota.WidgetSize:                resource.MustParse("5"),
+	}
+	ts := newAdmissionTestServer(t, namespace("default", corev1.NamespaceActive), &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "widgets"},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		Status: corev1.ResourceQuotaStatus{Hard: hard, Used: corev1.ResourceList{
+			"count/widgets.things.myorg.io": resource.MustParse("0"),
+			quota.WidgetSize:                resource.MustParse("0"),
+		}},
+	})
+
+	create := func(name, size string) (int, map[string]interface{}) {
+		return doRequest(t, ts, http.MethodPost, v1beta1WidgetsPath, "application/json",
+			`{"metadata":{"name":"`+name+`"},"spec":{"name":"`+name+`","size":"`+size+`"}}`)
+	}
+	if code, obj := create("a", "3"); code != http.StatusCreated {
+		t.Fatalf("Expected a widget within the quota to be created, got %d: %v", code, obj)
+	}
+	code, obj := create("b", "3")
+	if code != http.StatusForbidden || !strings.Contains(fmt.Sprint(obj["message"]), "exceeded quota: widgets") {
+		t.Fatalf("Expected a widget over the size quota to be forbidden, got %d: %v", code, obj)
+	}
+	if code, obj := create("b", "1"); code != http.StatusCreated {
+		t.Fatalf("Expected a widget within the quota to be created, got %d: %v", code, obj)
+	}
+	code, obj = create("c", "0")
+	if code != http.StatusForbidden || !strings.Contains(fmt.Sprint(obj["message"]), "count/widgets.things.myorg.io") {
+		t.Errorf("Expected a widget over the count quota to be forbidden, got %d: %v", code, obj)
+	}
+
+	// Growing a widget is charged the difference
+	code, obj = doRequest(t, ts, http.MethodPatch, v1beta1WidgetsPath+"/b", "application/merge-patch+json", `{"spec":{"size":"2"}}`)
+	if code != http.StatusOK {
+		t.Errorf("Expected growing a widget within the quota to succeed, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, v1beta1WidgetsPath+"/b", "application/merge-patch+json", `{"spec":{"size":"3"}}`)
+	if code != http.StatusForbidden {
+		t.Errorf("Expected growing a widget over the quota to be forbidden, got %d: %v", code, obj)
+	}
+
+	// Gadgets are not limited by a quota of widgets
+	code, obj = doRequest(t, ts, http.MethodPost, v1alpha1GadgetsPath, "application/json",
+		`{"metadata":{"name":"g"},"spec":{"type":"sensor","version":"1.0.0","enabled":true}}`)
+	if code != http.StatusCreated {
+		t.Errorf("Expected a gadget to be created, got %d: %v", code, obj)
+	}
+}
+
+func TestCoreAPIAvailable(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	if coreAPIAvailable("") {
+		t.Error("Expected no core API without a kubeconfig outside a cluster")
+	}
+	if !coreAPIAvailable("/etc/kubernetes/kubeconfig") {
+		t.Error("Expected a kubeconfig to make the core API available")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	"k8s.io/client-go/rest"
+)
+
+const widgetsPath = "/apis/things.myorg.io/v1alpha1/namespaces/default/widgets"
+
+// newTestServer serves the API with in-memory storage, allowing every request
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(newTestAPIServer(t).GenericAPIServer.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newTestAPIServer returns the server behind newTestServer
+func newTestAPIServer(t *testing.T) *MyAPIServer {
+	t.Helper()
+	config := NewConfig()
+	config.GenericConfig.Authorization.Authorizer = authorizerfactory.NewAlwaysAllowAuthorizer()
+	config.GenericConfig.LoopbackClientConfig = &rest.Config{}
+	config.GenericConfig.ExternalAddress = "127.0.0.1:443"
+	server, err := config.Complete().New()
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	return server
+}
+
+// doRequest sends body to path and decodes the response into a map
+func doRequest(t *testing.T, ts *httptest.Server, method, path, contentType, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	out := map[string]interface{}{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", data, err)
+	}
+	return resp.StatusCode, out
+}
+
+func apply(t *testing.T, ts *httptest.Server, path, query, body string) (int, map[string]interface{}) {
+	t.Helper()
+	return doRequest(t, ts, http.MethodPatch, path+"?"+query, "application/apply-patch+yaml", body)
+}
+
+// managers returns the field managers recorded in obj and the operation of each
+func managers(obj map[string]interface{}) map[string]string {
+	result := map[string]string{}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	entries, _ := metadata["managedFields"].([]interface{})
+	for _, entry := range entries {
+		e := entry.(map[string]interface{})
+		manager := e["manager"].(string)
+		if subresource, ok := e["subresource"].(string); ok {
+			manager += "/" + subresource
+		}
+		result[manager], _ = e["operation"].(string)
+	}
+	return result
+}
+
+func TestServerSideApply(t *testing.T) {
+	ts := newTestServer(t)
+	path := widgetsPath + "/w"
+
+	// A server-side dry run reports the result without creating anything
+	code, obj := apply(t, ts, path, "fieldManager=a&dryRun=All",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"first","size":1}}`)
+	if code != http.StatusCreated || obj["metadata"].(map[string]interface{})["uid"] == nil {
+		t.Fatalf("Expected a dry-run apply to report the created widget, got %d: %v", code, obj)
+	}
+	if code, obj := doRequest(t, ts, http.MethodGet, path, "", ""); code != http.StatusNotFound {
+		t.Fatalf("Expected a dry-run apply not to create the widget, got %d: %v", code, obj)
+	}
+
+	code, obj = apply(t, ts, path, "fieldManager=a",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w","labels":{"team":"a"}},"spec":{"name":"first","size":1}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected apply to create the widget, got %d: %v", code, obj)
+	}
+	if m := managers(obj); len(m) != 1 || m["a"] != "Apply" {
+		t.Errorf("Expected only manager 'a' to own fields, got %v", m)
+	}
+	if status := obj["status"].(map[string]interface{}); status["phase"] != "Active" {
+		t.Errorf("Expected the created widget to be Active, got %v", status)
+	}
+
+	// Re-applying the same configuration is a no-op
+	code, obj = apply(t, ts, path, "fieldManager=a",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w","labels":{"team":"a"}},"spec":{"name":"first","size":1}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected apply to succeed, got %d: %v", code, obj)
+	}
+	if generation := obj["metadata"].(map[string]interface{})["generation"]; generation != float64(1) {
+		t.Errorf("Expected an unchanged generation, got %v", generation)
+	}
+
+	// Another manager may not take over fields owned by 'a' without forcing
+	code, obj = apply(t, ts, path, "fieldManager=b",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"size":2}}`)
+	if code != http.StatusConflict {
+		t.Fatalf("Expected a conflict, got %d: %v", code, obj)
+	}
+	if !strings.Contains(obj["message"].(string), ".spec.size") {
+		t.Errorf("Expected the conflict to name .spec.size, got %q", obj["message"])
+	}
+
+	code, obj = apply(t, ts, path, "fieldManager=b&force=true",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"size":2}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected a forced apply to succeed, got %d: %v", code, obj)
+	}
+	if size := obj["spec"].(map[string]interface{})["size"]; size != float64(2) {
+		t.Errorf("Expected size 2, got %v", size)
+	}
+	if m := managers(obj); len(m) != 2 || m["a"] != "Apply" || m["b"] != "Apply" {
+		t.Errorf("Expected managers 'a' and 'b', got %v", m)
+	}
+
+	// 'a' no longer owns spec.size, so dropping it from its configuration keeps b's value
+	code, obj = apply(t, ts, path, "fieldManager=a",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w","labels":{"team":"a"}},"spec":{"name":"first"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected apply to succeed, got %d: %v", code, obj)
+	}
+	if size := obj["spec"].(map[string]interface{})["size"]; size != float64(2) {
+		t.Errorf("Expected size 2 to be kept, got %v", size)
+	}
+}
+
+func TestServerSideApply_Status(t *testing.T) {
+	ts := newTestServer(t)
+	path := widgetsPath + "/w"
+
+	if code, obj := apply(t, ts, path, "fieldManager=user",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"w","size":1}}`); code != http.StatusCreated {
+		t.Fatalf("Expected apply to create the widget, got %d: %v", code, obj)
+	}
+
+	// Status applies do not create widgets and ignore the spec
+	if code, obj := apply(t, ts, widgetsPath+"/missing/status", "fieldManager=controller",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"missing"},"status":{"phase":"Ready"}}`); code != http.StatusNotFound {
+		t.Errorf("Expected a status apply to a missing widget to fail, got %d: %v", code, obj)
+	}
+	code, obj := apply(t, ts, path+"/status", "fieldManager=controller",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"size":5},"status":{"phase":"Ready"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status apply to succeed, got %d: %v", code, obj)
+	}
+	if phase := obj["status"].(map[string]interface{})["phase"]; phase != "Ready" {
+		t.Errorf("Expected phase Ready, got %v", phase)
+	}
+	if size := obj["spec"].(map[string]interface{})["size"]; size != float64(1) {
+		t.Errorf("Expected the status apply not to change the spec, got size %v", size)
+	}
+	if m := managers(obj); m["user"] != "Apply" || m["controller/status"] != "Apply" {
+		t.Errorf("Expected 'user' and 'controller' on the status subresource, got %v", m)
+	}
+
+	// A main resource apply cannot change the status
+	code, obj = apply(t, ts, path, "fieldManager=user",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"w","size":1},"status":{"phase":"Gone"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected apply to succeed, got %d: %v", code, obj)
+	}
+	if phase := obj["status"].(map[string]interface{})["phase"]; phase != "Ready" {
+		t.Errorf("Expected phase to stay Ready, got %v", phase)
+	}
+}
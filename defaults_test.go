@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// spec returns the spec of an object
+func spec(obj map[string]interface{}) map[string]interface{} {
+	s, _ := obj["spec"].(map[string]interface{})
+	return s
+}
+
+func TestDefaults_Widget(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Creating through either version defaults the size
+	for path, expected := range map[string]interface{}{widgetsPath: float64(1), v1beta1WidgetsPath: "1"} {
+		code, obj := doRequest(t, ts, http.MethodPost, path, "application/json", `{"metadata":{"name":"created"},"spec":{"name":"w"}}`)
+		if code != http.StatusCreated || spec(obj)["size"] != expected {
+			t.Fatalf("Expected a widget created through %s to have size %v, got %d: %v", path, expected, code, obj)
+		}
+		doRequest(t, ts, http.MethodDelete, path+"/created", "", "")
+	}
+
+	// Sizes that are set, even to 0, are kept
+	code, obj := doRequest(t, ts, http.MethodPost, widgetsPath, "application/json", `{"metadata":{"name":"w"},"spec":{"name":"w","size":0}}`)
+	if code != http.StatusCreated || spec(obj)["size"] != float64(0) {
+		t.Fatalf("Expected an explicit size of 0 to be kept, got %d: %v", code, obj)
+	}
+
+	// Patches removing the size default it again, as do updates leaving it out
+	code, obj = doRequest(t, ts, http.MethodPatch, widgetsPath+"/w", "application/merge-patch+json", `{"spec":{"size":null}}`)
+	if code != http.StatusOK || spec(obj)["size"] != float64(1) {
+		t.Errorf("Expected removing the size with a merge patch to default it, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, widgetsPath+"/w", "application/json-patch+json",
+		`[{"op":"replace","path":"/spec/size","value":4},{"op":"remove","path":"/spec/size"}]`)
+	if code != http.StatusOK || spec(obj)["size"] != float64(1) {
+		t.Errorf("Expected removing the size with a JSON patch to default it, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPut, widgetsPath+"/w", "application/json",
+		`{"metadata":{"name":"w","resourceVersion":"`+obj["metadata"].(map[string]interface{})["resourceVersion"].(string)+`"},"spec":{"name":"w","description":"put"}}`)
+	if code != http.StatusOK || spec(obj)["size"] != float64(1) {
+		t.Errorf("Expected an update leaving out the size to default it, got %d: %v", code, obj)
+	}
+}
+
+func TestDefaults_Apply(t *testing.T) {
+	ts := newTestServer(t)
+	path := v1beta1WidgetsPath + "/applied"
+
+	code, obj := apply(t, ts, path, "fieldManager=test",
+		`{"apiVersion":"things.myorg.io/v1beta1","kind":"Widget","metadata":{"name":"applied"},"spec":{"name":"w","size":"3"}}`)
+	if code != http.StatusCreated || spec(obj)["size"] != "3" {
+		t.Fatalf("Expected the widget to be applied with size 3, got %d: %v", code, obj)
+	}
+
+	// Dropping the only owner of the size from the applied configuration removes it, which
+	// defaults it; the v1beta1 rule then keeps the widget from shrinking
+	code, obj = apply(t, ts, path, "fieldManager=test",
+		`{"apiVersion":"things.myorg.io/v1beta1","kind":"Widget","metadata":{"name":"applied"},"spec":{"name":"w"}}`)
+	if code != http.StatusUnprocessableEntity || !strings.Contains(strings.Join(causes(obj), "\n"), "cannot shrink") {
+		t.Errorf("Expected applying without the size to default it and be rejected, got %d: %v", code, obj)
+	}
+
+	code, obj = apply(t, ts, v1alpha1GadgetsPath+"/applied", "fieldManager=test",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Gadget","metadata":{"name":"applied"},"spec":{"type":"sensor","version":"1.0.0"}}`)
+	if code != http.StatusCreated || spec(obj)["enabled"] != true || spec(obj)["priority"] != float64(0) {
+		t.Errorf("Expected an applied gadget to be enabled with priority 0, got %d: %v", code, obj)
+	}
+}
+
+func TestDefaults_Gadget(t *testing.T) {
+	ts := newTestServer(t)
+
+	for path, version := range map[string]string{
+		v1alpha1GadgetsPath: `"1.0.0"`,
+		v1beta1GadgetsPath:  `{"major":1,"minor":0,"patch":0}`,
+	} {
+		code, obj := doRequest(t, ts, http.MethodPost, path, "application/json",
+			`{"metadata":{"name":"defaulted"},"spec":{"type":"sensor","version":`+version+`}}`)
+		if code != http.StatusCreated || spec(obj)["enabled"] != true || spec(obj)["priority"] != float64(0) {
+			t.Errorf("Expected a gadget created through %s to be enabled with priority 0, got %d: %v", path, code, obj)
+		}
+
+		// Gadgets disabled explicitly stay disabled
+		code, obj = doRequest(t, ts, http.MethodPost, path, "application/json",
+			`{"metadata":{"name":"disabled"},"spec":{"type":"sensor","version":`+version+`,"enabled":false}}`)
+		if code != http.StatusCreated || spec(obj)["enabled"] != false {
+			t.Errorf("Expected a gadget created disabled through %s to stay disabled, got %d: %v", path, code, obj)
+		}
+		doRequest(t, ts, http.MethodDelete, path+"/defaulted", "", "")
+		doRequest(t, ts, http.MethodDelete, path+"/disabled", "", "")
+	}
+}
+
+func TestDefaults_OpenAPI(t *testing.T) {
+	// The OpenAPI documents are installed when the server prepares to run
+	ts := httptest.NewServer(newTestAPIServer(t).GenericAPIServer.PrepareRun().Handler)
+	t.Cleanup(ts.Close)
+
+	code, doc := doRequest(t, ts, http.MethodGet, "/openapi/v3/apis/things.myorg.io/v1beta1", "", "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected the OpenAPI document, got %d: %v", code, doc)
+	}
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	defaults := map[string]interface{}{}
+	for name, schema := range schemas {
+		if !strings.HasSuffix(name, ".v1beta1.WidgetSpec") && !strings.HasSuffix(name, ".v1beta1.GadgetSpec") {
+			continue
+		}
+		kind := name[strings.LastIndex(name, ".")+1:]
+		for field, property := range schema.(map[string]interface{})["properties"].(map[string]interface{}) {
+			if value, ok := property.(map[string]interface{})["default"]; ok {
+				defaults[kind+"."+field] = value
+			}
+		}
+	}
+	expected := map[string]interface{}{"WidgetSpec.size": "1", "GadgetSpec.enabled": true, "GadgetSpec.priority": float64(0)}
+	for field, value := range expected {
+		if defaults[field] != value {
+			t.Errorf("Expected %s to default to %v in the OpenAPI schema, got %v", field, value, defaults[field])
+		}
+	}
+}
@@ -160,18 +160,20 @@ status() {
         return 1
     fi
     
-    # Check APIService
-    if kubectl get apiservice v1alpha1.things.myorg.io &> /dev/null; then
-        AVAILABLE=$(kubectl get apiservice v1alpha1.things.myorg.io -o jsonpath='{.status.conditions[?(@.type=="Available")].status}')
-        if [ "$AVAILABLE" = "True" ]; then
-            echo "✅ APIService: v1alpha1.things.myorg.io available"
+    # Check APIServices
+    for APISERVICE in v1beta1.things.myorg.io v1alpha1.things.myorg.io; do
+        if kubectl get apiservice $APISERVICE &> /dev/null; then
+            AVAILABLE=$(kubectl get apiservice $APISERVICE -o jsonpath='{.status.conditions[?(@.type=="Available")].status}')
+            if [ "$AVAILABLE" = "True" ]; then
+                echo "✅ APIService: $APISERVICE available"
+            else
+                echo "⚠️  APIService: $APISERVICE not available"
+            fi
         else
-            echo "⚠️  APIService: v1alpha1.things.myorg.io not available"
+            echo "❌ APIService: $APISERVICE not found"
+            return 1
         fi
-    else
-        echo "❌ APIService: v1alpha1.things.myorg.io not found"
-        return 1
-    fi
+    done
     
     # Check custom resources
     if kubectl api-resources | grep -q "things.myorg.io"; then
@@ -0,0 +1,63 @@
+#!/bin/bash
+
+# Copyright 2024 The Kubernetes Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SCRIPT_ROOT="$(dirname "${BASH_SOURCE[0]}")/.."
+MODULE="example.com/mytest-apiserver"
+CODEGEN_VERSION="v0.33.3"
+
+# Install the generators if not present
+for gen in deepcopy-gen conversion-gen defaulter-gen; do
+    if [ ! -f "${SCRIPT_ROOT}/bin/${gen}" ]; then
+        echo "Installing ${gen}..."
+        mkdir -p "${SCRIPT_ROOT}/bin"
+        GOBIN="$(cd "${SCRIPT_ROOT}/bin" && pwd)" go install "k8s.io/code-generator/cmd/${gen}@${CODEGEN_VERSION}"
+    fi
+done
+
+INTERNAL_PKGS=(
+    "${MODULE}/pkg/apis/widgets"
+    "${MODULE}/pkg/apis/gadgets"
+)
+VERSIONED_PKGS=(
+    "${MODULE}/pkg/apis/widgets/v1alpha1"
+    "${MODULE}/pkg/apis/widgets/v1beta1"
+    "${MODULE}/pkg/apis/gadgets/v1alpha1"
+    "${MODULE}/pkg/apis/gadgets/v1beta1"
+)
+
+echo "Generating deepcopy functions..."
+"${SCRIPT_ROOT}/bin/deepcopy-gen" \
+    --go-header-file="${SCRIPT_ROOT}/hack/boilerplate.go.txt" \
+    --output-file="zz_generated.deepcopy.go" \
+    "${INTERNAL_PKGS[@]}" "${VERSIONED_PKGS[@]}"
+
+echo "Generating conversion functions..."
+"${SCRIPT_ROOT}/bin/conversion-gen" \
+    --go-header-file="${SCRIPT_ROOT}/hack/boilerplate.go.txt" \
+    --output-file="zz_generated.conversion.go" \
+    "${VERSIONED_PKGS[@]}"
+
+echo "Generating defaulters..."
+"${SCRIPT_ROOT}/bin/defaulter-gen" \
+    --go-header-file="${SCRIPT_ROOT}/hack/boilerplate.go.txt" \
+    --output-file="zz_generated.defaults.go" \
+    "${VERSIONED_PKGS[@]}"
+
+echo "Code generation completed successfully!"
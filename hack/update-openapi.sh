@@ -45,8 +45,11 @@ echo "Generating OpenAPI definitions..."
     --output-file="zz_generated.openapi.go" \
     --report-filename="${SCRIPT_ROOT}/violations.report" \
     -v 2 \
-    "${OPENAPI_PKG}/pkg/apis/widgets" \
-    "${OPENAPI_PKG}/pkg/apis/gadgets" \
+    "${OPENAPI_PKG}/pkg/apis/widgets/v1alpha1" \
+    "${OPENAPI_PKG}/pkg/apis/widgets/v1beta1" \
+    "${OPENAPI_PKG}/pkg/apis/gadgets/v1alpha1" \
+    "${OPENAPI_PKG}/pkg/apis/gadgets/v1beta1" \
+    "k8s.io/apimachinery/pkg/api/resource" \
     "k8s.io/apimachinery/pkg/apis/meta/v1" \
     "k8s.io/apimachinery/pkg/runtime" \
     "k8s.io/apimachinery/pkg/version"
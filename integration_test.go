@@ -9,9 +9,11 @@ import (
 	"testing"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 
 	"example.com/mytest-apiserver/pkg/apis/gadgets"
 	"example.com/mytest-apiserver/pkg/apis/widgets"
@@ -22,7 +24,7 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 	// Create REST handlers
 	widgetREST := widgets.NewWidgetREST()
 	gadgetREST := gadgets.NewGadgetREST()
-	ctx := context.Background()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
 
 	// Test scenario: Create a widget and related gadgets
 	widget := &widgets.Widget{
@@ -33,7 +35,7 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 		Spec: widgets.WidgetSpec{
 			Name:        "Main Control Widget",
 			Description: "Primary control interface",
-			Size:        100,
+			Size:        resource.MustParse("100"),
 		},
 	}
 
@@ -52,7 +54,7 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 			},
 			Spec: gadgets.GadgetSpec{
 				Type:     "temperature-sensor",
-				Version:  "v1.0",
+				Version:  "1.0.0",
 				Enabled:  true,
 				Priority: 10,
 			},
@@ -64,7 +66,7 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 			},
 			Spec: gadgets.GadgetSpec{
 				Type:     "humidity-sensor",
-				Version:  "v1.1",
+				Version:  "1.1.0",
 				Enabled:  true,
 				Priority: 5,
 			},
@@ -76,7 +78,7 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 			},
 			Spec: gadgets.GadgetSpec{
 				Type:     "motor-controller",
-				Version:  "v2.0",
+				Version:  "2.0.0",
 				Enabled:  false,
 				Priority: 20,
 			},
@@ -128,7 +130,7 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 		}
 	}
 
-	mainWidget.Spec.Size = int32(activeGadgets * 50)
+	mainWidget.Spec.Size = *resource.NewQuantity(int64(activeGadgets*50), resource.DecimalSI)
 	mainWidget.Spec.Description = "Widget with connected gadgets"
 
 	// Mock update info for testing
@@ -145,9 +147,9 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 	}
 
 	finalWidget := updatedWidget.(*widgets.Widget)
-	expectedSize := int32(activeGadgets * 50)
-	if finalWidget.Spec.Size != expectedSize {
-		t.Errorf("Expected widget size %d, got %d", expectedSize, finalWidget.Spec.Size)
+	expectedSize := int64(activeGadgets * 50)
+	if finalWidget.Spec.Size.Value() != expectedSize {
+		t.Errorf("Expected widget size %d, got %s", expectedSize, finalWidget.Spec.Size.String())
 	}
 
 	// Clean up - delete all resources
@@ -168,7 +170,7 @@ func TestWidgetGadgetIntegration(t *testing.T) {
 func TestConcurrentOperations(t *testing.T) {
 	widgetREST := widgets.NewWidgetREST()
 	gadgetREST := gadgets.NewGadgetREST()
-	ctx := context.Background()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
 
 	const numWorkers = 5
 	const numOperations = 20
@@ -187,7 +189,7 @@ func TestConcurrentOperations(t *testing.T) {
 					Spec: widgets.WidgetSpec{
 						Name:        fmt.Sprintf("Widget %d-%d", workerID, j),
 						Description: "Concurrent test widget",
-						Size:        int32(j),
+						Size:        *resource.NewQuantity(int64(j), resource.DecimalSI),
 					},
 				}
 
@@ -205,7 +207,7 @@ func TestConcurrentOperations(t *testing.T) {
 					},
 					Spec: gadgets.GadgetSpec{
 						Type:     "test-sensor",
-						Version:  "v1.0",
+						Version:  "1.0.0",
 						Enabled:  true,
 						Priority: int32(workerID * 10),
 					},
@@ -256,7 +258,7 @@ func TestConcurrentOperations(t *testing.T) {
 func TestResourceLifecycle(t *testing.T) {
 	widgetREST := widgets.NewWidgetREST()
 	gadgetREST := gadgets.NewGadgetREST()
-	ctx := context.Background()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
 
 	// Phase 1: Create resources
 	widget := &widgets.Widget{
@@ -267,7 +269,7 @@ func TestResourceLifecycle(t *testing.T) {
 		Spec: widgets.WidgetSpec{
 			Name:        "Lifecycle Test Widget",
 			Description: "Testing complete lifecycle",
-			Size:        50,
+			Size:        resource.MustParse("50"),
 		},
 	}
 
@@ -285,7 +287,7 @@ func TestResourceLifecycle(t *testing.T) {
 		},
 		Spec: gadgets.GadgetSpec{
 			Type:     "lifecycle-sensor",
-			Version:  "v1.0",
+			Version:  "1.0.0",
 			Enabled:  true,
 			Priority: 15,
 		},
@@ -304,7 +306,7 @@ func TestResourceLifecycle(t *testing.T) {
 		t.Fatalf("Failed to get widget: %v", err)
 	}
 
-	if retrievedWidget.(*widgets.Widget).Spec.Size != 50 {
+	if retrievedWidget.(*widgets.Widget).Spec.Size.Value() != 50 {
 		t.Error("Widget spec not preserved after creation")
 	}
 
@@ -318,7 +320,7 @@ func TestResourceLifecycle(t *testing.T) {
 	}
 
 	// Phase 3: Update
-	widget.Spec.Size = 75
+	widget.Spec.Size = resource.MustParse("75")
 	widget.Spec.Description = "Updated lifecycle widget"
 
 	updateInfo := &mockUpdateInfo{updatedObj: widget}
@@ -327,7 +329,7 @@ func TestResourceLifecycle(t *testing.T) {
 		t.Fatalf("Failed to update widget: %v", err)
 	}
 
-	if updatedWidget.(*widgets.Widget).Spec.Size != 75 {
+	if updatedWidget.(*widgets.Widget).Spec.Size.Value() != 75 {
 		t.Error("Widget update failed")
 	}
 
@@ -2,46 +2,192 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
+	"example.com/mytest-apiserver/pkg/admission/plugin/uniquegadgettype"
+	"example.com/mytest-apiserver/pkg/admission/plugin/widgetsizelimit"
+	"example.com/mytest-apiserver/pkg/admission/thingsinitializer"
 	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	gadgetsinstall "example.com/mytest-apiserver/pkg/apis/gadgets/install"
 	"example.com/mytest-apiserver/pkg/apis/widgets"
+	widgetsinstall "example.com/mytest-apiserver/pkg/apis/widgets/install"
+	"example.com/mytest-apiserver/pkg/apis/widgets/v1alpha1"
 	mycommon "example.com/mytest-apiserver/pkg/common"
 	generatedopenapi "example.com/mytest-apiserver/pkg/generated/openapi"
+	"example.com/mytest-apiserver/pkg/quota"
+	"example.com/mytest-apiserver/pkg/rules"
+	"example.com/mytest-apiserver/pkg/store"
 	"github.com/spf13/pflag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apiserver/pkg/admission"
+	mutatingadmissionpolicy "k8s.io/apiserver/pkg/admission/plugin/policy/mutating"
+	"k8s.io/apiserver/pkg/admission/plugin/resourcequota"
 	"k8s.io/apiserver/pkg/endpoints/openapi"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
 	basecompatibility "k8s.io/component-base/compatibility"
 	"k8s.io/klog/v2"
 )
 
+// defaultEtcdPathPrefix is the etcd key prefix objects are stored under
+const defaultEtcdPathPrefix = "/registry/" + mycommon.GroupName
+
 var (
 	Scheme = runtime.NewScheme()
 	Codecs = serializer.NewCodecFactory(Scheme)
 )
 
+// quotaUsageSyncPeriod is how often the usage quotas record for widgets and gadgets is recounted
+const quotaUsageSyncPeriod = 30 * time.Second
+
+// defaultStorageVersion is the version objects are persisted in unless configured otherwise
+var defaultStorageVersion = v1alpha1.SchemeGroupVersion
+
 func init() {
-	gv := schema.GroupVersion{Group: mycommon.GroupName, Version: mycommon.APIVersion}
-	Scheme.AddKnownTypes(gv, &widgets.Widget{}, &widgets.WidgetList{}, &gadgets.Gadget{}, &gadgets.GadgetList{})
-	metav1.AddToGroupVersion(Scheme, gv)
+	widgetsinstall.Install(Scheme)
+	gadgetsinstall.Install(Scheme)
+	for _, gv := range Scheme.PrioritizedVersionsForGroup(mycommon.GroupName) {
+		metav1.AddToGroupVersion(Scheme, gv)
+	}
 
 	// Register meta types
 	metav1.AddToGroupVersion(Scheme, schema.GroupVersion{Version: "v1"})
 }
 
-func installAPI(s *genericapiserver.GenericAPIServer) error {
-	widgetREST := widgets.NewWidgetREST()
-	gadgetREST := gadgets.NewGadgetREST()
+// compileValidationRules compiles the CEL rules of the widget and gadget schemas. Objects
+// written in any version are validated in the preferred version, whose schema has the rules.
+func compileValidationRules() (widgetRules, gadgetRules *rules.Validator, err error) {
+	resolver := rules.NewSchemaResolver(generatedopenapi.GetOpenAPIDefinitions, Scheme)
+	preferred := Scheme.PrioritizedVersionsForGroup(mycommon.GroupName)[0]
+
+	if widgetRules, err = rules.NewValidator(resolver, Scheme, preferred.WithKind("Widget")); err != nil {
+		return nil, nil, err
+	}
+	if gadgetRules, err = rules.NewValidator(resolver, Scheme, preferred.WithKind("Gadget")); err != nil {
+		return nil, nil, err
+	}
+	return widgetRules, gadgetRules, nil
+}
+
+// storageVersion returns the group version called version objects can be persisted in
+func storageVersion(version string) (schema.GroupVersion, error) {
+	gv := schema.GroupVersion{Group: mycommon.GroupName, Version: version}
+	if !Scheme.IsVersionRegistered(gv) {
+		return schema.GroupVersion{}, fmt.Errorf("--storage-version must be a served version of %s, got %q",
+			mycommon.GroupName, version)
+	}
+	return gv, nil
+}
+
+// addStorageVersionFlag adds the --storage-version flag, which is parsed with storageVersion
+func addStorageVersionFlag(fs *pflag.FlagSet, version *string) {
+	fs.StringVar(version, "storage-version", *version,
+		"Version of "+mycommon.GroupName+" objects are persisted in, for etcd and file backed storage alike. "+
+			"Objects stored in another version are still read, and rewritten in this one when they are next updated "+
+			"or migrated with '"+migrateCommand+"'.")
+}
+
+// newAdmissionOptions returns the admission chain run on every write to widgets and gadgets:
+// NamespaceLifecycle, MutatingAdmissionWebhook, ValidatingAdmissionPolicy,
+// ValidatingAdmissionWebhook and, last so that only admitted objects are charged,
+// ResourceQuota. The alpha MutatingAdmissionPolicy and the plugins of this server,
+// WidgetSizeLimit and UniqueGadgetType, stay off unless enabled with --enable-admission-plugins.
+func newAdmissionOptions() *genericoptions.AdmissionOptions {
+	options := genericoptions.NewAdmissionOptions()
+	widgetsizelimit.Register(options.Plugins)
+	uniquegadgettype.Register(options.Plugins)
+	resourcequota.Register(options.Plugins)
+	options.RecommendedPluginOrder = append(options.RecommendedPluginOrder,
+		widgetsizelimit.PluginName, uniquegadgettype.PluginName, resourcequota.PluginName)
+	options.DefaultOffPlugins.Insert(mutatingadmissionpolicy.PluginName,
+		widgetsizelimit.PluginName, uniquegadgettype.PluginName)
+	return options
+}
+
+// coreAPIAvailable reports whether the core API server can be reached, through kubeconfig or
+// from inside a cluster
+func coreAPIAvailable(kubeconfig string) bool {
+	if kubeconfig != "" {
+		return true
+	}
+	_, err := restclient.InClusterConfig()
+	return !errors.Is(err, restclient.ErrNotInCluster)
+}
+
+// storageCodec encodes objects in storageVersion and decodes any served version to the
+// internal one
+func storageCodec(storageVersion schema.GroupVersion) runtime.Codec {
+	return Codecs.LegacyCodec(storageVersion)
+}
+
+// newStorage returns etcd backed storage if etcd is configured, file backed storage if a
+// storage directory is configured and in-memory storage otherwise. Etcd storage is encoded
+// as configured in optsGetter, file backed storage in storageVersion.
+func newStorage(optsGetter generic.RESTOptionsGetter, fileOptions *store.FileOptions,
+	storageVersion schema.GroupVersion) (widgets.Storage, gadgets.Storage, error) {
+	if optsGetter == nil {
+		// All resources take their resourceVersions from one clock, as they would from etcd
+		clock := store.NewClock()
+		if fileOptions == nil || fileOptions.Dir == "" {
+			return widgets.NewMemoryStorageWithClock(clock), gadgets.NewGadgetStorageWithClock(clock), nil
+		}
+
+		codec := storageCodec(storageVersion)
+		widgetStorage, err := widgets.NewFileStorage(filepath.Join(fileOptions.Dir, "widgets"), fileOptions, codec, clock)
+		if err != nil {
+			return nil, nil, err
+		}
+		gadgetStorage, err := gadgets.NewFileStorage(filepath.Join(fileOptions.Dir, "gadgets"), fileOptions, codec, clock)
+		if err != nil {
+			widgetStorage.Destroy()
+			return nil, nil, err
+		}
+		return widgetStorage, gadgetStorage, nil
+	}
+
+	widgetStorage, err := widgets.NewEtcdStorage(Scheme, optsGetter)
+	if err != nil {
+		return nil, nil, err
+	}
+	gadgetStorage, err := gadgets.NewEtcdStorage(Scheme, optsGetter)
+	if err != nil {
+		widgetStorage.Destroy()
+		return nil, nil, err
+	}
+	return widgetStorage, gadgetStorage, nil
+}
+
+// installAPI serves every version of the group from the same storage, which keeps objects in
+// their internal version, enforcing the validation rules of their schemas
+func installAPI(s *genericapiserver.GenericAPIServer, widgetStorage widgets.Storage, gadgetStorage gadgets.Storage) error {
+	widgetRules, gadgetRules, err := compileValidationRules()
+	if err != nil {
+		return err
+	}
+	widgetStrategy := widgets.NewStrategy(Scheme, widgetRules)
+	gadgetStrategy := gadgets.NewStrategy(Scheme, gadgetRules)
+	resources := map[string]rest.Storage{
+		"widgets":        widgets.NewWidgetRESTWithStorage(widgetStorage, widgetStrategy),
+		"widgets/status": widgets.NewStatusREST(widgetStorage, widgetStrategy),
+		"gadgets":        gadgets.NewGadgetRESTWithStorage(gadgetStorage, gadgetStrategy),
+		"gadgets/status": gadgets.NewStatusREST(gadgetStorage, gadgetStrategy),
+	}
 
 	apiGroupInfo := genericapiserver.NewDefaultAPIGroupInfo(mycommon.GroupName, Scheme, metav1.ParameterCodec, Codecs)
-	apiGroupInfo.VersionedResourcesStorageMap[mycommon.APIVersion] = map[string]rest.Storage{
-		"widgets": widgetREST,
-		"gadgets": gadgetREST,
+	for _, gv := range apiGroupInfo.PrioritizedVersions {
+		apiGroupInfo.VersionedResourcesStorageMap[gv.Version] = resources
 	}
 
 	return s.InstallAPIGroup(&apiGroupInfo)
@@ -49,6 +195,17 @@ func installAPI(s *genericapiserver.GenericAPIServer) error {
 
 type Config struct {
 	GenericConfig *genericapiserver.RecommendedConfig
+
+	// FileStorage configures file backed storage; it is only used without etcd
+	FileStorage *store.FileOptions
+
+	// StorageVersion is the version file backed storage persists objects in. Etcd storage is
+	// encoded as configured in GenericConfig.
+	StorageVersion schema.GroupVersion
+
+	// widgetStorage and gadgetStorage are created by storage once the generic config is applied
+	widgetStorage widgets.Storage
+	gadgetStorage gadgets.Storage
 }
 
 type MyAPIServer struct {
@@ -61,7 +218,8 @@ func (s *MyAPIServer) Run(ctx context.Context) error {
 
 func NewConfig() *Config {
 	return &Config{
-		GenericConfig: genericapiserver.NewRecommendedConfig(Codecs),
+		GenericConfig:  genericapiserver.NewRecommendedConfig(Codecs),
+		StorageVersion: defaultStorageVersion,
 	}
 }
 
@@ -78,6 +236,46 @@ func (c *Config) Complete() *Config {
 	return c
 }
 
+// storage returns the storage of widgets and gadgets, creating it on first use. Admission plugins
+// and the API share it, so it is created by whichever needs it first.
+func (c *Config) storage() (widgets.Storage, gadgets.Storage, error) {
+	if c.widgetStorage == nil {
+		widgetStorage, gadgetStorage, err := newStorage(c.GenericConfig.RESTOptionsGetter, c.FileStorage, c.StorageVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.widgetStorage, c.gadgetStorage = widgetStorage, gadgetStorage
+	}
+	return c.widgetStorage, c.gadgetStorage, nil
+}
+
+// quotaConfiguration returns the quota evaluators of widgets and gadgets, which count them in
+// storage
+func (c *Config) quotaConfiguration() (quotav1.Configuration, error) {
+	widgetStorage, gadgetStorage, err := c.storage()
+	if err != nil {
+		return nil, err
+	}
+	return quota.NewConfiguration(widgets.NewLister(widgetStorage), gadgets.NewLister(gadgetStorage)), nil
+}
+
+// admissionInitializers hands the plugins of this server listers over the storage of widgets
+// and gadgets, and ResourceQuota their quota configuration. The storage must be configured,
+// which RecommendedOptions.ApplyTo does before calling it.
+func (c *Config) admissionInitializers(*genericapiserver.RecommendedConfig) ([]admission.PluginInitializer, error) {
+	widgetStorage, gadgetStorage, err := c.storage()
+	if err != nil {
+		return nil, err
+	}
+	quotaConfiguration, err := c.quotaConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	return []admission.PluginInitializer{
+		thingsinitializer.New(widgets.NewLister(widgetStorage), gadgets.NewLister(gadgetStorage), quotaConfiguration),
+	}, nil
+}
+
 func (c *Config) New() (*MyAPIServer, error) {
 	genericServer, err := c.GenericConfig.Complete().New("my-apiserver", genericapiserver.NewEmptyDelegate())
 	if err != nil {
@@ -88,34 +286,96 @@ func (c *Config) New() (*MyAPIServer, error) {
 		GenericAPIServer: genericServer,
 	}
 
-	if err := installAPI(s.GenericAPIServer); err != nil {
+	widgetStorage, gadgetStorage, err := c.storage()
+	if err != nil {
+		return nil, err
+	}
+	if err := installAPI(s.GenericAPIServer, widgetStorage, gadgetStorage); err != nil {
 		return nil, err
 	}
 
+	// Quotas live in the core API server, so usage is only recounted when it can be reached
+	if c.GenericConfig.ClientConfig != nil {
+		client, err := kubernetes.NewForConfig(c.GenericConfig.ClientConfig)
+		if err != nil {
+			return nil, err
+		}
+		quotaConfiguration, err := c.quotaConfiguration()
+		if err != nil {
+			return nil, err
+		}
+		controller := quota.NewUsageController(client, quotaConfiguration)
+		if err := s.GenericAPIServer.AddPostStartHook("things-quota-usage", func(ctx genericapiserver.PostStartHookContext) error {
+			go controller.Run(ctx, quotaUsageSyncPeriod)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
 	return s, nil
 }
 
 func main() {
 	klog.InitFlags(nil)
 
-	options := genericoptions.NewRecommendedOptions("", Codecs.LegacyCodec())
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		if err := runMigration(genericapiserver.SetupSignalContext(), os.Args[2:]); err != nil {
+			klog.Fatalf("Error migrating storage: %v", err)
+		}
+		return
+	}
+
+	// Objects are stored in the configured storage version and decode to the internal version
+	options := genericoptions.NewRecommendedOptions(defaultEtcdPathPrefix, storageCodec(defaultStorageVersion))
 
-	// Now disable etcd for in-memory storage after validation passes
-	options.Etcd = nil
+	options.Admission = newAdmissionOptions()
 
 	// Disable optional features not available in all clusters
-	options.Admission = nil
 	options.Features = nil
 
+	fileOptions := store.NewFileOptions()
+	version := defaultStorageVersion.Version
+
 	options.AddFlags(pflag.CommandLine)
+	fileOptions.AddFlags(pflag.CommandLine)
+	addStorageVersionFlag(pflag.CommandLine, &version)
 
 	pflag.Parse()
 
+	// Admission looks up namespaces, webhooks and policies in the core API server. Local runs
+	// with neither a kubeconfig nor a cluster to run in go without it.
+	if !coreAPIAvailable(options.CoreAPI.CoreAPIKubeconfigPath) {
+		klog.Warningf("No --kubeconfig given outside a cluster, running without admission plugins")
+		options.CoreAPI = nil
+		options.Admission = nil
+	}
+
+	gv, err := storageVersion(version)
+	if err != nil {
+		klog.Fatalf("Error validating storage options: %v", err)
+	}
+	options.Etcd.StorageConfig.Codec = storageCodec(gv)
+	options.Etcd.StorageConfig.EncodeVersioner = gv
+
+	// Fall back to file backed or in-memory storage unless etcd servers are configured
+	if len(options.Etcd.StorageConfig.Transport.ServerList) == 0 {
+		options.Etcd = nil
+	} else if fileOptions.Dir != "" {
+		klog.Fatalf("--storage-dir cannot be combined with --etcd-servers")
+	}
+
 	if errs := options.Validate(); len(errs) != 0 {
 		klog.Errorf("Error validating options: %v", errs)
 	}
+	if errs := fileOptions.Validate(); len(errs) != 0 {
+		klog.Fatalf("Error validating storage options: %v", errs)
+	}
 
 	config := NewConfig()
+	config.FileStorage = fileOptions
+	config.StorageVersion = gv
+	options.ExtraAdmissionInitializers = config.admissionInitializers
 	if err := options.ApplyTo(config.GenericConfig); err != nil {
 		klog.Fatalf("Error applying options: %v", err)
 	}
@@ -127,7 +387,9 @@ func main() {
 		klog.Fatalf("Error creating server: %v", err)
 	}
 
-	ctx := context.Background()
+	// Shutting down on a signal lets the server destroy its storage, compacting and flushing
+	// file backed storage to disk
+	ctx := genericapiserver.SetupSignalContext()
 	klog.Infof("Starting my-apiserver...")
 	if err := server.Run(ctx); err != nil {
 		klog.Fatalf("Error running server: %v", err)
@@ -2,53 +2,57 @@ package main
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 
 	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	v1alpha1gadgets "example.com/mytest-apiserver/pkg/apis/gadgets/v1alpha1"
+	v1beta1gadgets "example.com/mytest-apiserver/pkg/apis/gadgets/v1beta1"
 	"example.com/mytest-apiserver/pkg/apis/widgets"
+	v1alpha1widgets "example.com/mytest-apiserver/pkg/apis/widgets/v1alpha1"
+	v1beta1widgets "example.com/mytest-apiserver/pkg/apis/widgets/v1beta1"
+	"example.com/mytest-apiserver/pkg/store"
 )
 
 func TestSchemeRegistration(t *testing.T) {
 	// Test that our resources are properly registered in the scheme
 	gv := Scheme.PrioritizedVersionsForGroup("things.myorg.io")
-	if len(gv) == 0 {
-		t.Error("Expected group 'things.myorg.io' to be registered")
-	}
-
-	// Test Widget registration
-	widgetGVK := schema.GroupVersionKind{
-		Group:   "things.myorg.io",
-		Version: "v1alpha1",
-		Kind:    "Widget",
-	}
-
-	obj, err := Scheme.New(widgetGVK)
-	if err != nil {
-		t.Errorf("Failed to create Widget from scheme: %v", err)
-	}
-
-	if _, ok := obj.(*widgets.Widget); !ok {
-		t.Errorf("Expected *widgets.Widget, got %T", obj)
-	}
-
-	// Test Gadget registration
-	gadgetGVK := schema.GroupVersionKind{
-		Group:   "things.myorg.io",
-		Version: "v1alpha1",
-		Kind:    "Gadget",
-	}
-
-	obj, err = Scheme.New(gadgetGVK)
-	if err != nil {
-		t.Errorf("Failed to create Gadget from scheme: %v", err)
-	}
-
-	if _, ok := obj.(*gadgets.Gadget); !ok {
-		t.Errorf("Expected *gadgets.Gadget, got %T", obj)
+	if len(gv) != 2 {
+		t.Fatalf("Expected group 'things.myorg.io' to be registered in two versions, got %v", gv)
+	}
+	if gv[0] != v1beta1widgets.SchemeGroupVersion || gv[1] != v1alpha1widgets.SchemeGroupVersion {
+		t.Errorf("Expected v1beta1 to be preferred over v1alpha1, got %v", gv)
+	}
+
+	// Every version has its own types, and the internal version those of the storage
+	for _, tc := range []struct {
+		version string
+		kind    string
+		want    runtime.Object
+	}{
+		{"v1alpha1", "Widget", &v1alpha1widgets.Widget{}},
+		{"v1beta1", "Widget", &v1beta1widgets.Widget{}},
+		{runtime.APIVersionInternal, "Widget", &widgets.Widget{}},
+		{"v1alpha1", "Gadget", &v1alpha1gadgets.Gadget{}},
+		{"v1beta1", "Gadget", &v1beta1gadgets.Gadget{}},
+		{runtime.APIVersionInternal, "Gadget", &gadgets.Gadget{}},
+	} {
+		gvk := schema.GroupVersionKind{Group: "things.myorg.io", Version: tc.version, Kind: tc.kind}
+		obj, err := Scheme.New(gvk)
+		if err != nil {
+			t.Errorf("Failed to create %v from scheme: %v", gvk, err)
+			continue
+		}
+		if reflect.TypeOf(obj) != reflect.TypeOf(tc.want) {
+			t.Errorf("Expected %T for %v, got %T", tc.want, gvk, obj)
+		}
 	}
 }
 
@@ -116,7 +120,7 @@ func TestGadgetREST_Interfaces(t *testing.T) {
 
 func TestWidgetREST_CRUD(t *testing.T) {
 	rest := widgets.NewWidgetREST()
-	ctx := context.Background()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
 
 	// Test Create
 	widget := &widgets.Widget{
@@ -127,7 +131,7 @@ func TestWidgetREST_CRUD(t *testing.T) {
 		Spec: widgets.WidgetSpec{
 			Name:        "Test Widget",
 			Description: "A test widget",
-			Size:        42,
+			Size:        resource.MustParse("42"),
 		},
 	}
 
@@ -203,7 +207,7 @@ func TestWidgetREST_CRUD(t *testing.T) {
 
 func TestGadgetREST_CRUD(t *testing.T) {
 	rest := gadgets.NewGadgetREST()
-	ctx := context.Background()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
 
 	// Test Create
 	gadget := &gadgets.Gadget{
@@ -213,7 +217,7 @@ func TestGadgetREST_CRUD(t *testing.T) {
 		},
 		Spec: gadgets.GadgetSpec{
 			Type:     "sensor",
-			Version:  "v1.0",
+			Version:  "1.0.0",
 			Enabled:  true,
 			Priority: 10,
 		},
@@ -314,10 +318,13 @@ func TestDeepCopy(t *testing.T) {
 		Spec: widgets.WidgetSpec{
 			Name:        "Test Widget",
 			Description: "A test widget",
-			Size:        42,
+			Size:        resource.MustParse("42"),
 		},
 		Status: widgets.WidgetStatus{
 			Phase: "Active",
+			Conditions: []metav1.Condition{
+				{Type: "Ready", Status: metav1.ConditionTrue},
+			},
 		},
 	}
 
@@ -335,6 +342,11 @@ func TestDeepCopy(t *testing.T) {
 		t.Error("DeepCopy should preserve spec fields")
 	}
 
+	copiedWidget.Status.Conditions[0].Status = metav1.ConditionFalse
+	if widget.Status.Conditions[0].Status != metav1.ConditionTrue {
+		t.Error("DeepCopy should copy conditions")
+	}
+
 	// Test Gadget DeepCopy
 	gadget := &gadgets.Gadget{
 		ObjectMeta: metav1.ObjectMeta{
@@ -343,12 +355,15 @@ func TestDeepCopy(t *testing.T) {
 		},
 		Spec: gadgets.GadgetSpec{
 			Type:     "sensor",
-			Version:  "v1.0",
+			Version:  "1.0.0",
 			Enabled:  true,
 			Priority: 10,
 		},
 		Status: gadgets.GadgetStatus{
 			State: "Active",
+			Conditions: []metav1.Condition{
+				{Type: "Ready", Status: metav1.ConditionTrue},
+			},
 		},
 	}
 
@@ -365,4 +380,102 @@ func TestDeepCopy(t *testing.T) {
 	if copiedGadget.Spec.Priority != gadget.Spec.Priority {
 		t.Error("DeepCopy should preserve spec fields")
 	}
+
+	copiedGadget.Status.Conditions[0].Status = metav1.ConditionFalse
+	if gadget.Status.Conditions[0].Status != metav1.ConditionTrue {
+		t.Error("DeepCopy should copy conditions")
+	}
+}
+
+func TestNewStorage_InMemoryWithoutEtcd(t *testing.T) {
+	widgetStorage, gadgetStorage, err := newStorage(nil, nil, defaultStorageVersion)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer widgetStorage.Destroy()
+	defer gadgetStorage.Destroy()
+
+	if _, ok := widgetStorage.(*widgets.MemoryStorage); !ok {
+		t.Errorf("Expected in-memory widget storage, got %T", widgetStorage)
+	}
+	if _, ok := gadgetStorage.(*gadgets.GadgetStorage); !ok {
+		t.Errorf("Expected in-memory gadget storage, got %T", gadgetStorage)
+	}
+}
+
+func TestNewStorage_SharedResourceVersions(t *testing.T) {
+	fileOptions := store.NewFileOptions()
+	fileOptions.Dir = t.TempDir()
+	ctx := context.Background()
+
+	widgetStorage, gadgetStorage, err := newStorage(nil, fileOptions, defaultStorageVersion)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	widget, err := widgetStorage.Create(ctx, &widgets.Widget{ObjectMeta: metav1.ObjectMeta{Name: "w", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	gadget, err := gadgetStorage.Create(ctx, &gadgets.Gadget{ObjectMeta: metav1.ObjectMeta{Name: "g", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	if widget.ResourceVersion != "1" || gadget.ResourceVersion != "2" {
+		t.Errorf("Expected resourceVersions 1 and 2 from one clock, got %s and %s",
+			widget.ResourceVersion, gadget.ResourceVersion)
+	}
+
+	list, err := widgetStorage.List(ctx, "default", &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list widgets: %v", err)
+	}
+	if list.ResourceVersion != "2" {
+		t.Errorf("Expected list resourceVersion 2, got %s", list.ResourceVersion)
+	}
+
+	widgetStorage.Destroy()
+	gadgetStorage.Destroy()
+
+	// After a restart the clock continues past the newest revision of any resource
+	widgetStorage, gadgetStorage, err = newStorage(nil, fileOptions, defaultStorageVersion)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer widgetStorage.Destroy()
+	defer gadgetStorage.Destroy()
+
+	widget, err = widgetStorage.Create(ctx, &widgets.Widget{ObjectMeta: metav1.ObjectMeta{Name: "w2", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	if widget.ResourceVersion != "3" {
+		t.Errorf("Expected resourceVersion 3 after restart, got %s", widget.ResourceVersion)
+	}
+}
+
+func TestSchemeFieldLabelConversion(t *testing.T) {
+	gv := schema.GroupVersion{Group: "things.myorg.io", Version: "v1alpha1"}
+	for _, tc := range []struct {
+		kind  string
+		label string
+		valid bool
+	}{
+		{"Widget", "metadata.name", true},
+		{"Widget", "spec.size", true},
+		{"Widget", "status.phase", true},
+		{"Widget", "spec.description", false},
+		{"Gadget", "metadata.namespace", true},
+		{"Gadget", "spec.enabled", true},
+		{"Gadget", "status.state", true},
+		{"Gadget", "spec.priority", false},
+	} {
+		_, _, err := Scheme.ConvertFieldLabel(gv.WithKind(tc.kind), tc.label, "x")
+		if tc.valid && err != nil {
+			t.Errorf("Expected %s to be selectable on %s: %v", tc.label, tc.kind, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("Expected %s to be rejected on %s", tc.label, tc.kind)
+		}
+	}
 }
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/generic"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/klog/v2"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+	"example.com/mytest-apiserver/pkg/store"
+)
+
+// migrateCommand runs the binary as a storage version migration instead of a server:
+//
+//	mytest-apiserver migrate-storage --storage-version=v1beta1 --storage-dir=/var/lib/mytest-apiserver
+const migrateCommand = "migrate-storage"
+
+const (
+	// migrationChunkSize is how many objects are listed at a time; progress is saved after each chunk
+	migrationChunkSize = 500
+
+	// maxMigrationAttempts is how often an object that keeps changing is read and rewritten again
+	maxMigrationAttempts = 5
+
+	// defaultMigrationStateFile is where file backed storage keeps the migration state, in its directory
+	defaultMigrationStateFile = "storage-migration.json"
+)
+
+// resourceMigration reads and rewrites the stored objects of one resource
+type resourceMigration struct {
+	resource string
+	list     func(ctx context.Context, options *internalversion.ListOptions) (runtime.Object, error)
+	get      func(ctx context.Context, namespace, name string) (runtime.Object, error)
+	update   func(ctx context.Context, obj runtime.Object) error
+}
+
+func storageMigrations(widgetStorage widgets.Storage, gadgetStorage gadgets.Storage) []resourceMigration {
+	return []resourceMigration{
+		{
+			resource: "widgets",
+			list: func(ctx context.Context, options *internalversion.ListOptions) (runtime.Object, error) {
+				list, err := widgetStorage.List(ctx, "", options)
+				if err != nil {
+					return nil, err
+				}
+				return list, nil
+			},
+			get: func(ctx context.Context, namespace, name string) (runtime.Object, error) {
+				widget, err := widgetStorage.Get(ctx, namespace, name)
+				if err != nil {
+					return nil, err
+				}
+				return widget, nil
+			},
+			update: func(ctx context.Context, obj runtime.Object) error {
+				_, err := widgetStorage.Update(ctx, obj.(*widgets.Widget))
+				return err
+			},
+		},
+		{
+			resource: "gadgets",
+			list: func(ctx context.Context, options *internalversion.ListOptions) (runtime.Object, error) {
+				list, err := gadgetStorage.List(ctx, "", options)
+				if err != nil {
+					return nil, err
+				}
+				return list, nil
+			},
+			get: func(ctx context.Context, namespace, name string) (runtime.Object, error) {
+				gadget, err := gadgetStorage.Get(ctx, namespace, name)
+				if err != nil {
+					return nil, err
+				}
+				return gadget, nil
+			},
+			update: func(ctx context.Context, obj runtime.Object) error {
+				_, err := gadgetStorage.Update(ctx, obj.(*gadgets.Gadget))
+				return err
+			},
+		},
+	}
+}
+
+// migrateStorage rewrites every stored object of migrations, which persists it in the storage
+// version of their storage, recording its progress in state
+func migrateStorage(ctx context.Context, state *store.MigrationState, migrations []resourceMigration) error {
+	for _, m := range migrations {
+		if err := m.run(ctx, state); err != nil {
+			return fmt.Errorf("migrating %s: %w", m.resource, err)
+		}
+	}
+	return nil
+}
+
+// run rewrites the objects of the resource in key order, starting after the last one state
+// records as rewritten. Progress is saved after every chunk and when the migration stops.
+func (m resourceMigration) run(ctx context.Context, state *store.MigrationState) (err error) {
+	progress := state.Resource(m.resource)
+	if progress.Complete {
+		klog.Infof("Skipping %s, already migrated to %s", m.resource, state.StorageVersion)
+		return nil
+	}
+	if progress.LastKey != "" {
+		klog.Infof("Resuming migration of %s to %s after %s", m.resource, state.StorageVersion, progress.LastKey)
+	}
+	defer func() {
+		if saveErr := state.Save(); err == nil {
+			err = saveErr
+		}
+	}()
+
+	for !progress.Complete {
+		options := &internalversion.ListOptions{Limit: migrationChunkSize}
+		if progress.LastKey != "" {
+			// Every chunk is read at the latest revision, so resuming never depends on a
+			// continue token that may have expired
+			if options.Continue, err = storage.EncodeContinue("/"+progress.LastKey+"\x00", "/", -1); err != nil {
+				return err
+			}
+		}
+		list, err := m.list(ctx, options)
+		if err != nil {
+			return err
+		}
+		objects, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		listMeta, err := meta.ListAccessor(list)
+		if err != nil {
+			return err
+		}
+		total := progress.Migrated + int64(len(objects))
+		if remaining := listMeta.GetRemainingItemCount(); remaining != nil {
+			total += *remaining
+		}
+
+		for _, obj := range objects {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			key, err := m.migrateObject(ctx, obj)
+			if err != nil {
+				return err
+			}
+			progress.LastKey = key
+			progress.Migrated++
+		}
+		progress.Complete = listMeta.GetContinue() == ""
+
+		if err := state.Save(); err != nil {
+			return err
+		}
+		klog.Infof("Migrated %d of %d %s to %s", progress.Migrated, total, m.resource, state.StorageVersion)
+	}
+	return nil
+}
+
+// migrateObject rewrites obj unchanged, returning its key. The resourceVersion it was read at is
+// a precondition, so a concurrent change is never overwritten; the object is read again and
+// rewritten instead.
+func (m resourceMigration) migrateObject(ctx context.Context, obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	namespace, name := accessor.GetNamespace(), accessor.GetName()
+	key := store.Key(namespace, name)
+
+	for attempt := 1; ; attempt++ {
+		err := m.update(ctx, obj)
+		switch {
+		case err == nil, errors.IsNotFound(err):
+			// An object deleted since it was read has nothing left to migrate
+			return key, nil
+		case !errors.IsConflict(err) || attempt == maxMigrationAttempts:
+			return "", err
+		}
+
+		if obj, err = m.get(ctx, namespace, name); err != nil {
+			if errors.IsNotFound(err) {
+				return key, nil
+			}
+			return "", err
+		}
+	}
+}
+
+// runMigration parses the flags of migrateCommand and migrates the stored objects to the
+// configured storage version. The state file is removed once every object is migrated.
+func runMigration(ctx context.Context, args []string) error {
+	fs := pflag.NewFlagSet(migrateCommand, pflag.ContinueOnError)
+	etcdOptions := genericoptions.NewEtcdOptions(storagebackend.NewDefaultConfig(defaultEtcdPathPrefix, nil))
+	fileOptions := store.NewFileOptions()
+	version := defaultStorageVersion.Version
+	var statePath string
+
+	etcdOptions.AddFlags(fs)
+	fileOptions.AddFlags(fs)
+	addStorageVersionFlag(fs, &version)
+	fs.StringVar(&statePath, "migration-state", statePath,
+		"File recording the progress of the migration, so that an interrupted one resumes where it stopped. "+
+			"Defaults to "+defaultMigrationStateFile+" in --storage-dir; required with --etcd-servers.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	gv, err := storageVersion(version)
+	if err != nil {
+		return err
+	}
+	if errs := fileOptions.Validate(); len(errs) != 0 {
+		return fmt.Errorf("invalid storage options: %v", errs)
+	}
+
+	var optsGetter generic.RESTOptionsGetter
+	switch {
+	case len(etcdOptions.StorageConfig.Transport.ServerList) != 0:
+		if fileOptions.Dir != "" {
+			return fmt.Errorf("--storage-dir cannot be combined with --etcd-servers")
+		}
+		if statePath == "" {
+			return fmt.Errorf("--migration-state is required with --etcd-servers")
+		}
+		if errs := etcdOptions.Validate(); len(errs) != 0 {
+			return fmt.Errorf("invalid etcd options: %v", errs)
+		}
+		etcdOptions.StorageConfig.Codec = storageCodec(gv)
+		etcdOptions.StorageConfig.EncodeVersioner = gv
+		etcdOptions.EnableWatchCache = false
+		optsGetter = etcdOptions.CreateRESTOptionsGetter(
+			&genericoptions.SimpleStorageFactory{StorageConfig: etcdOptions.StorageConfig}, nil)
+	case fileOptions.Dir != "":
+		if statePath == "" {
+			statePath = filepath.Join(fileOptions.Dir, defaultMigrationStateFile)
+		}
+	default:
+		return fmt.Errorf("objects are only persisted with --etcd-servers or --storage-dir")
+	}
+
+	widgetStorage, gadgetStorage, err := newStorage(optsGetter, fileOptions, gv)
+	if err != nil {
+		return err
+	}
+	defer widgetStorage.Destroy()
+	defer gadgetStorage.Destroy()
+
+	state, err := store.LoadMigrationState(statePath, gv.String())
+	if err != nil {
+		return err
+	}
+	klog.Infof("Migrating stored objects to %s", gv)
+	if err := migrateStorage(ctx, state, storageMigrations(widgetStorage, gadgetStorage)); err != nil {
+		return err
+	}
+	klog.Infof("Migrated every stored object to %s", gv)
+	return state.Remove()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+	v1beta1widgets "example.com/mytest-apiserver/pkg/apis/widgets/v1beta1"
+	"example.com/mytest-apiserver/pkg/store"
+)
+
+// newMigrationTestStorage returns file backed storage in dir holding widgets a, b and c and
+// gadget g, persisted as v1alpha1
+func newMigrationTestStorage(t *testing.T, dir string) (widgets.Storage, gadgets.Storage) {
+	t.Helper()
+	fileOptions := store.NewFileOptions()
+	fileOptions.Dir = dir
+	fileOptions.CompactionInterval = 0
+
+	widgetStorage, gadgetStorage, err := newStorage(nil, fileOptions, defaultStorageVersion)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := widgetStorage.Create(ctx, &widgets.Widget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       widgets.WidgetSpec{Name: name, Size: resource.MustParse("1500m")},
+		}); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+	if _, err := gadgetStorage.Create(ctx, &gadgets.Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "g", Namespace: "default"},
+		Spec:       gadgets.GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	return widgetStorage, gadgetStorage
+}
+
+// resourceVersions returns the resourceVersion of every stored widget by name
+func resourceVersions(t *testing.T, storage widgets.Storage) map[string]string {
+	t.Helper()
+	list, err := storage.List(context.Background(), "", nil)
+	if err != nil {
+		t.Fatalf("Failed to list widgets: %v", err)
+	}
+	versions := make(map[string]string)
+	for _, widget := range list.Items {
+		versions[widget.Name] = widget.ResourceVersion
+	}
+	return versions
+}
+
+func TestRunMigration_FileStorage(t *testing.T) {
+	dir := t.TempDir()
+	widgetStorage, gadgetStorage := newMigrationTestStorage(t, dir)
+	before := resourceVersions(t, widgetStorage)
+	widgetStorage.Destroy()
+	gadgetStorage.Destroy()
+
+	if err := runMigration(context.Background(), []string{"--storage-dir=" + dir, "--storage-version=v1beta1"}); err != nil {
+		t.Fatalf("Failed to migrate storage: %v", err)
+	}
+
+	for _, resource := range []string{"widgets", "gadgets"} {
+		data, err := os.ReadFile(filepath.Join(dir, resource, "snapshot.json"))
+		if err != nil {
+			t.Fatalf("Failed to read %s snapshot: %v", resource, err)
+		}
+		if strings.Contains(string(data), "v1alpha1") || !strings.Contains(string(data), "things.myorg.io/v1beta1") {
+			t.Errorf("Expected %s to be persisted as v1beta1 only, got %s", resource, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultMigrationStateFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the migration state to be removed once complete, got %v", err)
+	}
+
+	// Every widget was rewritten, so it has a new resourceVersion
+	fileOptions := store.NewFileOptions()
+	fileOptions.Dir = dir
+	widgetStorage, gadgetStorage, err := newStorage(nil, fileOptions, v1beta1widgets.SchemeGroupVersion)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer widgetStorage.Destroy()
+	defer gadgetStorage.Destroy()
+	for name, resourceVersion := range resourceVersions(t, widgetStorage) {
+		if resourceVersion == before[name] {
+			t.Errorf("Expected widget %s to be rewritten, still at resourceVersion %s", name, resourceVersion)
+		}
+	}
+}
+
+func TestRunMigration_InvalidOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []string
+	}{
+		{"no persistent storage", []string{"--storage-version=v1beta1"}},
+		{"unserved version", []string{"--storage-dir=" + t.TempDir(), "--storage-version=v1"}},
+		{"etcd without state file", []string{"--etcd-servers=http://127.0.0.1:2379"}},
+		{"etcd and storage dir", []string{"--etcd-servers=http://127.0.0.1:2379", "--storage-dir=" + t.TempDir()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := runMigration(context.Background(), tc.args); err == nil {
+				t.Errorf("Expected %v to be rejected", tc.args)
+			}
+		})
+	}
+}
+
+func TestMigrateStorage_ResumesAfterInterruption(t *testing.T) {
+	dir := t.TempDir()
+	widgetStorage, gadgetStorage := newMigrationTestStorage(t, dir)
+	defer widgetStorage.Destroy()
+	defer gadgetStorage.Destroy()
+	before := resourceVersions(t, widgetStorage)
+
+	statePath := filepath.Join(dir, defaultMigrationStateFile)
+	state, err := store.LoadMigrationState(statePath, "things.myorg.io/v1beta1")
+	if err != nil {
+		t.Fatalf("Failed to load migration state: %v", err)
+	}
+
+	// Interrupt the migration once widget a is rewritten
+	ctx, cancel := context.WithCancel(context.Background())
+	migrations := storageMigrations(widgetStorage, gadgetStorage)
+	update := migrations[0].update
+	migrations[0].update = func(ctx context.Context, obj runtime.Object) error {
+		defer cancel()
+		return update(ctx, obj)
+	}
+	if err := migrateStorage(ctx, state, migrations); err == nil {
+		t.Fatal("Expected the interrupted migration to fail")
+	}
+
+	state, err = store.LoadMigrationState(statePath, "things.myorg.io/v1beta1")
+	if err != nil {
+		t.Fatalf("Failed to reload migration state: %v", err)
+	}
+	if progress := state.Resource("widgets"); progress.LastKey != "default/a" || progress.Migrated != 1 {
+		t.Fatalf("Expected the progress up to widget a to be saved, got %+v", progress)
+	}
+	interrupted := resourceVersions(t, widgetStorage)
+
+	if err := migrateStorage(context.Background(), state, storageMigrations(widgetStorage, gadgetStorage)); err != nil {
+		t.Fatalf("Failed to resume migration: %v", err)
+	}
+	after := resourceVersions(t, widgetStorage)
+	if after["a"] != interrupted["a"] {
+		t.Errorf("Expected widget a not to be rewritten again, got resourceVersion %s after %s", after["a"], interrupted["a"])
+	}
+	for _, name := range []string{"b", "c"} {
+		if after[name] == before[name] {
+			t.Errorf("Expected widget %s to be rewritten on resume", name)
+		}
+	}
+	if progress := state.Resource("widgets"); !progress.Complete || progress.Migrated != 3 {
+		t.Errorf("Expected all 3 widgets to be migrated, got %+v", progress)
+	}
+	if progress := state.Resource("gadgets"); !progress.Complete || progress.Migrated != 1 {
+		t.Errorf("Expected the gadget to be migrated, got %+v", progress)
+	}
+}
+
+func TestMigrateStorage_ConcurrentChanges(t *testing.T) {
+	dir := t.TempDir()
+	widgetStorage, gadgetStorage := newMigrationTestStorage(t, dir)
+	defer widgetStorage.Destroy()
+	defer gadgetStorage.Destroy()
+	ctx := context.Background()
+
+	state, err := store.LoadMigrationState(filepath.Join(dir, defaultMigrationStateFile), "things.myorg.io/v1beta1")
+	if err != nil {
+		t.Fatalf("Failed to load migration state: %v", err)
+	}
+
+	// Widget a changes after it is listed and widget b is deleted, as by a running server
+	migrations := storageMigrations(widgetStorage, gadgetStorage)
+	update := migrations[0].update
+	changed := false
+	migrations[0].update = func(ctx context.Context, obj runtime.Object) error {
+		if !changed {
+			changed = true
+			widget, err := widgetStorage.Get(ctx, "default", "a")
+			if err != nil {
+				return err
+			}
+			widget.Spec.Description = "changed during migration"
+			if _, err := widgetStorage.Update(ctx, widget); err != nil {
+				return err
+			}
+			if err := widgetStorage.Delete(ctx, "default", "b", nil); err != nil {
+				return err
+			}
+		}
+		return update(ctx, obj)
+	}
+	if err := migrateStorage(ctx, state, migrations); err != nil {
+		t.Fatalf("Failed to migrate storage: %v", err)
+	}
+
+	widget, err := widgetStorage.Get(ctx, "default", "a")
+	if err != nil {
+		t.Fatalf("Failed to get widget: %v", err)
+	}
+	if widget.Spec.Description != "changed during migration" {
+		t.Errorf("Expected the concurrent change to be kept, got %q", widget.Spec.Description)
+	}
+	if progress := state.Resource("widgets"); !progress.Complete || progress.Migrated != 3 {
+		t.Errorf("Expected all 3 widgets to be accounted for, got %+v", progress)
+	}
+}
@@ -0,0 +1,109 @@
+// Package uniquegadgettype allows one enabled gadget of each type per namespace
+package uniquegadgettype
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/admission"
+
+	"example.com/mytest-apiserver/pkg/admission/pluginconfig"
+	"example.com/mytest-apiserver/pkg/admission/thingsinitializer"
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+)
+
+// PluginName is the name the plugin is enabled and configured by
+const PluginName = "UniqueGadgetType"
+
+// ConfigurationKind is the kind of the configuration of the plugin
+const ConfigurationKind = "UniqueGadgetTypeConfiguration"
+
+// Configuration selects the gadget types the plugin applies to
+type Configuration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Types are the gadget types of which one gadget per namespace may be enabled. Without
+	// them, every type is.
+	Types []string `json:"types,omitempty"`
+}
+
+// Register registers the plugin. Without a configuration it applies to every gadget type.
+func Register(plugins *admission.Plugins) {
+	plugins.Register(PluginName, func(config io.Reader) (admission.Interface, error) {
+		var configuration Configuration
+		if config != nil {
+			if err := pluginconfig.Decode(config, ConfigurationKind, &configuration); err != nil {
+				return nil, fmt.Errorf("reading the configuration of %s: %w", PluginName, err)
+			}
+		}
+		return New(configuration), nil
+	})
+}
+
+// Plugin rejects enabling a gadget if another gadget of the same type is enabled in its
+// namespace. Gadgets admitted at the same time are not checked against each other.
+type Plugin struct {
+	*admission.Handler
+	types  sets.Set[string]
+	lister gadgets.GadgetLister
+}
+
+var _ admission.ValidationInterface = &Plugin{}
+var _ thingsinitializer.WantsGadgetLister = &Plugin{}
+
+// New returns the plugin applying to the gadget types of configuration
+func New(configuration Configuration) *Plugin {
+	return &Plugin{
+		Handler: admission.NewHandler(admission.Create, admission.Update),
+		types:   sets.New(configuration.Types...),
+	}
+}
+
+// SetGadgetLister sets the lister the other gadgets of a namespace are looked up with
+func (p *Plugin) SetGadgetLister(lister gadgets.GadgetLister) {
+	p.lister = lister
+}
+
+// ValidateInitialization checks the plugin was handed a lister
+func (p *Plugin) ValidateInitialization() error {
+	if p.lister == nil {
+		return fmt.Errorf("%s needs a gadget lister", PluginName)
+	}
+	return nil
+}
+
+// Validate rejects enabled gadgets whose type is already enabled in their namespace. Gadgets
+// that were already enabled with the same type are admitted, so that conflicts from before the
+// plugin was enabled do not block their updates.
+func (p *Plugin) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	if a.GetResource().GroupResource() != gadgets.Resource("gadgets") || a.GetSubresource() != "" {
+		return nil
+	}
+	gadget, ok := a.GetObject().(*gadgets.Gadget)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a gadget, got %T", a.GetObject()))
+	}
+	if !gadget.Spec.Enabled || (p.types.Len() != 0 && !p.types.Has(gadget.Spec.Type)) {
+		return nil
+	}
+	if old, ok := a.GetOldObject().(*gadgets.Gadget); ok && old.Spec.Enabled && old.Spec.Type == gadget.Spec.Type {
+		return nil
+	}
+
+	others, err := p.lister.Gadgets(a.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	for _, other := range others {
+		if other.Name != gadget.Name && other.Spec.Enabled && other.Spec.Type == gadget.Spec.Type {
+			return admission.NewForbidden(a, fmt.Errorf("gadget %s is already an enabled %s gadget in namespace %s",
+				other.Name, gadget.Spec.Type, a.GetNamespace()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package uniquegadgettype
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/admission"
+
+	"example.com/mytest-apiserver/pkg/admission/thingsinitializer"
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+)
+
+func gadget(name, typ string, enabled bool) *gadgets.Gadget {
+	return &gadgets.Gadget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       gadgets.GadgetSpec{Type: typ, Version: "1.0.0", Enabled: enabled},
+	}
+}
+
+// newPlugin returns the plugin configured by config, listing gadgets from storage holding existing
+func newPlugin(t *testing.T, config string, existing ...*gadgets.Gadget) (admission.ValidationInterface, error) {
+	t.Helper()
+	storage := gadgets.NewGadgetStorage()
+	for _, g := range existing {
+		if _, err := storage.Create(context.Background(), g); err != nil {
+			t.Fatalf("Failed to create gadget: %v", err)
+		}
+	}
+	plugins := admission.NewPlugins()
+	Register(plugins)
+	var reader io.Reader
+	if config != "" {
+		reader = strings.NewReader(config)
+	}
+	plugin, err := plugins.InitPlugin(PluginName, reader, thingsinitializer.New(nil, gadgets.NewLister(storage), nil))
+	if err != nil {
+		return nil, err
+	}
+	return plugin.(admission.ValidationInterface), nil
+}
+
+// attributes creates obj, or updates old to obj
+func attributes(obj, old *gadgets.Gadget) admission.Attributes {
+	operation, oldObj := admission.Create, runtime.Object(nil)
+	if old != nil {
+		operation, oldObj = admission.Update, old
+	}
+	return admission.NewAttributesRecord(obj, oldObj, gadgets.SchemeGroupVersion.WithKind("Gadget"), obj.Namespace, obj.Name,
+		gadgets.SchemeGroupVersion.WithResource("gadgets"), "", operation, nil, false, nil)
+}
+
+func TestValidate(t *testing.T) {
+	existing := []*gadgets.Gadget{gadget("sensor", "sensor", true), gadget("spare", "sensor", false),
+		gadget("actuator", "actuator", true)}
+
+	for _, tc := range []struct {
+		name      string
+		config    string
+		obj, old  *gadgets.Gadget
+		forbidden bool
+	}{
+		{name: "enabled type taken", obj: gadget("new", "sensor", true), forbidden: true},
+		{name: "disabled", obj: gadget("new", "sensor", false)},
+		{name: "enabled type free", obj: gadget("new", "display", true)},
+		{name: "enabling", obj: gadget("spare", "sensor", true), old: gadget("spare", "sensor", false), forbidden: true},
+		{name: "changing type", obj: gadget("actuator", "sensor", true), old: gadget("actuator", "actuator", true), forbidden: true},
+		{name: "updating the enabled gadget", obj: gadget("sensor", "sensor", true), old: gadget("sensor", "sensor", true)},
+		{
+			name:   "type not configured",
+			config: "apiVersion: admission.things.myorg.io/v1alpha1\nkind: UniqueGadgetTypeConfiguration\ntypes: [actuator]\n",
+			obj:    gadget("new", "sensor", true),
+		},
+		{
+			name:      "type configured",
+			config:    "apiVersion: admission.things.myorg.io/v1alpha1\nkind: UniqueGadgetTypeConfiguration\ntypes: [actuator]\n",
+			obj:       gadget("new", "actuator", true),
+			forbidden: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plugin, err := newPlugin(t, tc.config, existing...)
+			if err != nil {
+				t.Fatalf("Failed to create plugin: %v", err)
+			}
+			err = plugin.Validate(context.Background(), attributes(tc.obj, tc.old), nil)
+			if tc.forbidden != apierrors.IsForbidden(err) || (!tc.forbidden && err != nil) {
+				t.Errorf("Expected forbidden %t, got %v", tc.forbidden, err)
+			}
+		})
+	}
+}
+
+func TestRegister_InvalidConfiguration(t *testing.T) {
+	_, err := newPlugin(t, "apiVersion: admission.things.myorg.io/v1alpha1\nkind: UniqueGadgetTypeConfiguration\ntype: sensor\n")
+	if err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("Expected unknown fields to be rejected, got %v", err)
+	}
+}
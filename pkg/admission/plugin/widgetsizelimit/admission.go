@@ -0,0 +1,137 @@
+// Package widgetsizelimit limits the total spec.size of the widgets in a namespace
+package widgetsizelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apiserver/pkg/admission"
+
+	"example.com/mytest-apiserver/pkg/admission/pluginconfig"
+	"example.com/mytest-apiserver/pkg/admission/thingsinitializer"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+// PluginName is the name the plugin is enabled and configured by
+const PluginName = "WidgetSizeLimit"
+
+// ConfigurationKind is the kind of the configuration of the plugin
+const ConfigurationKind = "WidgetSizeLimitConfiguration"
+
+// Configuration is the limit of the total size of the widgets in each namespace
+type Configuration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Default is the limit of namespaces not listed in Namespaces. Without it they are not
+	// limited.
+	Default *resource.Quantity `json:"default,omitempty"`
+
+	// Namespaces are the limits of particular namespaces
+	Namespaces map[string]resource.Quantity `json:"namespaces,omitempty"`
+}
+
+// Register registers the plugin, which must be configured with the limits it enforces
+func Register(plugins *admission.Plugins) {
+	plugins.Register(PluginName, func(config io.Reader) (admission.Interface, error) {
+		if config == nil {
+			return nil, fmt.Errorf("%s needs a %s in the admission configuration file", PluginName, ConfigurationKind)
+		}
+		var configuration Configuration
+		if err := pluginconfig.Decode(config, ConfigurationKind, &configuration); err != nil {
+			return nil, fmt.Errorf("reading the configuration of %s: %w", PluginName, err)
+		}
+		return New(configuration)
+	})
+}
+
+// Plugin rejects creating a widget, or growing one, if the widgets in its namespace would
+// then add up to more than the limit of the namespace. Widgets admitted at the same time are
+// not counted against each other, so concurrent requests may exceed the limit.
+type Plugin struct {
+	*admission.Handler
+	configuration Configuration
+	lister        widgets.WidgetLister
+}
+
+var _ admission.ValidationInterface = &Plugin{}
+var _ thingsinitializer.WantsWidgetLister = &Plugin{}
+
+// New returns the plugin enforcing the limits of configuration
+func New(configuration Configuration) (*Plugin, error) {
+	if configuration.Default != nil && configuration.Default.Sign() < 0 {
+		return nil, fmt.Errorf("the default limit must be greater than or equal to 0, got %s", configuration.Default)
+	}
+	for namespace, limit := range configuration.Namespaces {
+		if limit.Sign() < 0 {
+			return nil, fmt.Errorf("the limit of namespace %s must be greater than or equal to 0, got %s", namespace, limit.String())
+		}
+	}
+	return &Plugin{
+		Handler:       admission.NewHandler(admission.Create, admission.Update),
+		configuration: configuration,
+	}, nil
+}
+
+// SetWidgetLister sets the lister the widgets of a namespace are added up from
+func (p *Plugin) SetWidgetLister(lister widgets.WidgetLister) {
+	p.lister = lister
+}
+
+// ValidateInitialization checks the plugin was handed a lister
+func (p *Plugin) ValidateInitialization() error {
+	if p.lister == nil {
+		return fmt.Errorf("%s needs a widget lister", PluginName)
+	}
+	return nil
+}
+
+// limit returns the limit of namespace, if it has one
+func (p *Plugin) limit(namespace string) (resource.Quantity, bool) {
+	if limit, ok := p.configuration.Namespaces[namespace]; ok {
+		return limit, true
+	}
+	if p.configuration.Default != nil {
+		return *p.configuration.Default, true
+	}
+	return resource.Quantity{}, false
+}
+
+// Validate rejects widgets that would take their namespace over its limit. Updates that do not
+// grow a widget are always admitted, so a namespace over a lowered limit can still be changed.
+func (p *Plugin) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	if a.GetResource().GroupResource() != widgets.Resource("widgets") || a.GetSubresource() != "" {
+		return nil
+	}
+	widget, ok := a.GetObject().(*widgets.Widget)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a widget, got %T", a.GetObject()))
+	}
+	limit, ok := p.limit(a.GetNamespace())
+	if !ok {
+		return nil
+	}
+	if old, ok := a.GetOldObject().(*widgets.Widget); ok && widget.Spec.Size.Cmp(old.Spec.Size) <= 0 {
+		return nil
+	}
+
+	others, err := p.lister.Widgets(a.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	total := widget.Spec.Size.DeepCopy()
+	for _, other := range others {
+		if other.Name != widget.Name {
+			total.Add(other.Spec.Size)
+		}
+	}
+	if total.Cmp(limit) > 0 {
+		return admission.NewForbidden(a, fmt.Errorf("the widgets in namespace %s would add up to a size of %s, over its limit of %s",
+			a.GetNamespace(), total.String(), limit.String()))
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package widgetsizelimit
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/admission"
+
+	"example.com/mytest-apiserver/pkg/admission/thingsinitializer"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+func widget(namespace, name, size string) *widgets.Widget {
+	return &widgets.Widget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       widgets.WidgetSpec{Name: name, Size: resource.MustParse(size)},
+	}
+}
+
+// newPlugin returns the plugin configured by config, listing widgets from storage holding existing
+func newPlugin(t *testing.T, config string, existing ...*widgets.Widget) (admission.ValidationInterface, error) {
+	t.Helper()
+	storage := widgets.NewMemoryStorage()
+	for _, w := range existing {
+		if _, err := storage.Create(context.Background(), w); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+	plugins := admission.NewPlugins()
+	Register(plugins)
+	var reader io.Reader
+	if config != "" {
+		reader = strings.NewReader(config)
+	}
+	plugin, err := plugins.InitPlugin(PluginName, reader, thingsinitializer.New(widgets.NewLister(storage), nil, nil))
+	if err != nil {
+		return nil, err
+	}
+	return plugin.(admission.ValidationInterface), nil
+}
+
+// attributes creates obj, or updates old to obj
+func attributes(obj, old *widgets.Widget, subresource string) admission.Attributes {
+	operation, oldObj := admission.Create, runtime.Object(nil)
+	if old != nil {
+		operation, oldObj = admission.Update, old
+	}
+	return admission.NewAttributesRecord(obj, oldObj, widgets.SchemeGroupVersion.WithKind("Widget"), obj.Namespace, obj.Name,
+		widgets.SchemeGroupVersion.WithResource("widgets"), subresource, operation, nil, false, nil)
+}
+
+const config = `
+apiVersion: admission.things.myorg.io/v1alpha1
+kind: WidgetSizeLimitConfiguration
+default: "2"
+namespaces:
+  big: "10"
+  frozen: "0"
+`
+
+func TestValidate(t *testing.T) {
+	plugin, err := newPlugin(t, config, widget("big", "a", "6"), widget("default", "a", "1"))
+	if err != nil {
+		t.Fatalf("Failed to create plugin: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		obj, old    *widgets.Widget
+		subresource string
+		forbidden   bool
+	}{
+		{name: "within the namespace limit", obj: widget("big", "b", "4")},
+		{name: "over the namespace limit", obj: widget("big", "b", "4001m"), forbidden: true},
+		{name: "within the default limit", obj: widget("default", "b", "1")},
+		{name: "over the default limit", obj: widget("default", "b", "1500m"), forbidden: true},
+		{name: "zero limit", obj: widget("frozen", "a", "1m"), forbidden: true},
+		{name: "growing within the limit", obj: widget("big", "a", "10"), old: widget("big", "a", "6")},
+		{name: "growing over the limit", obj: widget("default", "a", "3"), old: widget("default", "a", "1"), forbidden: true},
+		{
+			// A namespace over a lowered limit can still be changed
+			name: "not growing over the limit",
+			obj:  widget("frozen", "a", "5"), old: widget("frozen", "a", "5"),
+		},
+		{name: "status", obj: widget("frozen", "a", "5"), old: widget("frozen", "a", "1"), subresource: "status"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := plugin.Validate(context.Background(), attributes(tc.obj, tc.old, tc.subresource), nil)
+			if tc.forbidden != apierrors.IsForbidden(err) || (!tc.forbidden && err != nil) {
+				t.Errorf("Expected forbidden %t, got %v", tc.forbidden, err)
+			}
+		})
+	}
+}
+
+func TestRegister_Configuration(t *testing.T) {
+	for _, tc := range []struct {
+		name, config, expected string
+	}{
+		{"missing", "", "needs a WidgetSizeLimitConfiguration"},
+		{"wrong kind", "apiVersion: admission.things.myorg.io/v1alpha1\nkind: Other\n", "kind WidgetSizeLimitConfiguration"},
+		{"unknown field", "apiVersion: admission.things.myorg.io/v1alpha1\nkind: WidgetSizeLimitConfiguration\nlimit: 1\n", "unknown field"},
+		{"negative", "apiVersion: admission.things.myorg.io/v1alpha1\nkind: WidgetSizeLimitConfiguration\ndefault: -1\n", "greater than or equal to 0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newPlugin(t, tc.config); err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateInitialization(t *testing.T) {
+	plugin, err := New(Configuration{})
+	if err != nil {
+		t.Fatalf("Failed to create plugin: %v", err)
+	}
+	if err := plugin.ValidateInitialization(); err == nil {
+		t.Error("Expected the plugin to need a lister")
+	}
+}
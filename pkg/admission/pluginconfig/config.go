@@ -0,0 +1,32 @@
+// Package pluginconfig reads the configuration of the admission plugins of this server from
+// the admission configuration file
+package pluginconfig
+
+import (
+	"fmt"
+	"io"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// GroupVersion is the apiVersion of the configuration of every plugin of this server
+const GroupVersion = "admission.things.myorg.io/v1alpha1"
+
+// Decode reads the configuration of kind from config into obj, which must inline
+// metav1.TypeMeta. Configurations of another kind or with unknown fields are rejected.
+func Decode(config io.Reader, kind string, obj interface{}) error {
+	data, err := io.ReadAll(config)
+	if err != nil {
+		return err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return err
+	}
+	if typeMeta.APIVersion != GroupVersion || typeMeta.Kind != kind {
+		return fmt.Errorf("expected a configuration of apiVersion %s and kind %s, got %q and %q",
+			GroupVersion, kind, typeMeta.APIVersion, typeMeta.Kind)
+	}
+	return yaml.UnmarshalStrict(data, obj)
+}
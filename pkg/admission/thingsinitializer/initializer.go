@@ -0,0 +1,53 @@
+// Package thingsinitializer hands admission plugins what they need from this server, such as
+// listers of the stored widgets and gadgets and the quota configuration of both
+package thingsinitializer
+
+import (
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/initializer"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+// WantsWidgetLister is implemented by plugins that look up stored widgets
+type WantsWidgetLister interface {
+	SetWidgetLister(widgets.WidgetLister)
+	admission.InitializationValidator
+}
+
+// WantsGadgetLister is implemented by plugins that look up stored gadgets
+type WantsGadgetLister interface {
+	SetGadgetLister(gadgets.GadgetLister)
+	admission.InitializationValidator
+}
+
+type pluginInitializer struct {
+	widgetLister       widgets.WidgetLister
+	gadgetLister       gadgets.GadgetLister
+	quotaConfiguration quotav1.Configuration
+}
+
+var _ admission.PluginInitializer = pluginInitializer{}
+
+// New returns an initializer handing widgetLister, gadgetLister and quotaConfiguration to the
+// plugins that want them, such as ResourceQuota for quotaConfiguration
+func New(widgetLister widgets.WidgetLister, gadgetLister gadgets.GadgetLister,
+	quotaConfiguration quotav1.Configuration) admission.PluginInitializer {
+	return pluginInitializer{widgetLister: widgetLister, gadgetLister: gadgetLister, quotaConfiguration: quotaConfiguration}
+}
+
+// Initialize hands plugin the listers and configuration it wants. It must run after the generic
+// initializer, since ResourceQuota needs its stop channel before its quota configuration.
+func (i pluginInitializer) Initialize(plugin admission.Interface) {
+	if wants, ok := plugin.(WantsWidgetLister); ok {
+		wants.SetWidgetLister(i.widgetLister)
+	}
+	if wants, ok := plugin.(WantsGadgetLister); ok {
+		wants.SetGadgetLister(i.gadgetLister)
+	}
+	if wants, ok := plugin.(initializer.WantsQuotaConfiguration); ok {
+		wants.SetQuotaConfiguration(i.quotaConfiguration)
+	}
+}
@@ -1,4 +1,3 @@
-// Package gadgets contains the Gadget API types
-// +k8s:openapi-gen=true
-
+// Package gadgets contains the internal Gadget API types, which the served versions in its
+// subpackages convert to and from, and the storage and REST implementation of gadgets
 package gadgets
@@ -0,0 +1,135 @@
+package gadgets
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/generic"
+
+	"example.com/mytest-apiserver/pkg/store"
+)
+
+var _ Storage = &EtcdStorage{}
+
+// EtcdStorage keeps gadgets in etcd
+// +k8s:openapi-gen=false
+type EtcdStorage struct {
+	etcd *store.Etcd
+}
+
+func NewEtcdStorage(typer runtime.ObjectTyper, optsGetter generic.RESTOptionsGetter) (*EtcdStorage, error) {
+	etcd, err := store.NewEtcd(typer, Resource("gadgets"), "gadget",
+		func() runtime.Object { return &Gadget{} },
+		func() runtime.Object { return &GadgetList{} },
+		GetAttrs, optsGetter)
+	if err != nil {
+		return nil, err
+	}
+	return &EtcdStorage{etcd: etcd}, nil
+}
+
+func (s *EtcdStorage) Get(ctx context.Context, namespace, name string) (*Gadget, error) {
+	gadget := &Gadget{}
+	if err := s.etcd.Get(ctx, namespace, name, gadget); err != nil {
+		return nil, err
+	}
+	return gadget, nil
+}
+
+func (s *EtcdStorage) List(ctx context.Context, namespace string, options *internalversion.ListOptions) (*GadgetList, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &GadgetList{}
+	if err := s.etcd.List(ctx, namespace, predicate, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *EtcdStorage) Create(ctx context.Context, gadget *Gadget) (*Gadget, error) {
+	out := &Gadget{}
+	if err := s.etcd.Create(ctx, gadget, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *EtcdStorage) Update(ctx context.Context, gadget *Gadget) (*Gadget, error) {
+	out := &Gadget{}
+	if err := s.etcd.Update(ctx, gadget, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *EtcdStorage) Delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions) error {
+	return s.etcd.Delete(ctx, namespace, name, preconditions, &Gadget{})
+}
+
+// DeleteCollection removes or updates the selected gadgets one at a time, each conditional on
+// the resourceVersion prepare saw, as etcd storage cannot change several objects at once.
+// Gadgets deleted concurrently are skipped. A gadget that fails does not stop the others; the error
+// then names the gadgets that were deleted and those that were not.
+func (s *EtcdStorage) DeleteCollection(ctx context.Context, namespace string, options *internalversion.ListOptions,
+	prepare func(*Gadget) (store.DeleteAction, error)) (*GadgetList, error) {
+	list, err := s.List(ctx, namespace, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decide on every gadget before changing any
+	actions := make([]store.DeleteAction, len(list.Items))
+	for i := range list.Items {
+		if actions[i], err = prepare(&list.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	deleted := &GadgetList{ListMeta: metav1.ListMeta{ResourceVersion: list.ResourceVersion}}
+	var deletedNames []string
+	var failed []store.DeleteFailure
+	for i := range list.Items {
+		gadget := &list.Items[i]
+		name := gadget.Namespace + "/" + gadget.Name
+		switch actions[i] {
+		case store.RemoveObject:
+			err = s.Delete(ctx, gadget.Namespace, gadget.Name, &metav1.Preconditions{ResourceVersion: &gadget.ResourceVersion})
+		case store.UpdateObject:
+			gadget, err = s.Update(ctx, gadget)
+		default:
+			err = nil
+		}
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, store.DeleteFailure{Name: name, Err: err})
+			continue
+		}
+		deleted.Items = append(deleted.Items, *gadget)
+		deletedNames = append(deletedNames, name)
+	}
+	if len(failed) != 0 {
+		return nil, store.NewPartialDeleteError(Resource("gadgets"), deletedNames, failed)
+	}
+	return deleted, nil
+}
+
+func (s *EtcdStorage) Watch(ctx context.Context, namespace string, options *internalversion.ListOptions) (watch.Interface, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+	return s.etcd.Watch(ctx, namespace, predicate, options)
+}
+
+func (s *EtcdStorage) Destroy() {
+	s.etcd.Destroy()
+}
@@ -3,22 +3,29 @@ package gadgets
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
-	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/util/dryrun"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 
-	"example.com/mytest-apiserver/pkg/common"
+	"example.com/mytest-apiserver/pkg/store"
 )
 
-// Gadget represents a sample gadget resource
+// Gadget is the internal version of a gadget, which every served version converts to and from
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type Gadget struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -31,11 +38,13 @@ type Gadget struct {
 }
 
 // GadgetSpec defines the desired state of Gadget
+// +k8s:deepcopy-gen=true
 type GadgetSpec struct {
 	// Type specifies the type of gadget
 	Type string `json:"type"`
 
-	// Version specifies the version of the gadget
+	// Version specifies the version of the gadget as a semantic version such as 1.2.3.
+	// v1beta1 serves it broken down into its parts.
 	Version string `json:"version"`
 
 	// Enabled indicates whether the gadget is enabled
@@ -46,12 +55,21 @@ type GadgetSpec struct {
 }
 
 // GadgetStatus defines the observed state of Gadget
+// +k8s:deepcopy-gen=true
 type GadgetStatus struct {
 	// State indicates the current state of the gadget
 	State string `json:"state,omitempty"`
+
+	// ObservedGeneration is the generation of the gadget the status was last reported for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report the observed condition of the gadget, such as Ready or Degraded
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // GadgetList contains a list of Gadget
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type GadgetList struct {
 	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata,omitempty"`
@@ -60,125 +78,419 @@ type GadgetList struct {
 	Items []Gadget `json:"items"`
 }
 
-func (g *Gadget) DeepCopyObject() runtime.Object {
-	return &Gadget{
-		TypeMeta:   g.TypeMeta,
-		ObjectMeta: *g.ObjectMeta.DeepCopy(),
-		Spec:       g.Spec,
-		Status:     g.Status,
-	}
+// Storage is the backend GadgetREST keeps gadgets in
+type Storage interface {
+	Get(ctx context.Context, namespace, name string) (*Gadget, error)
+	List(ctx context.Context, namespace string, options *internalversion.ListOptions) (*GadgetList, error)
+	Create(ctx context.Context, gadget *Gadget) (*Gadget, error)
+	Update(ctx context.Context, gadget *Gadget) (*Gadget, error)
+	Delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions) error
+	// DeleteCollection calls prepare on a copy of every gadget in namespace, or in all namespaces
+	// if namespace is empty, matching the selectors in options, then removes or updates each as
+	// prepare decided. Nothing changes if prepare fails for any of them. Storage changing them one
+	// at a time goes on after failing to change one and returns a store.NewPartialDeleteError.
+	// prepare is not called while holding locks on the storage, so it may read it.
+	DeleteCollection(ctx context.Context, namespace string, options *internalversion.ListOptions,
+		prepare func(*Gadget) (store.DeleteAction, error)) (*GadgetList, error)
+	Watch(ctx context.Context, namespace string, options *internalversion.ListOptions) (watch.Interface, error)
+	Destroy()
 }
 
-func (gl *GadgetList) DeepCopyObject() runtime.Object {
-	out := &GadgetList{
-		TypeMeta: gl.TypeMeta,
-		ListMeta: gl.ListMeta,
-		Items:    make([]Gadget, len(gl.Items)),
-	}
-	for i := range gl.Items {
-		out.Items[i] = *gl.Items[i].DeepCopyObject().(*Gadget)
-	}
-	return out
-}
+var _ Storage = &GadgetStorage{}
 
+// +k8s:openapi-gen=false
 type GadgetStorage struct {
-	mu             sync.RWMutex
-	gadgets        map[string]*Gadget
-	versionCounter int64
+	mu          sync.RWMutex
+	gadgets     map[string]*Gadget
+	clock       *store.Clock
+	broadcaster *store.Broadcaster
+	snapshots   *store.Snapshots
+
+	// journal persists every change when the storage is file backed
+	journal *store.Journal
+	stopCh  chan struct{}
+
+	// destroyOnce guards Destroy, which the server calls once for every served version
+	destroyOnce sync.Once
 }
 
+// NewGadgetStorage returns in-memory storage with its own revision clock
 func NewGadgetStorage() *GadgetStorage {
+	return NewGadgetStorageWithClock(store.NewClock())
+}
+
+// NewGadgetStorageWithClock returns in-memory storage taking resourceVersions from clock
+func NewGadgetStorageWithClock(clock *store.Clock) *GadgetStorage {
 	return &GadgetStorage{
-		gadgets:        make(map[string]*Gadget),
-		versionCounter: 1,
+		gadgets: make(map[string]*Gadget),
+		clock:   clock,
+		broadcaster: store.NewBroadcaster(Resource("gadgets"),
+			func() runtime.Object { return &Gadget{} }),
+		snapshots: store.NewSnapshots(),
+		stopCh:    make(chan struct{}),
 	}
 }
 
-func (s *GadgetStorage) Get(name string) (*Gadget, error) {
+// NewFileStorage returns storage that keeps gadgets in memory and persists them in dir encoded
+// with codec, restoring the gadgets and resourceVersions left there by a previous run. The clock
+// is advanced past every revision recovered.
+func NewFileStorage(dir string, options *store.FileOptions, codec runtime.Codec, clock *store.Clock) (*GadgetStorage, error) {
+	journal, state, err := store.OpenJournal(dir, codec, options.JournalOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewGadgetStorageWithClock(clock)
+	s.journal = journal
+	for _, obj := range state.Objects {
+		gadget := obj.(*Gadget)
+		s.gadgets[store.Key(gadget.Namespace, gadget.Name)] = gadget
+	}
+	clock.Advance(state.Revision)
+	// Events from before the restart are gone, so watches starting before it have to relist
+	s.broadcaster.ExpireThrough(state.Revision)
+
+	if options.CompactionInterval > 0 {
+		go wait.Until(func() {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			if err := s.compact(); err != nil {
+				klog.Errorf("Failed to compact gadget storage: %v", err)
+			}
+		}, options.CompactionInterval, s.stopCh)
+	}
+	return s, nil
+}
+
+func (s *GadgetStorage) Get(ctx context.Context, namespace, name string) (*Gadget, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	gadget, exists := s.gadgets[name]
+	gadget, exists := s.gadgets[store.Key(namespace, name)]
 	if !exists {
-		return nil, errors.NewNotFound(schema.GroupResource{Group: common.GroupName, Resource: "gadgets"}, name)
+		return nil, errors.NewNotFound(Resource("gadgets"), name)
 	}
 	return gadget.DeepCopyObject().(*Gadget), nil
 }
 
-func (s *GadgetStorage) List() (*GadgetList, error) {
+// List returns the gadgets in namespace, or in all namespaces if namespace is empty, that
+// match the selectors in options. Results are ordered by namespace and name and paginated
+// with options.Limit and options.Continue.
+func (s *GadgetStorage) List(ctx context.Context, namespace string, options *internalversion.ListOptions) (*GadgetList, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	// The state is consistent as of the current revision since writes hold the lock
+	page, err := s.snapshots.Paginate(namespace, predicate, s.clock.Current(), func() []runtime.Object {
+		objects := make([]runtime.Object, 0, len(s.gadgets))
+		for _, gadget := range s.gadgets {
+			objects = append(objects, gadget)
+		}
+		return objects
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	list := &GadgetList{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: common.GroupName + "/" + common.APIVersion,
-			Kind:       "GadgetList",
+		ListMeta: metav1.ListMeta{
+			ResourceVersion:    fmt.Sprintf("%d", page.Revision),
+			Continue:           page.Continue,
+			RemainingItemCount: page.RemainingItemCount,
 		},
-		Items: make([]Gadget, 0, len(s.gadgets)),
+		Items: make([]Gadget, 0, len(page.Objects)),
 	}
-
-	for _, gadget := range s.gadgets {
-		list.Items = append(list.Items, *gadget.DeepCopyObject().(*Gadget))
+	for _, obj := range page.Objects {
+		list.Items = append(list.Items, *obj.DeepCopyObject().(*Gadget))
 	}
 
 	return list, nil
 }
 
-func (s *GadgetStorage) Create(gadget *Gadget) (*Gadget, error) {
+func (s *GadgetStorage) Create(ctx context.Context, gadget *Gadget) (*Gadget, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if gadget.Name == "" {
-		gadget.Name = string(uuid.NewUUID())
+	key := store.Key(gadget.Namespace, gadget.Name)
+	if _, exists := s.gadgets[key]; exists {
+		return nil, errors.NewAlreadyExists(Resource("gadgets"), gadget.Name)
 	}
 
-	if _, exists := s.gadgets[gadget.Name]; exists {
-		return nil, fmt.Errorf("gadget %s already exists", gadget.Name)
+	if err := s.put(key, gadget, nil); err != nil {
+		return nil, err
 	}
-
-	now := metav1.NewTime(time.Now())
-	gadget.CreationTimestamp = now
-	gadget.ResourceVersion = fmt.Sprintf("%d", s.versionCounter)
-	s.versionCounter++
-	gadget.UID = uuid.NewUUID()
-	gadget.Status.State = "Active"
-
-	s.gadgets[gadget.Name] = gadget.DeepCopyObject().(*Gadget)
+	s.compactIfNeeded()
 	return gadget, nil
 }
 
-func (s *GadgetStorage) Update(gadget *Gadget) (*Gadget, error) {
+// Update replaces a stored gadget. A non-empty resourceVersion must match the stored one,
+// otherwise the update fails with a conflict.
+func (s *GadgetStorage) Update(ctx context.Context, gadget *Gadget) (*Gadget, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	existing, exists := s.gadgets[gadget.Name]
+	key := store.Key(gadget.Namespace, gadget.Name)
+	existing, exists := s.gadgets[key]
 	if !exists {
-		return nil, errors.NewNotFound(schema.GroupResource{Group: common.GroupName, Resource: "gadgets"}, gadget.Name)
+		return nil, errors.NewNotFound(Resource("gadgets"), gadget.Name)
+	}
+	if gadget.ResourceVersion != "" && gadget.ResourceVersion != existing.ResourceVersion {
+		return nil, store.NewOptimisticLockError(Resource("gadgets"), gadget.Name)
 	}
 
 	gadget.CreationTimestamp = existing.CreationTimestamp
 	gadget.UID = existing.UID
-	gadget.ResourceVersion = fmt.Sprintf("%d", s.versionCounter)
-	s.versionCounter++
-
-	s.gadgets[gadget.Name] = gadget.DeepCopyObject().(*Gadget)
+	if err := s.put(key, gadget, existing); err != nil {
+		return nil, err
+	}
+	s.compactIfNeeded()
 	return gadget, nil
 }
 
-func (s *GadgetStorage) Delete(name string) error {
+// Delete removes a stored gadget if it satisfies preconditions, otherwise it fails with a conflict
+func (s *GadgetStorage) Delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.gadgets[name]; !exists {
-		return errors.NewNotFound(schema.GroupResource{Group: common.GroupName, Resource: "gadgets"}, name)
+	key := store.Key(namespace, name)
+	existing, exists := s.gadgets[key]
+	if !exists {
+		return errors.NewNotFound(Resource("gadgets"), name)
+	}
+	if err := store.CheckPreconditions(Resource("gadgets"),
+		preconditions, existing); err != nil {
+		return err
 	}
 
-	delete(s.gadgets, name)
+	if err := s.remove(key, existing); err != nil {
+		return err
+	}
+	s.compactIfNeeded()
 	return nil
 }
 
+// DeleteCollection runs prepare on copies of the selected gadgets without holding the lock, since it
+// may call admission webhooks or read the gadgets itself, then removes or updates them all under
+// the write lock, so no other change interleaves with them. It fails with a conflict and changes
+// nothing if any of them was changed in between; those deleted in between are skipped.
+func (s *GadgetStorage) DeleteCollection(ctx context.Context, namespace string, options *internalversion.ListOptions,
+	prepare func(*Gadget) (store.DeleteAction, error)) (*GadgetList, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var keys []string
+	for key, gadget := range s.gadgets {
+		if namespace != metav1.NamespaceAll && gadget.Namespace != namespace {
+			continue
+		}
+		if matches, _ := predicate.Matches(gadget); matches {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	selected := make([]*Gadget, len(keys))
+	versions := make([]string, len(keys))
+	for i, key := range keys {
+		selected[i] = s.gadgets[key].DeepCopyObject().(*Gadget)
+		versions[i] = selected[i].ResourceVersion
+	}
+	s.mu.RUnlock()
+
+	// Decide on every gadget before changing any
+	actions := make([]store.DeleteAction, len(keys))
+	for i := range selected {
+		if actions[i], err = prepare(selected[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, key := range keys {
+		if existing, exists := s.gadgets[key]; exists && existing.ResourceVersion != versions[i] {
+			return nil, store.NewOptimisticLockError(Resource("gadgets"), selected[i].Name)
+		}
+	}
+
+	list := &GadgetList{
+		Items: make([]Gadget, 0, len(keys)),
+	}
+	defer s.compactIfNeeded()
+	for i, key := range keys {
+		existing, exists := s.gadgets[key]
+		if !exists {
+			continue
+		}
+		switch actions[i] {
+		case store.RemoveObject:
+			err = s.remove(key, existing)
+		case store.UpdateObject:
+			err = s.put(key, selected[i], existing)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *selected[i])
+	}
+	list.ResourceVersion = fmt.Sprintf("%d", s.clock.Current())
+	return list, nil
+}
+
+// Watch streams changes to the gadgets in namespace, or in all namespaces if namespace is empty,
+// that match the selectors in options. An empty or "0" resourceVersion starts with synthetic ADDED events for every existing
+// gadget; any other resourceVersion resumes after it, failing with 504 Timeout if it is newer than
+// the current one.
+func (s *GadgetStorage) Watch(ctx context.Context, namespace string, options *internalversion.ListOptions) (watch.Interface, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	opts := store.WatchOptions{
+		Revision: s.clock.Current(),
+		Filter: func(obj runtime.Object) bool {
+			if namespace != metav1.NamespaceAll && obj.(*Gadget).Namespace != namespace {
+				return false
+			}
+			matches, _ := predicate.Matches(obj)
+			return matches
+		},
+	}
+
+	sendInitialEvents := options.SendInitialEvents != nil && *options.SendInitialEvents
+	if options.ResourceVersion == "" || options.ResourceVersion == "0" || sendInitialEvents {
+		for _, gadget := range s.gadgets {
+			if !opts.Filter(gadget) {
+				continue
+			}
+			opts.Initial = append(opts.Initial, gadget.DeepCopyObject())
+		}
+		opts.InitialEventsEnd = sendInitialEvents && options.AllowWatchBookmarks
+	} else {
+		revision, err := store.ParseResourceVersion(options.ResourceVersion)
+		if err != nil {
+			return nil, err
+		}
+		// Events up to a revision that has not happened yet would be skipped without notice
+		if revision > opts.Revision {
+			return nil, storage.NewTooLargeResourceVersionError(uint64(revision), uint64(opts.Revision), 1)
+		}
+		opts.Revision = revision
+	}
+
+	return s.broadcaster.Watch(ctx, opts)
+}
+
+func (s *GadgetStorage) Destroy() {
+	s.destroyOnce.Do(s.destroy)
+}
+
+func (s *GadgetStorage) destroy() {
+	s.broadcaster.Shutdown()
+	if s.journal == nil {
+		return
+	}
+
+	close(s.stopCh)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.compact(); err != nil {
+		klog.Errorf("Failed to compact gadget storage: %v", err)
+	}
+	if err := s.journal.Close(); err != nil {
+		klog.Errorf("Failed to close gadget storage journal: %v", err)
+	}
+}
+
+// put stores gadget at a new revision in place of existing, which is nil for a new gadget, and
+// notifies watchers. Callers must hold the write lock.
+func (s *GadgetStorage) put(key string, gadget, existing *Gadget) error {
+	revision := s.clock.Next()
+	gadget.ResourceVersion = fmt.Sprintf("%d", revision)
+	if err := s.persist(revision, key, gadget); err != nil {
+		return err
+	}
+
+	s.gadgets[key] = gadget.DeepCopyObject().(*Gadget)
+	if existing == nil {
+		s.broadcaster.Action(watch.Added, gadget, nil, revision)
+	} else {
+		s.broadcaster.Action(watch.Modified, gadget, existing, revision)
+	}
+	return nil
+}
+
+// remove deletes the stored gadget existing and notifies watchers. Callers must hold the write lock.
+func (s *GadgetStorage) remove(key string, existing *Gadget) error {
+	// Deletions get their own revision so watchers can resume past them
+	revision := s.clock.Next()
+	if err := s.persist(revision, key, nil); err != nil {
+		return err
+	}
+	delete(s.gadgets, key)
+
+	// Stored gadgets are shared with list snapshots and never modified in place
+	deleted := existing.DeepCopyObject().(*Gadget)
+	deleted.ResourceVersion = fmt.Sprintf("%d", revision)
+	s.broadcaster.Action(watch.Deleted, deleted, nil, revision)
+	return nil
+}
+
+// persist logs the change about to be made at revision; a nil gadget is a deletion.
+// Callers must hold the write lock.
+func (s *GadgetStorage) persist(revision int64, key string, gadget *Gadget) error {
+	if s.journal == nil {
+		return nil
+	}
+	var obj runtime.Object
+	if gadget != nil {
+		obj = gadget
+	}
+	if err := s.journal.Append(revision, key, obj); err != nil {
+		return errors.NewInternalError(fmt.Errorf("persisting gadget: %w", err))
+	}
+	return nil
+}
+
+// compactIfNeeded compacts once enough changes have been logged. Callers must hold the write lock.
+func (s *GadgetStorage) compactIfNeeded() {
+	if s.journal == nil || !s.journal.NeedsCompaction() {
+		return
+	}
+	if err := s.compact(); err != nil {
+		klog.Errorf("Failed to compact gadget storage: %v", err)
+	}
+}
+
+// compact snapshots the stored gadgets. Callers must hold the lock so no change is logged meanwhile.
+func (s *GadgetStorage) compact() error {
+	objects := make([]runtime.Object, 0, len(s.gadgets))
+	for _, gadget := range s.gadgets {
+		objects = append(objects, gadget)
+	}
+	return s.journal.Compact(s.clock.Current(), objects)
+}
+
+// maxGenerateNameAttempts is how many names are generated for a gadget before a collision is
+// reported as AlreadyExists
+const maxGenerateNameAttempts = 8
+
+// +k8s:openapi-gen=false
 type GadgetREST struct {
-	storage *GadgetStorage
+	storage  Storage
+	strategy GadgetStrategy
 }
 
 // Ensure GadgetREST implements the required interfaces
@@ -187,12 +499,22 @@ var _ rest.Lister = &GadgetREST{}
 var _ rest.Getter = &GadgetREST{}
 var _ rest.Updater = &GadgetREST{}
 var _ rest.GracefulDeleter = &GadgetREST{}
+var _ rest.CollectionDeleter = &GadgetREST{}
+var _ rest.Watcher = &GadgetREST{}
 var _ rest.Scoper = &GadgetREST{}
 var _ rest.Storage = &GadgetREST{}
+var _ rest.ResetFieldsStrategy = &GadgetREST{}
 
 func NewGadgetREST() *GadgetREST {
+	return NewGadgetRESTWithStorage(NewGadgetStorage(), Strategy)
+}
+
+// NewGadgetRESTWithStorage returns the gadgets in storage, which are created, updated and deleted
+// through strategy
+func NewGadgetRESTWithStorage(storage Storage, strategy GadgetStrategy) *GadgetREST {
 	return &GadgetREST{
-		storage: NewGadgetStorage(),
+		storage:  storage,
+		strategy: strategy,
 	}
 }
 
@@ -205,63 +527,283 @@ func (r *GadgetREST) NewList() runtime.Object {
 }
 
 func (r *GadgetREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
-	return r.storage.Get(name)
+	return r.storage.Get(ctx, genericapirequest.NamespaceValue(ctx), name)
 }
 
 func (r *GadgetREST) List(ctx context.Context, options *internalversion.ListOptions) (runtime.Object, error) {
-	return r.storage.List()
+	return r.storage.List(ctx, genericapirequest.NamespaceValue(ctx), options)
 }
 
 func (r *GadgetREST) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc,
 	options *metav1.CreateOptions) (runtime.Object, error) {
-	gadget := obj.(*Gadget)
-	gadget.TypeMeta = metav1.TypeMeta{
-		APIVersion: common.GroupName + "/" + common.APIVersion,
-		Kind:       "Gadget",
+	gadget, ok := obj.(*Gadget)
+	if !ok {
+		return nil, errors.NewBadRequest(fmt.Sprintf("not a Gadget: %T", obj))
+	}
+	return create(ctx, r.storage, r.strategy, gadget, createValidation,
+		options != nil && dryrun.IsDryRun(options.DryRun))
+}
+
+// create stores a new gadget after running strategy and createValidation on it. A gadget with only
+// a generateName is given a name from it, and another one if that name turns out to be taken.
+// A dry run returns the gadget that would be stored without storing it.
+func create(ctx context.Context, storage Storage, strategy rest.RESTCreateStrategy, gadget *Gadget,
+	createValidation rest.ValidateObjectFunc, dryRun bool) (*Gadget, error) {
+	rest.WipeObjectMetaSystemFields(gadget)
+	rest.FillObjectMetaSystemFields(gadget)
+	generateName := gadget.GenerateName != "" && gadget.Name == ""
+	for attempt := 1; ; attempt++ {
+		if generateName {
+			gadget.Name = strategy.GenerateName(gadget.GenerateName)
+		}
+		if err := rest.BeforeCreate(strategy, ctx, gadget); err != nil {
+			return nil, err
+		}
+		if createValidation != nil {
+			if err := createValidation(ctx, gadget.DeepCopyObject()); err != nil {
+				return nil, err
+			}
+		}
+		var created *Gadget
+		var err error
+		if dryRun {
+			created, err = dryRunCreate(ctx, storage, gadget)
+		} else {
+			created, err = storage.Create(ctx, gadget)
+		}
+		if generateName && errors.IsAlreadyExists(err) && attempt < maxGenerateNameAttempts {
+			continue
+		}
+		return created, err
+	}
+}
+
+// dryRunCreate returns gadget as creating it would, failing if its name is taken
+func dryRunCreate(ctx context.Context, storage Storage, gadget *Gadget) (*Gadget, error) {
+	_, err := storage.Get(ctx, gadget.Namespace, gadget.Name)
+	if err == nil {
+		return nil, errors.NewAlreadyExists(Resource("gadgets"), gadget.Name)
 	}
-	return r.storage.Create(gadget)
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return gadget, nil
 }
 
 func (r *GadgetREST) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo,
 	createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc,
 	forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
-	oldObj, err := r.storage.Get(name)
-	if err != nil {
-		return nil, false, err
-	}
+	return update(ctx, r.storage, r.strategy, name, objInfo, createValidation, updateValidation, forceAllowCreate,
+		options != nil && dryrun.IsDryRun(options.DryRun))
+}
 
-	updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
-	if err != nil {
-		return nil, false, err
+// update replaces the gadget called name with the one objInfo computes from it, running strategy
+// so that only the parts of the gadget strategy allows to change are updated. If the gadget does
+// not exist and forceAllowCreate is set, as for server-side apply, it is created instead. A dry run
+// returns the gadget that would be stored without storing it.
+func update(ctx context.Context, storage Storage, strategy rest.RESTCreateUpdateStrategy, name string,
+	objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc,
+	updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate, dryRun bool) (runtime.Object, bool, error) {
+	namespace := genericapirequest.NamespaceValue(ctx)
+	for {
+		oldObj, err := storage.Get(ctx, namespace, name)
+		if errors.IsNotFound(err) && forceAllowCreate {
+			newObj, err := objInfo.UpdatedObject(ctx, &Gadget{})
+			if err != nil {
+				return nil, false, err
+			}
+			gadget, ok := newObj.(*Gadget)
+			if !ok {
+				return nil, false, errors.NewBadRequest(fmt.Sprintf("not a Gadget: %T", newObj))
+			}
+			gadget.Name = name
+			created, err := create(ctx, storage, strategy, gadget, createValidation, dryRun)
+			if errors.IsAlreadyExists(err) {
+				// Created concurrently; apply to that gadget instead
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			return created, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if err := store.CheckPreconditions(Resource("gadgets"),
+			objInfo.Preconditions(), oldObj); err != nil {
+			return nil, false, err
+		}
+
+		updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
+		if err != nil {
+			return nil, false, err
+		}
+
+		gadget, ok := updatedObj.(*Gadget)
+		if !ok {
+			return nil, false, errors.NewBadRequest(fmt.Sprintf("not a Gadget: %T", updatedObj))
+		}
+		gadget.Name = name
+		if err := rest.BeforeUpdate(strategy, ctx, gadget, oldObj); err != nil {
+			return nil, false, err
+		}
+		if updateValidation != nil {
+			if err := updateValidation(ctx, gadget.DeepCopyObject(), oldObj.DeepCopyObject()); err != nil {
+				return nil, false, err
+			}
+		}
+
+		// An update without resourceVersion is applied on top of the object it was computed
+		// from, and retried if that object changed in the meantime
+		unconditional := gadget.ResourceVersion == ""
+		if unconditional {
+			gadget.ResourceVersion = oldObj.ResourceVersion
+		}
+		if dryRun {
+			// The gadget read above is current, so only a stale resourceVersion would conflict
+			if gadget.ResourceVersion != oldObj.ResourceVersion {
+				return nil, false, store.NewOptimisticLockError(Resource("gadgets"), name)
+			}
+			return gadget, false, nil
+		}
+
+		// A gadget being deleted is removed by the update that removes its last finalizer
+		if gadget.DeletionTimestamp != nil && len(gadget.Finalizers) == 0 {
+			err := storage.Delete(ctx, namespace, name, &metav1.Preconditions{ResourceVersion: &gadget.ResourceVersion})
+			if unconditional && errors.IsConflict(err) {
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			return gadget, false, nil
+		}
+
+		updatedGadget, err := storage.Update(ctx, gadget)
+		if unconditional && errors.IsConflict(err) {
+			continue
+		}
+		return updatedGadget, false, err
 	}
-
-	gadget := updatedObj.(*Gadget)
-	gadget.Name = name
-	updatedGadget, err := r.storage.Update(gadget)
-	return updatedGadget, false, err
 }
 
+// Delete removes the gadget called name. A gadget with finalizers is only marked for deletion by
+// setting its deletionTimestamp; it is removed once the last finalizer is.
 func (r *GadgetREST) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc,
 	options *metav1.DeleteOptions) (runtime.Object, bool, error) {
-	obj, err := r.storage.Get(name)
-	if err != nil {
-		return nil, false, err
+	namespace := genericapirequest.NamespaceValue(ctx)
+	if options == nil {
+		options = &metav1.DeleteOptions{}
+	}
+	for {
+		gadget, err := r.storage.Get(ctx, namespace, name)
+		if err != nil {
+			return nil, false, err
+		}
+		action, err := deleteAction(ctx, r.strategy, gadget, options, deleteValidation)
+		if err != nil {
+			return nil, false, err
+		}
+		if action == store.KeepObject || dryrun.IsDryRun(options.DryRun) {
+			return gadget, action == store.RemoveObject, nil
+		}
+
+		if action == store.UpdateObject {
+			// The update is conditional on the gadget read above, so retry if it changed meanwhile
+			updated, err := r.storage.Update(ctx, gadget)
+			if errors.IsConflict(err) && options.Preconditions == nil {
+				continue
+			}
+			return updated, false, err
+		}
+
+		err = r.storage.Delete(ctx, namespace, name, &metav1.Preconditions{ResourceVersion: &gadget.ResourceVersion})
+		if errors.IsConflict(err) && options.Preconditions == nil {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return gadget, true, nil
+	}
+}
+
+// DeleteCollection deletes the gadgets in the request namespace matching listOptions as Delete
+// deletes each of them, in a single change to the storage
+func (r *GadgetREST) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc,
+	options *metav1.DeleteOptions, listOptions *internalversion.ListOptions) (runtime.Object, error) {
+	namespace := genericapirequest.NamespaceValue(ctx)
+	if options == nil {
+		options = &metav1.DeleteOptions{}
+	}
+	// Every selected gadget is deleted, not just a page of them
+	if listOptions == nil {
+		listOptions = &internalversion.ListOptions{}
+	} else {
+		listOptions = listOptions.DeepCopy()
+	}
+	listOptions.Limit = 0
+	listOptions.Continue = ""
+
+	prepare := func(gadget *Gadget) (store.DeleteAction, error) {
+		return deleteAction(ctx, r.strategy, gadget, options, deleteValidation)
+	}
+	if dryrun.IsDryRun(options.DryRun) {
+		list, err := r.storage.List(ctx, namespace, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			if _, err := prepare(&list.Items[i]); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return r.storage.DeleteCollection(ctx, namespace, listOptions, prepare)
+}
+
+// deleteAction runs strategy and deleteValidation for deleting gadget and decides what the
+// delete does with it. A gadget with finalizers is marked for deletion in place.
+func deleteAction(ctx context.Context, strategy rest.RESTDeleteStrategy, gadget *Gadget,
+	options *metav1.DeleteOptions, deleteValidation rest.ValidateObjectFunc) (store.DeleteAction, error) {
+	if _, _, err := rest.BeforeDelete(strategy, ctx, gadget, options); err != nil {
+		return store.KeepObject, err
+	}
+	if deleteValidation != nil {
+		if err := deleteValidation(ctx, gadget.DeepCopyObject()); err != nil {
+			return store.KeepObject, err
+		}
 	}
 
-	err = r.storage.Delete(name)
-	return obj, true, err
+	if len(gadget.Finalizers) == 0 {
+		return store.RemoveObject, nil
+	}
+	if gadget.DeletionTimestamp != nil {
+		return store.KeepObject, nil
+	}
+	now := metav1.Now()
+	gadget.DeletionTimestamp = &now
+	gadget.DeletionGracePeriodSeconds = ptr.To[int64](0)
+	return store.UpdateObject, nil
 }
 
-func (r *GadgetREST) Watch(ctx context.Context, options *metav1.ListOptions) (watch.Interface, error) {
-	return nil, fmt.Errorf("watch not implemented")
+func (r *GadgetREST) Watch(ctx context.Context, options *internalversion.ListOptions) (watch.Interface, error) {
+	return r.storage.Watch(ctx, genericapirequest.NamespaceValue(ctx), options)
 }
 
 func (r *GadgetREST) ConvertToTable(ctx context.Context, object runtime.Object,
 	tableOptions runtime.Object) (*metav1.Table, error) {
-	return rest.NewDefaultTableConvertor(schema.GroupResource{Group: common.GroupName, Resource: "gadgets"}).
+	return rest.NewDefaultTableConvertor(Resource("gadgets")).
 		ConvertToTable(ctx, object, tableOptions)
 }
 
+// GetResetFields returns the fields updates of gadgets ignore, for server-side apply
+func (r *GadgetREST) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return r.strategy.GetResetFields()
+}
+
 func (r *GadgetREST) NamespaceScoped() bool {
 	return true
 }
@@ -271,5 +813,5 @@ func (r *GadgetREST) GetSingularName() string {
 }
 
 func (r *GadgetREST) Destroy() {
-	// Cleanup resources if needed
+	r.storage.Destroy()
 }
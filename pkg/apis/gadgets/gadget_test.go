@@ -1,11 +1,29 @@
 package gadgets
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	apistorage "k8s.io/apiserver/pkg/storage"
+
+	"example.com/mytest-apiserver/pkg/store"
 )
 
 func TestGadgetStorage_Create(t *testing.T) {
@@ -13,18 +31,19 @@ func TestGadgetStorage_Create(t *testing.T) {
 
 	gadget := &Gadget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-gadget",
+			Name:      "test-gadget",
+			Namespace: "default",
 		},
 		Spec: GadgetSpec{
 			Type:     "sensor",
-			Version:  "v1.0",
+			Version:  "1.0.0",
 			Enabled:  true,
 			Priority: 10,
 		},
 	}
 
 	// Test successful creation
-	created, err := storage.Create(gadget)
+	created, err := storage.Create(context.Background(), gadget)
 	if err != nil {
 		t.Fatalf("Failed to create gadget: %v", err)
 	}
@@ -41,22 +60,14 @@ func TestGadgetStorage_Create(t *testing.T) {
 		t.Errorf("Expected priority 10, got %d", created.Spec.Priority)
 	}
 
-	if created.Status.State != "Active" {
-		t.Errorf("Expected state 'Active', got '%s'", created.Status.State)
-	}
-
 	if created.ResourceVersion == "" {
 		t.Error("ResourceVersion should be set")
 	}
 
-	if created.UID == "" {
-		t.Error("UID should be set")
-	}
-
 	// Test duplicate creation
-	_, err = storage.Create(gadget)
-	if err == nil {
-		t.Error("Expected error when creating duplicate gadget")
+	_, err = storage.Create(context.Background(), gadget)
+	if !errors.IsAlreadyExists(err) {
+		t.Errorf("Expected AlreadyExists when creating duplicate gadget, got %v", err)
 	}
 }
 
@@ -64,7 +75,7 @@ func TestGadgetStorage_Get(t *testing.T) {
 	storage := NewGadgetStorage()
 
 	// Test getting non-existent gadget
-	_, err := storage.Get("non-existent")
+	_, err := storage.Get(context.Background(), "default", "non-existent")
 	if err == nil {
 		t.Error("Expected error when getting non-existent gadget")
 	}
@@ -72,22 +83,23 @@ func TestGadgetStorage_Get(t *testing.T) {
 	// Create a gadget
 	gadget := &Gadget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-gadget",
+			Name:      "test-gadget",
+			Namespace: "default",
 		},
 		Spec: GadgetSpec{
 			Type:     "sensor",
-			Version:  "v1.0",
+			Version:  "1.0.0",
 			Enabled:  true,
 			Priority: 10,
 		},
 	}
-	_, err = storage.Create(gadget)
+	_, err = storage.Create(context.Background(), gadget)
 	if err != nil {
 		t.Fatalf("Failed to create gadget: %v", err)
 	}
 
 	// Test getting existing gadget
-	retrieved, err := storage.Get("test-gadget")
+	retrieved, err := storage.Get(context.Background(), "default", "test-gadget")
 	if err != nil {
 		t.Fatalf("Failed to get gadget: %v", err)
 	}
@@ -111,13 +123,14 @@ func TestGadgetStorage_Update(t *testing.T) {
 	// Test updating non-existent gadget
 	gadget := &Gadget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "non-existent",
+			Name:      "non-existent",
+			Namespace: "default",
 		},
 		Spec: GadgetSpec{
 			Priority: 20,
 		},
 	}
-	_, err := storage.Update(gadget)
+	_, err := storage.Update(context.Background(), gadget)
 	if err == nil {
 		t.Error("Expected error when updating non-existent gadget")
 	}
@@ -125,16 +138,17 @@ func TestGadgetStorage_Update(t *testing.T) {
 	// Create a gadget
 	originalGadget := &Gadget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-gadget",
+			Name:      "test-gadget",
+			Namespace: "default",
 		},
 		Spec: GadgetSpec{
 			Type:     "sensor",
-			Version:  "v1.0",
+			Version:  "1.0.0",
 			Enabled:  true,
 			Priority: 10,
 		},
 	}
-	created, err := storage.Create(originalGadget)
+	created, err := storage.Create(context.Background(), originalGadget)
 	if err != nil {
 		t.Fatalf("Failed to create gadget: %v", err)
 	}
@@ -145,9 +159,9 @@ func TestGadgetStorage_Update(t *testing.T) {
 	// Update the gadget (add small delay to ensure different timestamp)
 	time.Sleep(time.Millisecond)
 	created.Spec.Priority = 20
-	created.Spec.Version = "v2.0"
+	created.Spec.Version = "2.0.0"
 	created.Spec.Enabled = false
-	updated, err := storage.Update(created)
+	updated, err := storage.Update(context.Background(), created)
 	if err != nil {
 		t.Fatalf("Failed to update gadget: %v", err)
 	}
@@ -156,7 +170,7 @@ func TestGadgetStorage_Update(t *testing.T) {
 		t.Errorf("Expected priority 20, got %d", updated.Spec.Priority)
 	}
 
-	if updated.Spec.Version != "v2.0" {
+	if updated.Spec.Version != "2.0.0" {
 		t.Errorf("Expected version 'v2.0', got '%s'", updated.Spec.Version)
 	}
 
@@ -183,7 +197,7 @@ func TestGadgetStorage_Delete(t *testing.T) {
 	storage := NewGadgetStorage()
 
 	// Test deleting non-existent gadget
-	err := storage.Delete("non-existent")
+	err := storage.Delete(context.Background(), "default", "non-existent", nil)
 	if err == nil {
 		t.Error("Expected error when deleting non-existent gadget")
 	}
@@ -191,27 +205,28 @@ func TestGadgetStorage_Delete(t *testing.T) {
 	// Create a gadget
 	gadget := &Gadget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-gadget",
+			Name:      "test-gadget",
+			Namespace: "default",
 		},
 		Spec: GadgetSpec{
 			Type:     "sensor",
-			Version:  "v1.0",
+			Version:  "1.0.0",
 			Priority: 10,
 		},
 	}
-	_, err = storage.Create(gadget)
+	_, err = storage.Create(context.Background(), gadget)
 	if err != nil {
 		t.Fatalf("Failed to create gadget: %v", err)
 	}
 
 	// Delete the gadget
-	err = storage.Delete("test-gadget")
+	err = storage.Delete(context.Background(), "default", "test-gadget", nil)
 	if err != nil {
 		t.Fatalf("Failed to delete gadget: %v", err)
 	}
 
 	// Verify it's deleted
-	_, err = storage.Get("test-gadget")
+	_, err = storage.Get(context.Background(), "default", "test-gadget")
 	if err == nil {
 		t.Error("Gadget should be deleted")
 	}
@@ -221,7 +236,7 @@ func TestGadgetStorage_List(t *testing.T) {
 	storage := NewGadgetStorage()
 
 	// Test listing empty storage
-	list, err := storage.List()
+	list, err := storage.List(context.Background(), "default", &internalversion.ListOptions{})
 	if err != nil {
 		t.Fatalf("Failed to list gadgets: %v", err)
 	}
@@ -234,23 +249,24 @@ func TestGadgetStorage_List(t *testing.T) {
 	for i := 0; i < 3; i++ {
 		gadget := &Gadget{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: fmt.Sprintf("gadget-%d", i),
+				Name:      fmt.Sprintf("gadget-%d", i),
+				Namespace: "default",
 			},
 			Spec: GadgetSpec{
 				Type:     fmt.Sprintf("type-%d", i),
-				Version:  fmt.Sprintf("v1.%d", i),
+				Version:  fmt.Sprintf("1.%d.0", i),
 				Enabled:  i%2 == 0,
 				Priority: int32(i * 5),
 			},
 		}
-		_, err = storage.Create(gadget)
+		_, err = storage.Create(context.Background(), gadget)
 		if err != nil {
 			t.Fatalf("Failed to create gadget %d: %v", i, err)
 		}
 	}
 
 	// List all gadgets
-	list, err = storage.List()
+	list, err = storage.List(context.Background(), "default", &internalversion.ListOptions{})
 	if err != nil {
 		t.Fatalf("Failed to list gadgets: %v", err)
 	}
@@ -258,11 +274,6 @@ func TestGadgetStorage_List(t *testing.T) {
 	if len(list.Items) != 3 {
 		t.Errorf("Expected 3 gadgets, got %d", len(list.Items))
 	}
-
-	// Verify list metadata
-	if list.Kind != "GadgetList" {
-		t.Errorf("Expected kind 'GadgetList', got '%s'", list.Kind)
-	}
 }
 
 func TestGadgetStorage_ThreadSafety(t *testing.T) {
@@ -277,16 +288,17 @@ func TestGadgetStorage_ThreadSafety(t *testing.T) {
 			for j := 0; j < numOperations; j++ {
 				gadget := &Gadget{
 					ObjectMeta: metav1.ObjectMeta{
-						Name: fmt.Sprintf("gadget-%d-%d", id, j),
+						Name:      fmt.Sprintf("gadget-%d-%d", id, j),
+						Namespace: "default",
 					},
 					Spec: GadgetSpec{
 						Type:     fmt.Sprintf("type-%d", id),
-						Version:  fmt.Sprintf("v%d.%d", id, j),
+						Version:  fmt.Sprintf("%d.%d.0", id, j),
 						Enabled:  j%2 == 0,
 						Priority: int32(j),
 					},
 				}
-				_, err := storage.Create(gadget)
+				_, err := storage.Create(context.Background(), gadget)
 				if err != nil {
 					t.Errorf("Failed to create gadget %d-%d: %v", id, j, err)
 				}
@@ -301,7 +313,7 @@ func TestGadgetStorage_ThreadSafety(t *testing.T) {
 	}
 
 	// Verify all gadgets were created
-	list, err := storage.List()
+	list, err := storage.List(context.Background(), "default", &internalversion.ListOptions{})
 	if err != nil {
 		t.Fatalf("Failed to list gadgets: %v", err)
 	}
@@ -311,3 +323,1262 @@ func TestGadgetStorage_ThreadSafety(t *testing.T) {
 		t.Errorf("Expected %d gadgets, got %d", expected, len(list.Items))
 	}
 }
+
+func TestGadgetStorage_Watch(t *testing.T) {
+	storage := NewGadgetStorage()
+
+	existing, err := storage.Create(context.Background(), &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"},
+		Spec: GadgetSpec{
+			Type:     "sensor",
+			Version:  "1.0.0",
+			Priority: 10,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+
+	// A watch without resourceVersion starts with the current state
+	w, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to watch gadgets: %v", err)
+	}
+	defer w.Stop()
+
+	expectEvent := func(w watch.Interface, eventType watch.EventType, name string) *Gadget {
+		t.Helper()
+		select {
+		case event := <-w.ResultChan():
+			gadget := event.Object.(*Gadget)
+			if event.Type != eventType || gadget.Name != name {
+				t.Fatalf("Expected %s event for %s, got %s for %s", eventType, name, event.Type, gadget.Name)
+			}
+			return gadget
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s event for %s", eventType, name)
+		}
+		return nil
+	}
+
+	expectEvent(w, watch.Added, "existing")
+
+	created, err := storage.Create(context.Background(), &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget", Namespace: "default"},
+		Spec: GadgetSpec{
+			Type:     "sensor",
+			Version:  "1.0.0",
+			Priority: 10,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	expectEvent(w, watch.Added, "test-gadget")
+
+	updated, err := storage.Update(context.Background(), created)
+	if err != nil {
+		t.Fatalf("Failed to update gadget: %v", err)
+	}
+	modified := expectEvent(w, watch.Modified, "test-gadget")
+	if modified.ResourceVersion != updated.ResourceVersion {
+		t.Errorf("Expected MODIFIED event at resourceVersion %s, got %s", updated.ResourceVersion, modified.ResourceVersion)
+	}
+
+	if err := storage.Delete(context.Background(), "default", "test-gadget", nil); err != nil {
+		t.Fatalf("Failed to delete gadget: %v", err)
+	}
+	deleted := expectEvent(w, watch.Deleted, "test-gadget")
+
+	// Resuming from the first object's resourceVersion replays everything after it
+	resumed, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: existing.ResourceVersion})
+	if err != nil {
+		t.Fatalf("Failed to resume watch: %v", err)
+	}
+	defer resumed.Stop()
+
+	expectEvent(resumed, watch.Added, "test-gadget")
+	expectEvent(resumed, watch.Modified, "test-gadget")
+	if replayed := expectEvent(resumed, watch.Deleted, "test-gadget"); replayed.ResourceVersion != deleted.ResourceVersion {
+		t.Errorf("Expected replayed DELETED event at resourceVersion %s, got %s", deleted.ResourceVersion, replayed.ResourceVersion)
+	}
+
+	// Invalid resourceVersions are rejected
+	_, err = storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "abc"})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for invalid resourceVersion, got %v", err)
+	}
+
+	// So are resourceVersions that have not been reached yet, rather than skipping events up to them
+	_, err = storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "1000"})
+	if !apistorage.IsTooLargeResourceVersion(err) {
+		t.Errorf("Expected 504 Timeout for a resourceVersion newer than the current one, got %v", err)
+	}
+}
+
+func TestGadgetStorage_Namespaces(t *testing.T) {
+	storage := NewGadgetStorage()
+
+	// The same name may be used in different namespaces
+	for _, namespace := range []string{"default", "team-a"} {
+		_, err := storage.Create(context.Background(), &Gadget{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: namespace},
+			Spec: GadgetSpec{
+				Type:     "sensor",
+				Version:  "1.0.0",
+				Priority: 10,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create gadget in namespace %s: %v", namespace, err)
+		}
+	}
+
+	retrieved, err := storage.Get(context.Background(), "team-a", "shared")
+	if err != nil {
+		t.Fatalf("Failed to get gadget: %v", err)
+	}
+	if retrieved.Namespace != "team-a" {
+		t.Errorf("Expected namespace 'team-a', got '%s'", retrieved.Namespace)
+	}
+
+	list, err := storage.List(context.Background(), "team-a", &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list gadgets: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Namespace != "team-a" {
+		t.Errorf("Expected only the gadget in 'team-a', got %d items", len(list.Items))
+	}
+
+	list, err = storage.List(context.Background(), metav1.NamespaceAll, &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list gadgets: %v", err)
+	}
+	if len(list.Items) != 2 {
+		t.Errorf("Expected 2 gadgets across all namespaces, got %d", len(list.Items))
+	}
+
+	if err := storage.Delete(context.Background(), "default", "shared", nil); err != nil {
+		t.Fatalf("Failed to delete gadget: %v", err)
+	}
+	if _, err := storage.Get(context.Background(), "team-a", "shared"); err != nil {
+		t.Errorf("Deleting in one namespace should not affect another: %v", err)
+	}
+}
+
+func TestGadgetREST_NamespaceMismatch(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "team-a")
+
+	_, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget", Namespace: "default"},
+		Spec:       GadgetSpec{Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for mismatched namespace, got %v", err)
+	}
+
+	// The namespace is taken from the request when the object omits it
+	created, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec:       GadgetSpec{Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	if created.(*Gadget).Namespace != "team-a" {
+		t.Errorf("Expected namespace 'team-a', got '%s'", created.(*Gadget).Namespace)
+	}
+
+	_, err = rest.Get(genericapirequest.WithNamespace(context.Background(), "default"), "test-gadget", &metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected NotFound in another namespace, got %v", err)
+	}
+}
+
+func TestGadgetStorage_UpdateConflict(t *testing.T) {
+	storage := NewGadgetStorage()
+
+	created, err := storage.Create(context.Background(), &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget", Namespace: "default"},
+		Spec: GadgetSpec{
+			Type:     "sensor",
+			Version:  "1.0.0",
+			Priority: 10,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+
+	// Two clients read the same version
+	first := created.DeepCopyObject().(*Gadget)
+	second := created.DeepCopyObject().(*Gadget)
+
+	first.Spec.Priority = 1
+	if _, err := storage.Update(context.Background(), first); err != nil {
+		t.Fatalf("Failed to update gadget: %v", err)
+	}
+
+	second.Spec.Priority = 2
+	_, err = storage.Update(context.Background(), second)
+	if !errors.IsConflict(err) {
+		t.Fatalf("Expected conflict for stale resourceVersion, got %v", err)
+	}
+
+	retrieved, err := storage.Get(context.Background(), "default", "test-gadget")
+	if err != nil {
+		t.Fatalf("Failed to get gadget: %v", err)
+	}
+	if retrieved.Spec.Priority != 1 {
+		t.Errorf("Expected the first update to be preserved, got Priority %d", retrieved.Spec.Priority)
+	}
+}
+
+type testUpdateInfo struct {
+	preconditions *metav1.Preconditions
+	update        func(*Gadget)
+}
+
+func (i *testUpdateInfo) Preconditions() *metav1.Preconditions {
+	return i.preconditions
+}
+
+func (i *testUpdateInfo) UpdatedObject(ctx context.Context, oldObj runtime.Object) (runtime.Object, error) {
+	gadget := oldObj.DeepCopyObject().(*Gadget)
+	i.update(gadget)
+	return gadget, nil
+}
+
+func TestGadgetREST_UpdatePreconditions(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec: GadgetSpec{
+			Type:     "sensor",
+			Version:  "1.0.0",
+			Priority: 10,
+		},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	created := obj.(*Gadget)
+
+	setField := func(gadget *Gadget) { gadget.Spec.Priority = 1 }
+
+	wrongUID := types.UID("wrong-uid")
+	_, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		preconditions: &metav1.Preconditions{UID: &wrongUID},
+		update:        setField,
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for UID precondition, got %v", err)
+	}
+
+	staleVersion := "0"
+	_, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		preconditions: &metav1.Preconditions{ResourceVersion: &staleVersion},
+		update:        setField,
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for resourceVersion precondition, got %v", err)
+	}
+
+	updated, _, err := rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		preconditions: &metav1.Preconditions{UID: &created.UID, ResourceVersion: &created.ResourceVersion},
+		update:        setField,
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Expected update with matching preconditions to succeed: %v", err)
+	}
+	if updated.(*Gadget).Spec.Priority != 1 {
+		t.Errorf("Expected Priority 1, got %d", updated.(*Gadget).Spec.Priority)
+	}
+}
+
+// testCodec persists gadgets in the file storage tests, which cannot import the served versions
+var testCodec = func() runtime.Codec {
+	gv := schema.GroupVersion{Group: "test", Version: "v1"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(gv, &Gadget{}, &GadgetList{})
+	codecs := serializer.NewCodecFactory(scheme)
+	return codecs.CodecForVersions(codecs.LegacyCodec(gv), codecs.UniversalDeserializer(), gv, gv)
+}()
+
+func TestGadgetStorage_FileRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to create file storage: %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := storage.Create(ctx, &Gadget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0", Enabled: true},
+		}); err != nil {
+			t.Fatalf("Failed to create gadget: %v", err)
+		}
+	}
+	if err := storage.Delete(ctx, "default", "b", nil); err != nil {
+		t.Fatalf("Failed to delete gadget: %v", err)
+	}
+	before, err := storage.Get(ctx, "default", "a")
+	if err != nil {
+		t.Fatalf("Failed to get gadget: %v", err)
+	}
+	storage.Destroy()
+	// The server destroys the storage once for every version it serves
+	storage.Destroy()
+
+	storage, err = NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer storage.Destroy()
+
+	after, err := storage.Get(ctx, "default", "a")
+	if err != nil {
+		t.Fatalf("Expected gadget to survive a restart: %v", err)
+	}
+	if after.ResourceVersion != before.ResourceVersion || after.UID != before.UID || after.Spec != before.Spec {
+		t.Errorf("Expected gadget to be restored unchanged, got %+v, want %+v", after, before)
+	}
+	if _, err := storage.Get(ctx, "default", "b"); !errors.IsNotFound(err) {
+		t.Errorf("Expected deleted gadget to stay deleted, got %v", err)
+	}
+
+	// Revisions continue after the deletion instead of being reused
+	created, err := storage.Create(ctx, &Gadget{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	if created.ResourceVersion != "4" {
+		t.Errorf("Expected resourceVersion 4 after restart, got %s", created.ResourceVersion)
+	}
+}
+
+func TestGadgetStorage_FileRestartWatch(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to create file storage: %v", err)
+	}
+	var last *Gadget
+	for _, name := range []string{"a", "b", "c"} {
+		if last, err = storage.Create(ctx, &Gadget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0", Enabled: true},
+		}); err != nil {
+			t.Fatalf("Failed to create gadget: %v", err)
+		}
+	}
+	storage.Destroy()
+
+	storage, err = NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer storage.Destroy()
+
+	// The events before the restart are gone, so watches from before it have to relist
+	if _, err := storage.Watch(ctx, "default", &internalversion.ListOptions{ResourceVersion: "1"}); !errors.IsResourceExpired(err) {
+		t.Errorf("Expected 410 Gone for a watch from before the restart, got %v", err)
+	}
+
+	w, err := storage.Watch(ctx, "default", &internalversion.ListOptions{ResourceVersion: last.ResourceVersion})
+	if err != nil {
+		t.Fatalf("Expected a watch from the last recovered resourceVersion to start: %v", err)
+	}
+	defer w.Stop()
+	if _, err := storage.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "d", Namespace: "default"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0", Enabled: true},
+	}); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	select {
+	case event := <-w.ResultChan():
+		if event.Type != watch.Added || event.Object.(*Gadget).Name != "d" {
+			t.Errorf("Expected ADDED event for d, got %s for %v", event.Type, event.Object)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the event after the restart")
+	}
+}
+
+func TestGadgetStorage_Selectors(t *testing.T) {
+	storage := NewGadgetStorage()
+	ctx := context.Background()
+
+	for _, gadget := range []*Gadget{
+		{ObjectMeta: metav1.ObjectMeta{Name: "sensor", Namespace: "default", Labels: map[string]string{"team": "foo"}}, Spec: GadgetSpec{Type: "sensor", Enabled: true}, Status: GadgetStatus{State: "Active"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "actuator", Namespace: "default", Labels: map[string]string{"team": "bar"}}, Spec: GadgetSpec{Type: "actuator"}, Status: GadgetStatus{State: "Active"}},
+	} {
+		if _, err := storage.Create(ctx, gadget); err != nil {
+			t.Fatalf("Failed to create gadget: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		options  *internalversion.ListOptions
+		expected []string
+	}{
+		{"label", &internalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"team": "foo"})}, []string{"sensor"}},
+		{"name", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", "actuator")}, []string{"actuator"}},
+		{"type", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.type", "sensor")}, []string{"sensor"}},
+		{"enabled", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.enabled", "false")}, []string{"actuator"}},
+		{"state", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("status.state", "Active")}, []string{"actuator", "sensor"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := storage.List(ctx, "default", tc.options)
+			if err != nil {
+				t.Fatalf("Failed to list gadgets: %v", err)
+			}
+			var names []string
+			for _, gadget := range list.Items {
+				names = append(names, gadget.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+
+	_, err := storage.List(ctx, "default", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.priority", "1")})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for unsupported field selector, got %v", err)
+	}
+	_, err = storage.Watch(ctx, "default", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.priority", "1")})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for unsupported field selector, got %v", err)
+	}
+}
+
+func TestGadgetStorage_WatchSelectors(t *testing.T) {
+	storage := NewGadgetStorage()
+	ctx := context.Background()
+
+	w, err := storage.Watch(ctx, "default", &internalversion.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"team": "foo"}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch gadgets: %v", err)
+	}
+	defer w.Stop()
+
+	gadget, err := storage.Create(ctx, &Gadget{ObjectMeta: metav1.ObjectMeta{Name: "w", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+
+	// Objects entering and leaving the selection are seen as added and deleted
+	gadget.Labels = map[string]string{"team": "foo"}
+	if gadget, err = storage.Update(ctx, gadget); err != nil {
+		t.Fatalf("Failed to update gadget: %v", err)
+	}
+	gadget.Labels = map[string]string{"team": "bar"}
+	if gadget, err = storage.Update(ctx, gadget); err != nil {
+		t.Fatalf("Failed to update gadget: %v", err)
+	}
+
+	for _, expected := range []watch.EventType{watch.Added, watch.Deleted} {
+		select {
+		case event := <-w.ResultChan():
+			if event.Type != expected {
+				t.Errorf("Expected %s event, got %s", expected, event.Type)
+			}
+			if event.Type == watch.Deleted && event.Object.(*Gadget).ResourceVersion != gadget.ResourceVersion {
+				t.Errorf("Expected DELETED event at resourceVersion %s, got %s",
+					gadget.ResourceVersion, event.Object.(*Gadget).ResourceVersion)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s event", expected)
+		}
+	}
+}
+
+func TestGadgetStorage_Pagination(t *testing.T) {
+	storage := NewGadgetStorage()
+	ctx := context.Background()
+
+	for _, name := range []string{"c", "a", "e", "b", "d"} {
+		if _, err := storage.Create(ctx, &Gadget{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}); err != nil {
+			t.Fatalf("Failed to create gadget: %v", err)
+		}
+	}
+
+	list, err := storage.List(ctx, "default", &internalversion.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list gadgets: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Name != "a" || list.Items[1].Name != "b" {
+		t.Errorf("Expected first page [a b], got %v", list.Items)
+	}
+	if list.Continue == "" || list.RemainingItemCount == nil || *list.RemainingItemCount != 3 {
+		t.Fatalf("Expected continue token with 3 remaining items, got %q and %v", list.Continue, list.RemainingItemCount)
+	}
+	revision := list.ResourceVersion
+
+	// Changes after the first page do not show up in later pages
+	if err := storage.Delete(ctx, "default", "c", nil); err != nil {
+		t.Fatalf("Failed to delete gadget: %v", err)
+	}
+
+	var names []string
+	for list.Continue != "" {
+		if list, err = storage.List(ctx, "default", &internalversion.ListOptions{Limit: 2, Continue: list.Continue}); err != nil {
+			t.Fatalf("Failed to list gadgets: %v", err)
+		}
+		if list.ResourceVersion != revision {
+			t.Errorf("Expected all pages at resourceVersion %s, got %s", revision, list.ResourceVersion)
+		}
+		for _, gadget := range list.Items {
+			names = append(names, gadget.Name)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"c", "d", "e"}) {
+		t.Errorf("Expected remaining pages [c d e], got %v", names)
+	}
+}
+
+func TestGadgetREST_Validation(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	_, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "v1.0"},
+	}, nil, &metav1.CreateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Fatalf("Expected 422 Invalid, got %v", err)
+	}
+	causes := err.(*errors.StatusError).ErrStatus.Details.Causes
+	if len(causes) != 1 || causes[0].Field != "spec.version" {
+		t.Errorf("Expected a cause for spec.version, got %+v", causes)
+	}
+
+	// The validation callbacks handed in by the handlers run as well
+	rejected := errors.NewForbidden(schema.GroupResource{Resource: "gadgets"}, "test-gadget", fmt.Errorf("denied"))
+	_, err = rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, func(ctx context.Context, obj runtime.Object) error {
+		return rejected
+	}, &metav1.CreateOptions{})
+	if err != rejected {
+		t.Errorf("Expected createValidation error, got %v", err)
+	}
+
+	if _, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+
+	_, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Spec.Version = "next" },
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid on update, got %v", err)
+	}
+
+	_, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Spec.Version = "1.1.0" },
+	}, nil, func(ctx context.Context, obj, old runtime.Object) error {
+		return rejected
+	}, false, &metav1.UpdateOptions{})
+	if err != rejected {
+		t.Errorf("Expected updateValidation error, got %v", err)
+	}
+}
+func TestGadgetREST_Create(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+		Status:     GadgetStatus{State: "Broken"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	created := obj.(*Gadget)
+	if created.Status.State != "Active" {
+		t.Errorf("Expected status 'Active', got '%s'", created.Status.State)
+	}
+	if created.UID == "" || created.CreationTimestamp.IsZero() {
+		t.Error("UID and CreationTimestamp should be set")
+	}
+
+	obj, err = rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "gadget-"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	if name := obj.(*Gadget).Name; !strings.HasPrefix(name, "gadget-") || len(name) == len("gadget-") {
+		t.Errorf("Expected a name generated from 'gadget-', got '%s'", name)
+	}
+
+	// Object metadata is validated like that of upstream resources
+	_, err = rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget", Labels: map[string]string{"bad key": "value"}},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid for an invalid label, got %v", err)
+	}
+
+	// Updates keep the fields the server owns
+	updated, _, err := rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) {
+			gadget.UID = ""
+			gadget.CreationTimestamp = metav1.Time{}
+			gadget.Spec.Priority = 3
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update gadget: %v", err)
+	}
+	if updated.(*Gadget).UID != created.UID || !updated.(*Gadget).CreationTimestamp.Equal(&created.CreationTimestamp) {
+		t.Error("UID and CreationTimestamp should remain the same")
+	}
+
+	wrongUID := types.UID("wrong-uid")
+	_, _, err = rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &wrongUID}})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for a mismatched UID precondition, got %v", err)
+	}
+}
+func TestGadgetREST_Status(t *testing.T) {
+	storage := NewGadgetStorage()
+	gadgetREST := NewGadgetRESTWithStorage(storage, Strategy)
+	statusREST := NewStatusREST(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	if _, err := gadgetREST.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget", Labels: map[string]string{"team": "foo"}},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0", Priority: 1},
+	}, nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+
+	// Updates of the gadget ignore status changes
+	obj, _, err := gadgetREST.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) {
+			gadget.Spec.Priority = 2
+			gadget.Status.State = "Failed"
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update gadget: %v", err)
+	}
+	if gadget := obj.(*Gadget); gadget.Spec.Priority != 2 || gadget.Status.State != "Active" {
+		t.Errorf("Expected priority 2 and state 'Active', got %d and '%s'", gadget.Spec.Priority, gadget.Status.State)
+	}
+
+	// Updates of the status ignore everything else
+	obj, _, err = statusREST.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) {
+			gadget.Spec.Priority = 3
+			gadget.Labels = map[string]string{"team": "bar"}
+			gadget.Status.State = "Failed"
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update gadget status: %v", err)
+	}
+	gadget := obj.(*Gadget)
+	if gadget.Spec.Priority != 2 || gadget.Labels["team"] != "foo" || gadget.Status.State != "Failed" {
+		t.Errorf("Expected only the state to change, got priority %d, labels %v and state '%s'",
+			gadget.Spec.Priority, gadget.Labels, gadget.Status.State)
+	}
+
+	obj, err = statusREST.Get(ctx, "test-gadget", &metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get gadget status: %v", err)
+	}
+	if obj.(*Gadget).Status.State != "Failed" {
+		t.Errorf("Expected state 'Failed', got '%s'", obj.(*Gadget).Status.State)
+	}
+}
+func TestGadgetREST_Generation(t *testing.T) {
+	storage := NewGadgetStorage()
+	gadgetREST := NewGadgetRESTWithStorage(storage, Strategy)
+	statusREST := NewStatusREST(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := gadgetREST.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget", Generation: 5},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0", Priority: 1},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	if generation := obj.(*Gadget).Generation; generation != 1 {
+		t.Errorf("Expected generation 1 on create, got %d", generation)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		rest       rest.Updater
+		update     func(*Gadget)
+		generation int64
+	}{
+		{"spec change", gadgetREST, func(gadget *Gadget) { gadget.Spec.Priority = 2 }, 2},
+		{"metadata change", gadgetREST, func(gadget *Gadget) { gadget.Labels = map[string]string{"team": "foo"} }, 2},
+		{"generation change", gadgetREST, func(gadget *Gadget) { gadget.Generation = 10 }, 2},
+		{"status change", statusREST, func(gadget *Gadget) {
+			gadget.Status.ObservedGeneration = 2
+			gadget.Status.Conditions = []metav1.Condition{{
+				Type:               "Ready",
+				Status:             metav1.ConditionTrue,
+				Reason:             "Reconciled",
+				LastTransitionTime: metav1.Now(),
+			}}
+		}, 2},
+		{"another spec change", gadgetREST, func(gadget *Gadget) { gadget.Spec.Enabled = true }, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obj, _, err := tc.rest.Update(ctx, "test-gadget", &testUpdateInfo{update: tc.update},
+				nil, nil, false, &metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("Failed to update gadget: %v", err)
+			}
+			if generation := obj.(*Gadget).Generation; generation != tc.generation {
+				t.Errorf("Expected generation %d, got %d", tc.generation, generation)
+			}
+		})
+	}
+
+	obj, err = gadgetREST.Get(ctx, "test-gadget", &metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get gadget: %v", err)
+	}
+	status := obj.(*Gadget).Status
+	if status.ObservedGeneration != 2 || len(status.Conditions) != 1 || status.Conditions[0].Type != "Ready" {
+		t.Errorf("Expected observed generation 2 and a Ready condition, got %+v", status)
+	}
+
+	_, _, err = statusREST.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) {
+			gadget.Status.Conditions = append(gadget.Status.Conditions, metav1.Condition{Type: "Degraded"})
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid for an incomplete condition, got %v", err)
+	}
+}
+func TestGadgetREST_Finalizers(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	if _, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget", Finalizers: []string{"example.com/cleanup"}},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+
+	// A dry run changes nothing
+	obj, deleted, err := rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil || deleted || obj.(*Gadget).DeletionTimestamp == nil {
+		t.Fatalf("Expected a dry run to report the deletionTimestamp, got %v, %v, %v", obj, deleted, err)
+	}
+	if obj, _ := rest.Get(ctx, "test-gadget", &metav1.GetOptions{}); obj.(*Gadget).DeletionTimestamp != nil {
+		t.Error("Expected a dry run not to mark the gadget for deletion")
+	}
+
+	// Deleting a gadget with finalizers only marks it for deletion
+	obj, deleted, err = rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("Failed to delete gadget: %v", err)
+	}
+	gadget := obj.(*Gadget)
+	if deleted || gadget.DeletionTimestamp == nil || gadget.DeletionGracePeriodSeconds == nil ||
+		*gadget.DeletionGracePeriodSeconds != 0 {
+		t.Fatalf("Expected the gadget to be marked for deletion, got deleted %v and %+v", deleted, gadget.ObjectMeta)
+	}
+
+	// Deleting it again changes nothing
+	obj, deleted, err = rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{})
+	if err != nil || deleted || obj.(*Gadget).ResourceVersion != gadget.ResourceVersion {
+		t.Errorf("Expected a repeated delete to be a no-op, got %v, %v", deleted, err)
+	}
+
+	// No finalizers can be added and the deletionTimestamp cannot be cleared
+	_, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Finalizers = append(gadget.Finalizers, "example.com/other") },
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid adding a finalizer, got %v", err)
+	}
+	obj, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.DeletionTimestamp = nil },
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil || obj.(*Gadget).DeletionTimestamp == nil {
+		t.Errorf("Expected the deletionTimestamp to be kept, got %v", err)
+	}
+
+	// Removing the last finalizer removes the gadget
+	if _, _, err := rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Finalizers = nil },
+	}, nil, nil, false, &metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to remove finalizer: %v", err)
+	}
+	if _, err := rest.Get(ctx, "test-gadget", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected the gadget to be removed with its last finalizer, got %v", err)
+	}
+}
+
+func TestGadgetREST_StoredInvalidVersion(t *testing.T) {
+	storage := NewGadgetStorage()
+	rest := NewGadgetRESTWithStorage(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	// Stored before versions were validated
+	if _, err := storage.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "default", Finalizers: []string{"example.com/cleanup"}},
+		Spec:       GadgetSpec{Type: "sensor", Version: "v1.0"},
+	}); err != nil {
+		t.Fatalf("Failed to store gadget: %v", err)
+	}
+	if _, deleted, err := rest.Delete(ctx, "old", nil, &metav1.DeleteOptions{}); err != nil || deleted {
+		t.Fatalf("Expected the gadget to be marked for deletion, got %v, %v", deleted, err)
+	}
+
+	// Its finalizer can be removed without fixing its version
+	if _, _, err := rest.Update(ctx, "old", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Finalizers = nil },
+	}, nil, nil, false, &metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to remove finalizer: %v", err)
+	}
+	if _, err := rest.Get(ctx, "old", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected the gadget to be removed with its last finalizer, got %v", err)
+	}
+}
+
+func TestGadgetREST_DeleteOptions(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	created := obj.(*Gadget)
+
+	staleVersion := "0"
+	_, _, err = rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &staleVersion},
+	})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for a stale resourceVersion precondition, got %v", err)
+	}
+
+	_, deleted, err := rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil || !deleted {
+		t.Errorf("Expected a dry run to report the deletion, got %v, %v", deleted, err)
+	}
+	if _, err := rest.Get(ctx, "test-gadget", &metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected a dry run not to delete the gadget, got %v", err)
+	}
+
+	_, deleted, err = rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &created.UID, ResourceVersion: &created.ResourceVersion},
+	})
+	if err != nil || !deleted {
+		t.Errorf("Expected the gadget to be deleted, got %v, %v", deleted, err)
+	}
+	if _, err := rest.Get(ctx, "test-gadget", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected the gadget to be gone, got %v", err)
+	}
+}
+
+func TestGadgetREST_DeleteCollection(t *testing.T) {
+	storage := NewGadgetStorage()
+	rest := NewGadgetRESTWithStorage(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	for _, gadget := range []*Gadget{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default", Labels: map[string]string{"tier": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default", Labels: map[string]string{"tier": "web"},
+			Finalizers: []string{"example.com/cleanup"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "default", Labels: map[string]string{"tier": "db"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "d", Namespace: "other", Labels: map[string]string{"tier": "web"}}},
+	} {
+		gadget.Spec = GadgetSpec{Type: "sensor", Version: "1.0.0"}
+		if _, err := rest.Create(genericapirequest.WithNamespace(context.Background(), gadget.Namespace), gadget,
+			nil, &metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Failed to create gadget %s: %v", gadget.Name, err)
+		}
+	}
+	selector := &internalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"tier": "web"})}
+
+	remaining := func(namespace string) []string {
+		t.Helper()
+		list, err := storage.List(context.Background(), namespace, &internalversion.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed to list gadgets: %v", err)
+		}
+		var names []string
+		for _, gadget := range list.Items {
+			if gadget.DeletionTimestamp == nil {
+				names = append(names, gadget.Name)
+			}
+		}
+		return names
+	}
+
+	// A dry run reports what would be deleted without changing anything
+	obj, err := rest.DeleteCollection(ctx, nil, &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}, selector)
+	if err != nil {
+		t.Fatalf("Failed to delete gadgets: %v", err)
+	}
+	if items := obj.(*GadgetList).Items; len(items) != 2 || items[1].DeletionTimestamp == nil {
+		t.Errorf("Expected a dry run to report a and b marked for deletion, got %+v", items)
+	}
+	if names := remaining("default"); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("Expected a dry run not to delete anything, got %v", names)
+	}
+
+	// A failing validation aborts before anything is deleted
+	_, err = rest.DeleteCollection(ctx, func(ctx context.Context, obj runtime.Object) error {
+		if obj.(*Gadget).Name == "b" {
+			return errors.NewForbidden(schema.GroupResource{Resource: "gadgets"}, "b", fmt.Errorf("denied"))
+		}
+		return nil
+	}, &metav1.DeleteOptions{}, selector)
+	if !errors.IsForbidden(err) {
+		t.Errorf("Expected the validation error, got %v", err)
+	}
+	if names := remaining("default"); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("Expected nothing to be deleted, got %v", names)
+	}
+
+	w, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "4"})
+	if err != nil {
+		t.Fatalf("Failed to watch gadgets: %v", err)
+	}
+	defer w.Stop()
+
+	obj, err = rest.DeleteCollection(ctx, nil, &metav1.DeleteOptions{}, selector)
+	if err != nil {
+		t.Fatalf("Failed to delete gadgets: %v", err)
+	}
+	if items := obj.(*GadgetList).Items; len(items) != 2 || items[0].Name != "a" || items[1].Name != "b" {
+		t.Errorf("Expected a and b to be deleted, got %+v", items)
+	}
+
+	// Only the selected gadgets in the request namespace are affected; b waits for its finalizer
+	if names := remaining("default"); !reflect.DeepEqual(names, []string{"c"}) {
+		t.Errorf("Expected only c to be left, got %v", names)
+	}
+	if _, err := storage.Get(context.Background(), "other", "d"); err != nil {
+		t.Errorf("Expected the gadget in another namespace to be kept, got %v", err)
+	}
+	b, err := storage.Get(context.Background(), "default", "b")
+	if err != nil || b.DeletionTimestamp == nil {
+		t.Errorf("Expected b to be marked for deletion, got %v, %v", b, err)
+	}
+
+	for _, expected := range []struct {
+		eventType watch.EventType
+		name      string
+	}{{watch.Deleted, "a"}, {watch.Modified, "b"}} {
+		select {
+		case event := <-w.ResultChan():
+			if gadget := event.Object.(*Gadget); event.Type != expected.eventType || gadget.Name != expected.name {
+				t.Errorf("Expected %s event for %s, got %s for %s", expected.eventType, expected.name, event.Type, gadget.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s event for %s", expected.eventType, expected.name)
+		}
+	}
+}
+
+func TestGadgetStorage_DeleteCollectionUnlocked(t *testing.T) {
+	storage := NewGadgetStorage()
+	ctx := context.Background()
+	for _, name := range []string{"a", "b"} {
+		if _, err := storage.Create(ctx, &Gadget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+		}); err != nil {
+			t.Fatalf("Failed to create gadget: %v", err)
+		}
+	}
+
+	// prepare may read the storage, as delete validation does through listers
+	list, err := storage.DeleteCollection(ctx, "default", &internalversion.ListOptions{},
+		func(gadget *Gadget) (store.DeleteAction, error) {
+			_, err := storage.Get(ctx, gadget.Namespace, gadget.Name)
+			return store.RemoveObject, err
+		})
+	if err != nil || len(list.Items) != 2 {
+		t.Fatalf("Expected both gadgets to be deleted, got %v: %v", list, err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := storage.Create(ctx, &Gadget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+		}); err != nil {
+			t.Fatalf("Failed to create gadget: %v", err)
+		}
+	}
+
+	// A gadget changed while deciding fails the whole collection, leaving every gadget alone
+	_, err = storage.DeleteCollection(ctx, "default", &internalversion.ListOptions{},
+		func(gadget *Gadget) (store.DeleteAction, error) {
+			if gadget.Name == "b" {
+				current, err := storage.Get(ctx, gadget.Namespace, gadget.Name)
+				if err != nil {
+					return store.KeepObject, err
+				}
+				current.Labels = map[string]string{"changed": "true"}
+				if _, err := storage.Update(ctx, current); err != nil {
+					return store.KeepObject, err
+				}
+			}
+			return store.RemoveObject, nil
+		})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected a conflict for a gadget changed while deciding, got %v", err)
+	}
+	remaining, err := storage.List(ctx, "default", &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list gadgets: %v", err)
+	}
+	if len(remaining.Items) != 2 {
+		t.Errorf("Expected no gadget to be deleted after the conflict, got %v", remaining.Items)
+	}
+}
+
+func TestGadgetREST_Errors(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+	newGadget := func() *Gadget {
+		return &Gadget{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+			Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+		}
+	}
+
+	expectStatus := func(err error, reason metav1.StatusReason, code int32) {
+		t.Helper()
+		status, ok := err.(errors.APIStatus)
+		if !ok {
+			t.Fatalf("Expected a status error with reason %s, got %v", reason, err)
+		}
+		s := status.Status()
+		if s.Reason != reason || s.Code != code {
+			t.Errorf("Expected %s (%d), got %s (%d): %s", reason, code, s.Reason, s.Code, s.Message)
+		}
+		if reason != metav1.StatusReasonBadRequest && (s.Details == nil || s.Details.Group != "things.myorg.io" ||
+			s.Details.Name != "test-gadget") {
+			t.Errorf("Expected details naming things.myorg.io test-gadget, got %+v", s.Details)
+		}
+	}
+
+	if _, err := rest.Create(ctx, newGadget(), nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	_, err := rest.Create(ctx, newGadget(), nil, &metav1.CreateOptions{})
+	expectStatus(err, metav1.StatusReasonAlreadyExists, 409)
+	if details := err.(errors.APIStatus).Status().Details; details.Kind != "gadgets" {
+		t.Errorf("Expected details for gadgets, got %+v", details)
+	}
+
+	_, err = rest.Get(ctx, "missing", &metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+
+	stale := "0"
+	_, _, err = rest.Delete(ctx, "test-gadget", nil, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &stale},
+	})
+	expectStatus(err, metav1.StatusReasonConflict, 409)
+
+	invalid := newGadget()
+	invalid.Name = "other-gadget"
+	invalid.Spec = GadgetSpec{}
+	_, err = rest.Create(ctx, invalid, nil, &metav1.CreateOptions{})
+	if !errors.IsInvalid(err) || err.(errors.APIStatus).Status().Details.Kind != "Gadget" {
+		t.Errorf("Expected Gadget to be Invalid, got %v", err)
+	}
+
+	_, err = rest.Create(ctx, &metav1.Status{}, nil, &metav1.CreateOptions{})
+	expectStatus(err, metav1.StatusReasonBadRequest, 400)
+
+	_, err = rest.Watch(ctx, &internalversion.ListOptions{ResourceVersion: "abc"})
+	expectStatus(err, metav1.StatusReasonBadRequest, 400)
+}
+
+// sequenceNameGenerator generates the given names in turn, repeating the last one
+type sequenceNameGenerator []string
+
+func (g *sequenceNameGenerator) GenerateName(base string) string {
+	name := (*g)[0]
+	if len(*g) > 1 {
+		*g = (*g)[1:]
+	}
+	return name
+}
+
+func TestGadgetREST_Names(t *testing.T) {
+	rest := NewGadgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+	create := func(meta metav1.ObjectMeta) (*Gadget, error) {
+		obj, err := rest.Create(ctx, &Gadget{ObjectMeta: meta, Spec: GadgetSpec{Type: "sensor", Version: "1.0.0"}}, nil, &metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return obj.(*Gadget), nil
+	}
+
+	if _, err := create(metav1.ObjectMeta{}); !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid without name or generateName, got %v", err)
+	}
+	if _, err := create(metav1.ObjectMeta{Name: "My_Gadget"}); !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid for a name that is not a DNS subdomain, got %v", err)
+	}
+	if _, err := create(metav1.ObjectMeta{Name: "taken"}); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+
+	// A generated name that is taken is replaced by another one
+	rest.strategy.NameGenerator = &sequenceNameGenerator{"taken", "taken", "free"}
+	created, err := create(metav1.ObjectMeta{GenerateName: "gadget-"})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	if created.Name != "free" || created.GenerateName != "gadget-" {
+		t.Errorf("Expected the gadget to be named 'free', got %q", created.Name)
+	}
+
+	// Eventually the collision is reported
+	rest.strategy.NameGenerator = &sequenceNameGenerator{"taken"}
+	if _, err := create(metav1.ObjectMeta{GenerateName: "gadget-"}); !errors.IsAlreadyExists(err) {
+		t.Errorf("Expected AlreadyExists once no generated name is free, got %v", err)
+	}
+}
+
+func TestGadgetREST_DryRun(t *testing.T) {
+	storage := NewGadgetStorage()
+	rest := NewGadgetRESTWithStorage(storage, Strategy)
+	status := NewStatusREST(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+	dryRun := []string{metav1.DryRunAll}
+
+	w, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		t.Fatalf("Failed to watch gadgets: %v", err)
+	}
+	defer w.Stop()
+
+	// A dry-run create returns the gadget as it would be stored
+	obj, err := rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "gadget-"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{DryRun: dryRun})
+	if err != nil {
+		t.Fatalf("Failed to dry-run create: %v", err)
+	}
+	would := obj.(*Gadget)
+	if would.Name == "" || would.UID == "" || would.Generation != 1 || would.Status.State != "Active" {
+		t.Errorf("Expected the server populated fields, got %+v", would)
+	}
+	if _, err := rest.Get(ctx, would.Name, &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected a dry-run create not to store the gadget, got %v", err)
+	}
+	_, _, err = rest.Update(ctx, "applied", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Spec = GadgetSpec{Type: "sensor", Version: "1.0.0"} },
+	}, nil, nil, true, &metav1.UpdateOptions{DryRun: dryRun})
+	if err != nil {
+		t.Fatalf("Failed to dry-run create on update: %v", err)
+	}
+	if _, err := rest.Get(ctx, "applied", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected a dry-run create on update not to store the gadget, got %v", err)
+	}
+
+	obj, err = rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	created := obj.(*Gadget)
+	if created.ResourceVersion != "1" {
+		t.Errorf("Expected dry runs not to use up resourceVersions, got %s", created.ResourceVersion)
+	}
+	_, err = rest.Create(ctx, &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-gadget"},
+		Spec:       GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}, nil, &metav1.CreateOptions{DryRun: dryRun})
+	if !errors.IsAlreadyExists(err) {
+		t.Errorf("Expected a dry-run create of a taken name to fail, got %v", err)
+	}
+
+	// Dry-run updates return the result, including conflicts, without storing it
+	obj, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Spec.Priority = 3 },
+	}, nil, nil, false, &metav1.UpdateOptions{DryRun: dryRun})
+	if err != nil {
+		t.Fatalf("Failed to dry-run update: %v", err)
+	}
+	if updated := obj.(*Gadget); updated.Spec.Priority != 3 || updated.Generation != 2 {
+		t.Errorf("Expected the updated spec and generation, got %+v", updated)
+	}
+	_, _, err = rest.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.ResourceVersion = "0" },
+	}, nil, nil, false, &metav1.UpdateOptions{DryRun: dryRun})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected a dry-run update with a stale resourceVersion to conflict, got %v", err)
+	}
+	if _, _, err := status.Update(ctx, "test-gadget", &testUpdateInfo{
+		update: func(gadget *Gadget) { gadget.Status.State = "Ready" },
+	}, nil, nil, false, &metav1.UpdateOptions{DryRun: dryRun}); err != nil {
+		t.Fatalf("Failed to dry-run status update: %v", err)
+	}
+
+	current, err := storage.Get(context.Background(), "default", "test-gadget")
+	if err != nil {
+		t.Fatalf("Failed to get gadget: %v", err)
+	}
+	if !reflect.DeepEqual(current, created) {
+		t.Errorf("Expected dry runs to leave the gadget unchanged, got %+v", current)
+	}
+
+	// Watchers only saw the real create
+	select {
+	case event := <-w.ResultChan():
+		if event.Type != watch.Added || event.Object.(*Gadget).Name != "test-gadget" {
+			t.Errorf("Expected only the ADDED event for test-gadget, got %s for %s", event.Type, event.Object.(*Gadget).Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the ADDED event")
+	}
+	select {
+	case event := <-w.ResultChan():
+		t.Errorf("Expected no events for dry runs, got %s", event.Type)
+	default:
+	}
+}
@@ -0,0 +1,19 @@
+// Package install registers every version of the gadget API with a scheme
+package install
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/gadgets/v1alpha1"
+	"example.com/mytest-apiserver/pkg/apis/gadgets/v1beta1"
+)
+
+// Install registers the internal and served versions of gadgets with scheme, preferring v1beta1
+func Install(scheme *runtime.Scheme) {
+	utilruntime.Must(gadgets.AddToScheme(scheme))
+	utilruntime.Must(v1beta1.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	utilruntime.Must(scheme.SetVersionPriority(v1beta1.SchemeGroupVersion, v1alpha1.SchemeGroupVersion))
+}
@@ -0,0 +1,57 @@
+package gadgets
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// GadgetLister lists gadgets from storage, for admission plugins that need to look at the
+// gadgets other than the one they admit
+type GadgetLister interface {
+	// List returns the gadgets of every namespace that match selector
+	List(selector labels.Selector) ([]*Gadget, error)
+	// Gadgets returns a lister of the gadgets in namespace
+	Gadgets(namespace string) GadgetNamespaceLister
+}
+
+// GadgetNamespaceLister lists the gadgets in one namespace
+type GadgetNamespaceLister interface {
+	// List returns the gadgets in the namespace that match selector
+	List(selector labels.Selector) ([]*Gadget, error)
+	// Get returns the gadget called name in the namespace
+	Get(name string) (*Gadget, error)
+}
+
+// NewLister returns a lister of the gadgets in storage. Unlike an informer's lister it reads
+// storage directly, so it always sees the latest gadgets.
+func NewLister(storage Storage) GadgetLister {
+	return &gadgetLister{storage: storage}
+}
+
+// +k8s:openapi-gen=false
+type gadgetLister struct {
+	storage   Storage
+	namespace string
+}
+
+func (l *gadgetLister) List(selector labels.Selector) ([]*Gadget, error) {
+	list, err := l.storage.List(context.TODO(), l.namespace, &internalversion.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	gadgets := make([]*Gadget, len(list.Items))
+	for i := range list.Items {
+		gadgets[i] = &list.Items[i]
+	}
+	return gadgets, nil
+}
+
+func (l *gadgetLister) Gadgets(namespace string) GadgetNamespaceLister {
+	return &gadgetLister{storage: l.storage, namespace: namespace}
+}
+
+func (l *gadgetLister) Get(name string) (*Gadget, error) {
+	return l.storage.Get(context.TODO(), l.namespace, name)
+}
@@ -0,0 +1,69 @@
+package gadgets
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// SelectableFields returns the fields a gadget can be selected by
+func SelectableFields(gadget *Gadget) fields.Set {
+	return generic.AddObjectMetaFieldsSet(fields.Set{
+		"spec.type":    gadget.Spec.Type,
+		"spec.enabled": strconv.FormatBool(gadget.Spec.Enabled),
+		"status.state": gadget.Status.State,
+	}, &gadget.ObjectMeta, true)
+}
+
+// GetAttrs returns the labels and fields of a gadget for selector matching
+func GetAttrs(obj runtime.Object) (labels.Set, fields.Set, error) {
+	gadget, ok := obj.(*Gadget)
+	if !ok {
+		return nil, nil, fmt.Errorf("not a gadget: %T", obj)
+	}
+	return gadget.Labels, SelectableFields(gadget), nil
+}
+
+// ConvertFieldLabel is the field label conversion func registered with the scheme; it
+// rejects fields gadgets cannot be selected by
+func ConvertFieldLabel(label, value string) (string, string, error) {
+	switch label {
+	case "metadata.name", "metadata.namespace", "spec.type", "spec.enabled", "status.state":
+		return label, value, nil
+	default:
+		return "", "", fmt.Errorf("field label not supported for gadgets: %s", label)
+	}
+}
+
+// Predicate returns the selection predicate for the selectors and pagination in options
+func Predicate(options *internalversion.ListOptions) (storage.SelectionPredicate, error) {
+	predicate := storage.SelectionPredicate{
+		Label:    labels.Everything(),
+		Field:    fields.Everything(),
+		GetAttrs: GetAttrs,
+	}
+	if options == nil {
+		return predicate, nil
+	}
+	predicate.Limit = options.Limit
+	predicate.Continue = options.Continue
+	if options.LabelSelector != nil {
+		predicate.Label = options.LabelSelector
+	}
+	if options.FieldSelector != nil {
+		for _, requirement := range options.FieldSelector.Requirements() {
+			if _, _, err := ConvertFieldLabel(requirement.Field, requirement.Value); err != nil {
+				return predicate, errors.NewBadRequest(err.Error())
+			}
+		}
+		predicate.Field = options.FieldSelector
+	}
+	return predicate, nil
+}
@@ -0,0 +1,162 @@
+package gadgets
+
+import (
+	"context"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage/names"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+
+	"example.com/mytest-apiserver/pkg/common"
+	"example.com/mytest-apiserver/pkg/rules"
+)
+
+// GadgetStrategy implements the behaviour gadgets share with upstream resources on create,
+// update and delete
+// +k8s:openapi-gen=false
+type GadgetStrategy struct {
+	runtime.ObjectTyper
+	names.NameGenerator
+
+	// validator evaluates the CEL rules of the gadget schema on create and update
+	validator *rules.Validator
+}
+
+// Strategy is the strategy GadgetREST runs through rest.BeforeCreate, rest.BeforeUpdate
+// and rest.BeforeDelete unless given another one. It only enforces the rules written in Go.
+var Strategy = NewStrategy(scheme, nil)
+
+var _ rest.RESTCreateStrategy = Strategy
+var _ rest.RESTUpdateStrategy = Strategy
+var _ rest.RESTDeleteStrategy = Strategy
+var _ rest.ResetFieldsStrategy = Strategy
+
+// NewStrategy returns the gadget strategy for objects typed by typer, which evaluates the CEL
+// rules of the gadget schema with validator. The schema is generated from the versioned packages,
+// which import this one, so callers compile the validator from it. Without one only the rules
+// written in Go are enforced.
+func NewStrategy(typer runtime.ObjectTyper, validator *rules.Validator) GadgetStrategy {
+	return GadgetStrategy{typer, names.SimpleNameGenerator, validator}
+}
+
+// validateRules returns the rules of the gadget schema obj fails, given old on update
+func (s GadgetStrategy) validateRules(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	if s.validator == nil {
+		return nil
+	}
+	return s.validator.Validate(ctx, obj, old)
+}
+
+// servedVersions are the versions server-side apply tracks the fields of gadgets in. The
+// versioned packages import this one, so their group versions cannot be used here.
+var servedVersions = []fieldpath.APIVersion{
+	common.GroupName + "/v1alpha1",
+	common.GroupName + "/v1beta1",
+}
+
+// resetFields returns paths for every served version
+func resetFields(paths ...fieldpath.Path) map[fieldpath.APIVersion]*fieldpath.Set {
+	fields := make(map[fieldpath.APIVersion]*fieldpath.Set, len(servedVersions))
+	for _, version := range servedVersions {
+		fields[version] = fieldpath.NewSet(paths...)
+	}
+	return fields
+}
+
+// GetResetFields returns the fields PrepareForUpdate resets
+func (GadgetStrategy) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return resetFields(fieldpath.MakePathOrDie("status"))
+}
+
+func (GadgetStrategy) NamespaceScoped() bool {
+	return true
+}
+
+// PrepareForCreate populates the fields the server owns on a new gadget
+func (GadgetStrategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	gadget := obj.(*Gadget)
+	gadget.Generation = 1
+	gadget.Status = GadgetStatus{State: "Active"}
+}
+
+// PrepareForUpdate keeps the status of old, which is only updated through the status
+// subresource, and bumps the generation if the spec changed
+func (GadgetStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	gadget, oldGadget := obj.(*Gadget), old.(*Gadget)
+	gadget.Status = oldGadget.Status
+	if !apiequality.Semantic.DeepEqual(gadget.Spec, oldGadget.Spec) {
+		gadget.Generation = oldGadget.Generation + 1
+	}
+}
+
+func (s GadgetStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	allErrs := ValidateGadget(obj.(*Gadget))
+	return append(allErrs, s.validateRules(ctx, obj, nil)...)
+}
+
+func (GadgetStrategy) WarningsOnCreate(ctx context.Context, obj runtime.Object) []string {
+	return nil
+}
+
+func (GadgetStrategy) AllowCreateOnUpdate() bool {
+	return false
+}
+
+// AllowUnconditionalUpdate is true since updates without resourceVersion are retried
+// against the latest gadget
+func (GadgetStrategy) AllowUnconditionalUpdate() bool {
+	return true
+}
+
+func (GadgetStrategy) Canonicalize(obj runtime.Object) {
+}
+
+func (s GadgetStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	allErrs := ValidateGadgetUpdate(obj.(*Gadget), old.(*Gadget))
+	return append(allErrs, s.validateRules(ctx, obj, old)...)
+}
+
+func (GadgetStrategy) WarningsOnUpdate(ctx context.Context, obj, old runtime.Object) []string {
+	return nil
+}
+
+// GadgetStatusStrategy is the strategy of the gadgets/status subresource
+// +k8s:openapi-gen=false
+type GadgetStatusStrategy struct {
+	GadgetStrategy
+}
+
+var _ rest.RESTUpdateStrategy = GadgetStatusStrategy{}
+var _ rest.ResetFieldsStrategy = GadgetStatusStrategy{}
+
+// NewStatusStrategy returns the strategy of the status subresource of gadgets handled by strategy
+func NewStatusStrategy(strategy GadgetStrategy) GadgetStatusStrategy {
+	return GadgetStatusStrategy{strategy}
+}
+
+// GetResetFields returns the fields PrepareForUpdate resets
+func (GadgetStatusStrategy) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return resetFields(
+		fieldpath.MakePathOrDie("spec"),
+		fieldpath.MakePathOrDie("metadata", "labels"),
+		fieldpath.MakePathOrDie("metadata", "annotations"),
+		fieldpath.MakePathOrDie("metadata", "finalizers"),
+		fieldpath.MakePathOrDie("metadata", "ownerReferences"),
+	)
+}
+
+// PrepareForUpdate keeps everything of old but the status, including the metadata users own
+func (GadgetStatusStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	gadget, oldGadget := obj.(*Gadget), old.(*Gadget)
+	gadget.Spec = oldGadget.Spec
+	metav1.ResetObjectMetaForStatus(gadget, oldGadget)
+}
+
+func (s GadgetStatusStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	allErrs := ValidateGadgetStatusUpdate(obj.(*Gadget), old.(*Gadget))
+	return append(allErrs, s.validateRules(ctx, obj, old)...)
+}
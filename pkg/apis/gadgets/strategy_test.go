@@ -0,0 +1,46 @@
+package gadgets_test
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/gadgets/install"
+	"example.com/mytest-apiserver/pkg/apis/gadgets/v1beta1"
+	generatedopenapi "example.com/mytest-apiserver/pkg/generated/openapi"
+	"example.com/mytest-apiserver/pkg/rules"
+)
+
+func TestStrategy_ValidationRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	validator, err := rules.NewValidator(rules.NewSchemaResolver(generatedopenapi.GetOpenAPIDefinitions, scheme),
+		scheme, v1beta1.SchemeGroupVersion.WithKind("Gadget"))
+	if err != nil {
+		t.Fatalf("Failed to compile the gadget validation rules: %v", err)
+	}
+	strategy := gadgets.NewStrategy(scheme, validator)
+	ctx := context.Background()
+
+	gadget := &gadgets.Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "g", Namespace: "default"},
+		Spec:       gadgets.GadgetSpec{Type: "sensor", Version: "1.0.0", Enabled: true, Priority: 5},
+	}
+	if errs := strategy.Validate(ctx, gadget); len(errs) != 0 {
+		t.Errorf("Expected an enabled gadget with a priority to be valid, got %v", errs)
+	}
+
+	gadget.Spec.Enabled = false
+	errs := strategy.Validate(ctx, gadget)
+	if len(errs) != 1 || errs[0].Field != "spec.priority" || errs[0].Detail != "must be 0 when enabled is false" {
+		t.Errorf("Expected the rule to reject a disabled gadget with a priority, got %v", errs)
+	}
+
+	// Without a validator only the rules written in Go are enforced
+	if errs := gadgets.NewStrategy(scheme, nil).Validate(ctx, gadget); len(errs) != 0 {
+		t.Errorf("Expected no rules to be evaluated without a validator, got %v", errs)
+	}
+}
@@ -0,0 +1,12 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+)
+
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	// Field selectors are checked against these before reaching storage
+	return scheme.AddFieldLabelConversionFunc(SchemeGroupVersion.WithKind("Gadget"), gadgets.ConvertFieldLabel)
+}
@@ -0,0 +1,208 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by conversion-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	unsafe "unsafe"
+
+	gadgets "example.com/mytest-apiserver/pkg/apis/gadgets"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	conversion "k8s.io/apimachinery/pkg/conversion"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+func init() {
+	localSchemeBuilder.Register(RegisterConversions)
+}
+
+// RegisterConversions adds conversion functions to the given scheme.
+// Public to allow building arbitrary schemes.
+func RegisterConversions(s *runtime.Scheme) error {
+	if err := s.AddGeneratedConversionFunc((*Gadget)(nil), (*gadgets.Gadget)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_Gadget_To_gadgets_Gadget(a.(*Gadget), b.(*gadgets.Gadget), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*gadgets.Gadget)(nil), (*Gadget)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_gadgets_Gadget_To_v1alpha1_Gadget(a.(*gadgets.Gadget), b.(*Gadget), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*GadgetList)(nil), (*gadgets.GadgetList)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_GadgetList_To_gadgets_GadgetList(a.(*GadgetList), b.(*gadgets.GadgetList), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*gadgets.GadgetList)(nil), (*GadgetList)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_gadgets_GadgetList_To_v1alpha1_GadgetList(a.(*gadgets.GadgetList), b.(*GadgetList), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*GadgetSpec)(nil), (*gadgets.GadgetSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_GadgetSpec_To_gadgets_GadgetSpec(a.(*GadgetSpec), b.(*gadgets.GadgetSpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*gadgets.GadgetSpec)(nil), (*GadgetSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_gadgets_GadgetSpec_To_v1alpha1_GadgetSpec(a.(*gadgets.GadgetSpec), b.(*GadgetSpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*GadgetStatus)(nil), (*gadgets.GadgetStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_GadgetStatus_To_gadgets_GadgetStatus(a.(*GadgetStatus), b.(*gadgets.GadgetStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*gadgets.GadgetStatus)(nil), (*GadgetStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_gadgets_GadgetStatus_To_v1alpha1_GadgetStatus(a.(*gadgets.GadgetStatus), b.(*GadgetStatus), scope)
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func autoConvert_v1alpha1_Gadget_To_gadgets_Gadget(in *Gadget, out *gadgets.Gadget, s conversion.Scope) error {
+	out.ObjectMeta = in.ObjectMeta
+	if err := Convert_v1alpha1_GadgetSpec_To_gadgets_GadgetSpec(&in.Spec, &out.Spec, s); err != nil {
+		return err
+	}
+	if err := Convert_v1alpha1_GadgetStatus_To_gadgets_GadgetStatus(&in.Status, &out.Status, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Convert_v1alpha1_Gadget_To_gadgets_Gadget is an autogenerated conversion function.
+func Convert_v1alpha1_Gadget_To_gadgets_Gadget(in *Gadget, out *gadgets.Gadget, s conversion.Scope) error {
+	return autoConvert_v1alpha1_Gadget_To_gadgets_Gadget(in, out, s)
+}
+
+func autoConvert_gadgets_Gadget_To_v1alpha1_Gadget(in *gadgets.Gadget, out *Gadget, s conversion.Scope) error {
+	out.ObjectMeta = in.ObjectMeta
+	if err := Convert_gadgets_GadgetSpec_To_v1alpha1_GadgetSpec(&in.Spec, &out.Spec, s); err != nil {
+		return err
+	}
+	if err := Convert_gadgets_GadgetStatus_To_v1alpha1_GadgetStatus(&in.Status, &out.Status, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Convert_gadgets_Gadget_To_v1alpha1_Gadget is an autogenerated conversion function.
+func Convert_gadgets_Gadget_To_v1alpha1_Gadget(in *gadgets.Gadget, out *Gadget, s conversion.Scope) error {
+	return autoConvert_gadgets_Gadget_To_v1alpha1_Gadget(in, out, s)
+}
+
+func autoConvert_v1alpha1_GadgetList_To_gadgets_GadgetList(in *GadgetList, out *gadgets.GadgetList, s conversion.Scope) error {
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]gadgets.Gadget, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha1_Gadget_To_gadgets_Gadget(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	return nil
+}
+
+// Convert_v1alpha1_GadgetList_To_gadgets_GadgetList is an autogenerated conversion function.
+func Convert_v1alpha1_GadgetList_To_gadgets_GadgetList(in *GadgetList, out *gadgets.GadgetList, s conversion.Scope) error {
+	return autoConvert_v1alpha1_GadgetList_To_gadgets_GadgetList(in, out, s)
+}
+
+func autoConvert_gadgets_GadgetList_To_v1alpha1_GadgetList(in *gadgets.GadgetList, out *GadgetList, s conversion.Scope) error {
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Gadget, len(*in))
+		for i := range *in {
+			if err := Convert_gadgets_Gadget_To_v1alpha1_Gadget(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	return nil
+}
+
+// Convert_gadgets_GadgetList_To_v1alpha1_GadgetList is an autogenerated conversion function.
+func Convert_gadgets_GadgetList_To_v1alpha1_GadgetList(in *gadgets.GadgetList, out *GadgetList, s conversion.Scope) error {
+	return autoConvert_gadgets_GadgetList_To_v1alpha1_GadgetList(in, out, s)
+}
+
+func autoConvert_v1alpha1_GadgetSpec_To_gadgets_GadgetSpec(in *GadgetSpec, out *gadgets.GadgetSpec, s conversion.Scope) error {
+	out.Type = in.Type
+	out.Version = in.Version
+	if err := v1.Convert_Pointer_bool_To_bool(&in.Enabled, &out.Enabled, s); err != nil {
+		return err
+	}
+	out.Priority = in.Priority
+	return nil
+}
+
+// Convert_v1alpha1_GadgetSpec_To_gadgets_GadgetSpec is an autogenerated conversion function.
+func Convert_v1alpha1_GadgetSpec_To_gadgets_GadgetSpec(in *GadgetSpec, out *gadgets.GadgetSpec, s conversion.Scope) error {
+	return autoConvert_v1alpha1_GadgetSpec_To_gadgets_GadgetSpec(in, out, s)
+}
+
+func autoConvert_gadgets_GadgetSpec_To_v1alpha1_GadgetSpec(in *gadgets.GadgetSpec, out *GadgetSpec, s conversion.Scope) error {
+	out.Type = in.Type
+	out.Version = in.Version
+	if err := v1.Convert_bool_To_Pointer_bool(&in.Enabled, &out.Enabled, s); err != nil {
+		return err
+	}
+	out.Priority = in.Priority
+	return nil
+}
+
+// Convert_gadgets_GadgetSpec_To_v1alpha1_GadgetSpec is an autogenerated conversion function.
+func Convert_gadgets_GadgetSpec_To_v1alpha1_GadgetSpec(in *gadgets.GadgetSpec, out *GadgetSpec, s conversion.Scope) error {
+	return autoConvert_gadgets_GadgetSpec_To_v1alpha1_GadgetSpec(in, out, s)
+}
+
+func autoConvert_v1alpha1_GadgetStatus_To_gadgets_GadgetStatus(in *GadgetStatus, out *gadgets.GadgetStatus, s conversion.Scope) error {
+	out.State = in.State
+	out.ObservedGeneration = in.ObservedGeneration
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
+	return nil
+}
+
+// Convert_v1alpha1_GadgetStatus_To_gadgets_GadgetStatus is an autogenerated conversion function.
+func Convert_v1alpha1_GadgetStatus_To_gadgets_GadgetStatus(in *GadgetStatus, out *gadgets.GadgetStatus, s conversion.Scope) error {
+	return autoConvert_v1alpha1_GadgetStatus_To_gadgets_GadgetStatus(in, out, s)
+}
+
+func autoConvert_gadgets_GadgetStatus_To_v1alpha1_GadgetStatus(in *gadgets.GadgetStatus, out *GadgetStatus, s conversion.Scope) error {
+	out.State = in.State
+	out.ObservedGeneration = in.ObservedGeneration
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
+	return nil
+}
+
+// Convert_gadgets_GadgetStatus_To_v1alpha1_GadgetStatus is an autogenerated conversion function.
+func Convert_gadgets_GadgetStatus_To_v1alpha1_GadgetStatus(in *gadgets.GadgetStatus, out *GadgetStatus, s conversion.Scope) error {
+	return autoConvert_gadgets_GadgetStatus_To_v1alpha1_GadgetStatus(in, out, s)
+}
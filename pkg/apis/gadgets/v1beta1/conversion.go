@@ -0,0 +1,135 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+)
+
+// VersionAnnotation holds the version of a gadget that is not a semantic version, such as v1.0
+// stored before versions were validated, which v1beta1 serves as the closest semantic version.
+// Updates through v1beta1 that leave that version alone keep the original one.
+const VersionAnnotation = "gadgets.things.myorg.io/version"
+
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	// Field selectors are checked against these before reaching storage
+	return scheme.AddFieldLabelConversionFunc(SchemeGroupVersion.WithKind("Gadget"), gadgets.ConvertFieldLabel)
+}
+
+func Convert_gadgets_Gadget_To_v1beta1_Gadget(in *gadgets.Gadget, out *Gadget, s conversion.Scope) error {
+	if err := autoConvert_gadgets_Gadget_To_v1beta1_Gadget(in, out, s); err != nil {
+		return err
+	}
+	_, exact := parseVersion(in.Spec.Version)
+	_, annotated := in.Annotations[VersionAnnotation]
+	if exact && !annotated {
+		return nil
+	}
+	out.Annotations = withoutVersionAnnotation(in.Annotations)
+	if !exact {
+		if out.Annotations == nil {
+			out.Annotations = make(map[string]string, 1)
+		}
+		out.Annotations[VersionAnnotation] = in.Spec.Version
+	}
+	return nil
+}
+
+func Convert_v1beta1_Gadget_To_gadgets_Gadget(in *Gadget, out *gadgets.Gadget, s conversion.Scope) error {
+	if err := autoConvert_v1beta1_Gadget_To_gadgets_Gadget(in, out, s); err != nil {
+		return err
+	}
+	value, annotated := in.Annotations[VersionAnnotation]
+	if !annotated {
+		return nil
+	}
+	out.Annotations = withoutVersionAnnotation(in.Annotations)
+	if version, _ := parseVersion(value); formatVersion(version) == out.Spec.Version {
+		out.Spec.Version = value
+	}
+	return nil
+}
+
+func Convert_gadgets_GadgetSpec_To_v1beta1_GadgetSpec(in *gadgets.GadgetSpec, out *GadgetSpec, s conversion.Scope) error {
+	if err := autoConvert_gadgets_GadgetSpec_To_v1beta1_GadgetSpec(in, out, s); err != nil {
+		return err
+	}
+	out.Version, _ = parseVersion(in.Version)
+	return nil
+}
+
+// Convert_v1beta1_GadgetSpec_To_gadgets_GadgetSpec formats the version as a semantic version;
+// validation rejects any part that makes it an invalid one
+func Convert_v1beta1_GadgetSpec_To_gadgets_GadgetSpec(in *GadgetSpec, out *gadgets.GadgetSpec, s conversion.Scope) error {
+	if err := autoConvert_v1beta1_GadgetSpec_To_gadgets_GadgetSpec(in, out, s); err != nil {
+		return err
+	}
+	out.Version = formatVersion(in.Version)
+	return nil
+}
+
+// parseVersion returns the parts of version, and whether they are exactly version. A version
+// that is not a semantic version, such as v1.0, is read as the closest one, here 1.0.0, and one
+// that cannot be read at all, such as latest, as none.
+func parseVersion(version string) (*GadgetVersion, bool) {
+	if version == "" {
+		return nil, true
+	}
+	parsed, err := semver.Parse(version)
+	exact := err == nil
+	if !exact {
+		if parsed, err = semver.ParseTolerant(version); err != nil {
+			return nil, false
+		}
+	}
+	out := &GadgetVersion{
+		Major: int64(parsed.Major),
+		Minor: int64(parsed.Minor),
+		Patch: int64(parsed.Patch),
+		Build: strings.Join(parsed.Build, "."),
+	}
+	if len(parsed.Pre) > 0 {
+		pre := make([]string, len(parsed.Pre))
+		for i := range parsed.Pre {
+			pre[i] = parsed.Pre[i].String()
+		}
+		out.PreRelease = strings.Join(pre, ".")
+	}
+	return out, exact
+}
+
+// formatVersion returns version as a semantic version, or nothing if it is nil
+func formatVersion(version *GadgetVersion) string {
+	if version == nil {
+		return ""
+	}
+	out := fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
+	if version.PreRelease != "" {
+		out += "-" + version.PreRelease
+	}
+	if version.Build != "" {
+		out += "+" + version.Build
+	}
+	return out
+}
+
+// withoutVersionAnnotation returns a copy of annotations without VersionAnnotation, or nil if
+// nothing else is left; annotations are shared with the object converted from
+func withoutVersionAnnotation(annotations map[string]string) map[string]string {
+	var out map[string]string
+	for key, value := range annotations {
+		if key == VersionAnnotation {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(annotations))
+		}
+		out[key] = value
+	}
+	return out
+}
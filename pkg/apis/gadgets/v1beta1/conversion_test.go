@@ -0,0 +1,111 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+)
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(gadgets.AddToScheme(scheme))
+	utilruntime.Must(AddToScheme(scheme))
+	return scheme
+}
+
+func TestConvertGadget_Version(t *testing.T) {
+	scheme := newTestScheme()
+	for _, tc := range []struct {
+		version  string
+		expected *GadgetVersion
+	}{
+		{"", nil},
+		{"1.2.3", &GadgetVersion{Major: 1, Minor: 2, Patch: 3}},
+		{"1.0.0-rc.1+build.5", &GadgetVersion{Major: 1, PreRelease: "rc.1", Build: "build.5"}},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			external := &Gadget{}
+			if err := scheme.Convert(&gadgets.Gadget{Spec: gadgets.GadgetSpec{Version: tc.version}}, external, nil); err != nil {
+				t.Fatalf("Failed to convert to v1beta1: %v", err)
+			}
+			if !reflect.DeepEqual(external.Spec.Version, tc.expected) {
+				t.Errorf("Expected version %+v, got %+v", tc.expected, external.Spec.Version)
+			}
+
+			internal := &gadgets.Gadget{}
+			if err := scheme.Convert(external, internal, nil); err != nil {
+				t.Fatalf("Failed to convert from v1beta1: %v", err)
+			}
+			if internal.Spec.Version != tc.version {
+				t.Errorf("Expected version %q after a round trip, got %q", tc.version, internal.Spec.Version)
+			}
+		})
+	}
+}
+
+func TestConvertGadget_StoredVersion(t *testing.T) {
+	scheme := newTestScheme()
+	// Versions stored before they were validated are served as the closest semantic version,
+	// if any, and kept in an annotation
+	for _, tc := range []struct {
+		version  string
+		expected *GadgetVersion
+	}{
+		{"v1.0", &GadgetVersion{Major: 1}},
+		{"2", &GadgetVersion{Major: 2}},
+		{"latest", nil},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			internal := &gadgets.Gadget{
+				ObjectMeta: metav1.ObjectMeta{Name: "gadget", Annotations: map[string]string{"team": "foo"}},
+				Spec:       gadgets.GadgetSpec{Version: tc.version},
+			}
+			external := &Gadget{}
+			if err := scheme.Convert(internal, external, nil); err != nil {
+				t.Fatalf("Failed to convert to v1beta1: %v", err)
+			}
+			if !reflect.DeepEqual(external.Spec.Version, tc.expected) {
+				t.Errorf("Expected version %+v, got %+v", tc.expected, external.Spec.Version)
+			}
+			if external.Annotations[VersionAnnotation] != tc.version || external.Annotations["team"] != "foo" {
+				t.Errorf("Expected version annotation %q next to the others, got %v", tc.version, external.Annotations)
+			}
+			if _, ok := internal.Annotations[VersionAnnotation]; ok {
+				t.Errorf("Expected the internal gadget to be left alone, got %v", internal.Annotations)
+			}
+
+			// Round trips through v1beta1 keep the stored version
+			back := &gadgets.Gadget{}
+			if err := scheme.Convert(external, back, nil); err != nil {
+				t.Fatalf("Failed to convert from v1beta1: %v", err)
+			}
+			if back.Spec.Version != tc.version {
+				t.Errorf("Expected version %q after a round trip, got %q", tc.version, back.Spec.Version)
+			}
+			if _, ok := back.Annotations[VersionAnnotation]; ok || back.Annotations["team"] != "foo" {
+				t.Errorf("Expected only the version annotation to be dropped, got %v", back.Annotations)
+			}
+		})
+	}
+}
+
+func TestConvertGadget_StoredVersionChanged(t *testing.T) {
+	scheme := newTestScheme()
+	external := &Gadget{
+		ObjectMeta: metav1.ObjectMeta{Name: "gadget", Annotations: map[string]string{VersionAnnotation: "v1.0"}},
+		Spec:       GadgetSpec{Version: &GadgetVersion{Major: 1, Minor: 1}},
+	}
+	internal := &gadgets.Gadget{}
+	if err := scheme.Convert(external, internal, nil); err != nil {
+		t.Fatalf("Failed to convert from v1beta1: %v", err)
+	}
+	if internal.Spec.Version != "1.1.0" || internal.Annotations != nil {
+		t.Errorf("Expected the new version to replace the annotated one, got %q and %v",
+			internal.Spec.Version, internal.Annotations)
+	}
+}
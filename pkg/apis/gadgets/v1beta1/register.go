@@ -0,0 +1,27 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"example.com/mytest-apiserver/pkg/common"
+)
+
+// SchemeGroupVersion is the group version gadgets are served in
+var SchemeGroupVersion = schema.GroupVersion{Group: common.GroupName, Version: "v1beta1"}
+
+var (
+	SchemeBuilder      runtime.SchemeBuilder
+	localSchemeBuilder = &SchemeBuilder
+	AddToScheme        = localSchemeBuilder.AddToScheme
+)
+
+func init() {
+	// The generated conversions and defaulters register themselves with localSchemeBuilder
+	localSchemeBuilder.Register(addKnownTypes, addConversionFuncs, RegisterDefaults)
+}
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &Gadget{}, &GadgetList{})
+	return nil
+}
@@ -0,0 +1,87 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Gadget represents a sample gadget resource
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Gadget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec defines the desired state of Gadget
+	Spec GadgetSpec `json:"spec,omitempty"`
+
+	// Status defines the observed state of Gadget
+	Status GadgetStatus `json:"status,omitempty"`
+}
+
+// GadgetSpec defines the desired state of Gadget
+// +k8s:validation:cel[0]:rule="self.enabled || self.priority == 0"
+// +k8s:validation:cel[0]:message="must be 0 when enabled is false"
+// +k8s:validation:cel[0]:fieldPath=".priority"
+type GadgetSpec struct {
+	// Type specifies the type of gadget
+	Type string `json:"type"`
+
+	// Version specifies the version of the gadget
+	Version *GadgetVersion `json:"version,omitempty"`
+
+	// Enabled indicates whether the gadget is enabled. Gadgets are enabled unless this is set to
+	// false.
+	// +optional
+	// +default=true
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Priority sets the priority of the gadget, 0 unless set
+	// +optional
+	// +default=0
+	Priority int32 `json:"priority"`
+}
+
+// GadgetVersion is a semantic version, such as 1.2.3-rc.1+build.5
+type GadgetVersion struct {
+	// Major is the major version
+	Major int64 `json:"major"`
+
+	// Minor is the minor version
+	Minor int64 `json:"minor"`
+
+	// Patch is the patch version
+	Patch int64 `json:"patch"`
+
+	// PreRelease is the dot-separated pre-release version, such as rc.1
+	// +optional
+	PreRelease string `json:"preRelease,omitempty"`
+
+	// Build is the dot-separated build metadata, such as build.5
+	// +optional
+	Build string `json:"build,omitempty"`
+}
+
+// GadgetStatus defines the observed state of Gadget
+type GadgetStatus struct {
+	// State indicates the current state of the gadget
+	State string `json:"state,omitempty"`
+
+	// ObservedGeneration is the generation of the gadget the status was last reported for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report the observed condition of the gadget, such as Ready or Degraded
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// GadgetList contains a list of Gadget
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type GadgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is the list of Gadget objects
+	Items []Gadget `json:"items"`
+}
@@ -0,0 +1,51 @@
+package gadgets
+
+import (
+	"github.com/blang/semver/v4"
+	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateGadget returns the problems with a new gadget. Its name must be a DNS-1123 subdomain.
+func ValidateGadget(gadget *Gadget) field.ErrorList {
+	allErrs := apimachineryvalidation.ValidateObjectMeta(&gadget.ObjectMeta, true,
+		apimachineryvalidation.NameIsDNSSubdomain, field.NewPath("metadata"))
+	return append(allErrs, validateGadgetSpec(&gadget.Spec, nil, field.NewPath("spec"))...)
+}
+
+// ValidateGadgetUpdate returns the problems with a gadget replacing old. Names cannot change, and
+// the rest of the metadata is validated for every resource on update. Fields left unchanged are
+// not validated again, so gadgets stored before a rule existed can still be updated and deleted.
+func ValidateGadgetUpdate(gadget, old *Gadget) field.ErrorList {
+	return validateGadgetSpec(&gadget.Spec, &old.Spec, field.NewPath("spec"))
+}
+
+// ValidateGadgetStatusUpdate returns the problems with the status of a gadget replacing old
+func ValidateGadgetStatusUpdate(gadget, old *Gadget) field.ErrorList {
+	return validateGadgetStatus(&gadget.Status, field.NewPath("status"))
+}
+
+// validateGadgetSpec validates spec, or only the fields that differ from old if it is not nil
+func validateGadgetSpec(spec, old *GadgetSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if old == nil || spec.Version != old.Version {
+		if spec.Version == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("version"), ""))
+		} else if _, err := semver.Parse(spec.Version); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("version"), spec.Version,
+				"must be a semantic version such as 1.2.3: "+err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateGadgetStatus(status *GadgetStatus, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if status.ObservedGeneration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("observedGeneration"), status.ObservedGeneration,
+			"must be greater than or equal to 0"))
+	}
+	allErrs = append(allErrs, metav1validation.ValidateConditions(status.Conditions, fldPath.Child("conditions"))...)
+	return allErrs
+}
@@ -0,0 +1,101 @@
+package gadgets
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateGadget(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		version  string
+		expected []string
+	}{
+		{"release", "1.2.3", nil},
+		{"pre-release and build", "1.2.3-rc.1+build.5", nil},
+		{"missing", "", []string{"spec.version"}},
+		{"leading v", "v1.2.3", []string{"spec.version"}},
+		{"missing patch", "1.2", []string{"spec.version"}},
+		{"not a version", "latest", []string{"spec.version"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateGadget(&Gadget{ObjectMeta: validObjectMeta, Spec: GadgetSpec{Type: "sensor", Version: tc.version}})
+			if paths := errorPaths(errs); !equalPaths(paths, tc.expected) {
+				t.Errorf("Expected errors for %v, got %v", tc.expected, errs)
+			}
+		})
+	}
+}
+
+func TestValidateGadgetUpdate(t *testing.T) {
+	old := &Gadget{ObjectMeta: validObjectMeta, Spec: GadgetSpec{Type: "sensor", Version: "v1.0"}}
+	for _, tc := range []struct {
+		name     string
+		version  string
+		expected []string
+	}{
+		// Versions stored before they were validated are kept as long as they are not changed
+		{"unchanged", "v1.0", nil},
+		{"fixed", "1.0.0", nil},
+		{"changed", "v1.1", []string{"spec.version"}},
+		{"removed", "", []string{"spec.version"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gadget := old.DeepCopy()
+			gadget.Spec.Version = tc.version
+			if paths := errorPaths(ValidateGadgetUpdate(gadget, old)); !equalPaths(paths, tc.expected) {
+				t.Errorf("Expected errors for %v, got %v", tc.expected, paths)
+			}
+		})
+	}
+}
+
+var validObjectMeta = metav1.ObjectMeta{Name: "gadget", Namespace: "default"}
+
+func TestGadgetName(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		meta     metav1.ObjectMeta
+		expected []string
+	}{
+		{"subdomain", metav1.ObjectMeta{Name: "my-gadget.example", Namespace: "default"}, nil},
+		// Names are generated before validation
+		{"generated", metav1.ObjectMeta{GenerateName: "my-gadget-", Name: "my-gadget-x7k2p", Namespace: "default"}, nil},
+		{"neither", metav1.ObjectMeta{Namespace: "default"}, []string{"metadata.name"}},
+		{"uppercase", metav1.ObjectMeta{Name: "MyGadget", Namespace: "default"}, []string{"metadata.name"}},
+		{"underscore", metav1.ObjectMeta{Name: "my_gadget", Namespace: "default"}, []string{"metadata.name"}},
+		{"invalid generateName", metav1.ObjectMeta{GenerateName: "MyGadget-", Name: "MyGadget-x7k2p", Namespace: "default"},
+			[]string{"metadata.generateName", "metadata.name"}},
+		{"too long", metav1.ObjectMeta{Name: strings.Repeat("a", 254), Namespace: "default"}, []string{"metadata.name"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateGadget(&Gadget{ObjectMeta: tc.meta, Spec: GadgetSpec{Type: "sensor", Version: "1.0.0"}})
+			if paths := errorPaths(errs); !equalPaths(paths, tc.expected) {
+				t.Errorf("Expected errors for %v, got %v", tc.expected, errs)
+			}
+		})
+	}
+}
+
+func errorPaths(errs field.ErrorList) []string {
+	var paths []string
+	for _, err := range errs {
+		paths = append(paths, err.Field)
+	}
+	return paths
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,4 +1,3 @@
-// Package widgets contains the Widget API types
-// +k8s:openapi-gen=true
-
+// Package widgets contains the internal Widget API types, which the served versions in its
+// subpackages convert to and from, and the storage and REST implementation of widgets
 package widgets
@@ -0,0 +1,135 @@
+package widgets
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/generic"
+
+	"example.com/mytest-apiserver/pkg/store"
+)
+
+var _ Storage = &EtcdStorage{}
+
+// EtcdStorage keeps widgets in etcd
+// +k8s:openapi-gen=false
+type EtcdStorage struct {
+	etcd *store.Etcd
+}
+
+func NewEtcdStorage(typer runtime.ObjectTyper, optsGetter generic.RESTOptionsGetter) (*EtcdStorage, error) {
+	etcd, err := store.NewEtcd(typer, Resource("widgets"), "widget",
+		func() runtime.Object { return &Widget{} },
+		func() runtime.Object { return &WidgetList{} },
+		GetAttrs, optsGetter)
+	if err != nil {
+		return nil, err
+	}
+	return &EtcdStorage{etcd: etcd}, nil
+}
+
+func (s *EtcdStorage) Get(ctx context.Context, namespace, name string) (*Widget, error) {
+	widget := &Widget{}
+	if err := s.etcd.Get(ctx, namespace, name, widget); err != nil {
+		return nil, err
+	}
+	return widget, nil
+}
+
+func (s *EtcdStorage) List(ctx context.Context, namespace string, options *internalversion.ListOptions) (*WidgetList, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &WidgetList{}
+	if err := s.etcd.List(ctx, namespace, predicate, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *EtcdStorage) Create(ctx context.Context, widget *Widget) (*Widget, error) {
+	out := &Widget{}
+	if err := s.etcd.Create(ctx, widget, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *EtcdStorage) Update(ctx context.Context, widget *Widget) (*Widget, error) {
+	out := &Widget{}
+	if err := s.etcd.Update(ctx, widget, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *EtcdStorage) Delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions) error {
+	return s.etcd.Delete(ctx, namespace, name, preconditions, &Widget{})
+}
+
+// DeleteCollection removes or updates the selected widgets one at a time, each conditional on
+// the resourceVersion prepare saw, as etcd storage cannot change several objects at once.
+// Widgets deleted concurrently are skipped. A widget that fails does not stop the others; the error
+// then names the widgets that were deleted and those that were not.
+func (s *EtcdStorage) DeleteCollection(ctx context.Context, namespace string, options *internalversion.ListOptions,
+	prepare func(*Widget) (store.DeleteAction, error)) (*WidgetList, error) {
+	list, err := s.List(ctx, namespace, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decide on every widget before changing any
+	actions := make([]store.DeleteAction, len(list.Items))
+	for i := range list.Items {
+		if actions[i], err = prepare(&list.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	deleted := &WidgetList{ListMeta: metav1.ListMeta{ResourceVersion: list.ResourceVersion}}
+	var deletedNames []string
+	var failed []store.DeleteFailure
+	for i := range list.Items {
+		widget := &list.Items[i]
+		name := widget.Namespace + "/" + widget.Name
+		switch actions[i] {
+		case store.RemoveObject:
+			err = s.Delete(ctx, widget.Namespace, widget.Name, &metav1.Preconditions{ResourceVersion: &widget.ResourceVersion})
+		case store.UpdateObject:
+			widget, err = s.Update(ctx, widget)
+		default:
+			err = nil
+		}
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, store.DeleteFailure{Name: name, Err: err})
+			continue
+		}
+		deleted.Items = append(deleted.Items, *widget)
+		deletedNames = append(deletedNames, name)
+	}
+	if len(failed) != 0 {
+		return nil, store.NewPartialDeleteError(Resource("widgets"), deletedNames, failed)
+	}
+	return deleted, nil
+}
+
+func (s *EtcdStorage) Watch(ctx context.Context, namespace string, options *internalversion.ListOptions) (watch.Interface, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+	return s.etcd.Watch(ctx, namespace, predicate, options)
+}
+
+func (s *EtcdStorage) Destroy() {
+	s.etcd.Destroy()
+}
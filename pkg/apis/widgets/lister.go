@@ -0,0 +1,57 @@
+package widgets
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// WidgetLister lists widgets from storage, for admission plugins that need to look at the
+// widgets other than the one they admit
+type WidgetLister interface {
+	// List returns the widgets of every namespace that match selector
+	List(selector labels.Selector) ([]*Widget, error)
+	// Widgets returns a lister of the widgets in namespace
+	Widgets(namespace string) WidgetNamespaceLister
+}
+
+// WidgetNamespaceLister lists the widgets in one namespace
+type WidgetNamespaceLister interface {
+	// List returns the widgets in the namespace that match selector
+	List(selector labels.Selector) ([]*Widget, error)
+	// Get returns the widget called name in the namespace
+	Get(name string) (*Widget, error)
+}
+
+// NewLister returns a lister of the widgets in storage. Unlike an informer's lister it reads
+// storage directly, so it always sees the latest widgets.
+func NewLister(storage Storage) WidgetLister {
+	return &widgetLister{storage: storage}
+}
+
+// +k8s:openapi-gen=false
+type widgetLister struct {
+	storage   Storage
+	namespace string
+}
+
+func (l *widgetLister) List(selector labels.Selector) ([]*Widget, error) {
+	list, err := l.storage.List(context.TODO(), l.namespace, &internalversion.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	widgets := make([]*Widget, len(list.Items))
+	for i := range list.Items {
+		widgets[i] = &list.Items[i]
+	}
+	return widgets, nil
+}
+
+func (l *widgetLister) Widgets(namespace string) WidgetNamespaceLister {
+	return &widgetLister{storage: l.storage, namespace: namespace}
+}
+
+func (l *widgetLister) Get(name string) (*Widget, error) {
+	return l.storage.Get(context.TODO(), l.namespace, name)
+}
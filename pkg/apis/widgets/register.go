@@ -0,0 +1,34 @@
+package widgets
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
+	"example.com/mytest-apiserver/pkg/common"
+)
+
+// SchemeGroupVersion is the internal version of the group widgets are converted to and stored from
+var SchemeGroupVersion = schema.GroupVersion{Group: common.GroupName, Version: runtime.APIVersionInternal}
+
+var (
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	AddToScheme   = SchemeBuilder.AddToScheme
+)
+
+// Resource returns the group-qualified resource, as used in errors
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
+// scheme types the widgets handled by Strategy
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(AddToScheme(scheme))
+}
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion, &Widget{}, &WidgetList{})
+	return nil
+}
@@ -0,0 +1,84 @@
+package widgets
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// SelectableFields returns the fields a widget can be selected by
+func SelectableFields(widget *Widget) fields.Set {
+	return generic.AddObjectMetaFieldsSet(fields.Set{
+		"spec.size":    sizeFieldValue(widget.Spec.Size),
+		"status.phase": widget.Status.Phase,
+	}, &widget.ObjectMeta, true)
+}
+
+// sizeFieldValue returns size as selected by field selectors: whole numbers in decimal, as
+// v1alpha1 serves them, and other sizes in canonical quantity form
+func sizeFieldValue(size resource.Quantity) string {
+	if value, ok := size.AsInt64(); ok {
+		return strconv.FormatInt(value, 10)
+	}
+	return size.String()
+}
+
+// GetAttrs returns the labels and fields of a widget for selector matching
+func GetAttrs(obj runtime.Object) (labels.Set, fields.Set, error) {
+	widget, ok := obj.(*Widget)
+	if !ok {
+		return nil, nil, fmt.Errorf("not a widget: %T", obj)
+	}
+	return widget.Labels, SelectableFields(widget), nil
+}
+
+// ConvertFieldLabel is the field label conversion func registered with the scheme; it
+// rejects fields widgets cannot be selected by
+func ConvertFieldLabel(label, value string) (string, string, error) {
+	switch label {
+	case "spec.size":
+		// Sizes are selected by value, whichever way they are written
+		if size, err := resource.ParseQuantity(value); err == nil {
+			return label, sizeFieldValue(size), nil
+		}
+		return label, value, nil
+	case "metadata.name", "metadata.namespace", "status.phase":
+		return label, value, nil
+	default:
+		return "", "", fmt.Errorf("field label not supported for widgets: %s", label)
+	}
+}
+
+// Predicate returns the selection predicate for the selectors and pagination in options
+func Predicate(options *internalversion.ListOptions) (storage.SelectionPredicate, error) {
+	predicate := storage.SelectionPredicate{
+		Label:    labels.Everything(),
+		Field:    fields.Everything(),
+		GetAttrs: GetAttrs,
+	}
+	if options == nil {
+		return predicate, nil
+	}
+	predicate.Limit = options.Limit
+	predicate.Continue = options.Continue
+	if options.LabelSelector != nil {
+		predicate.Label = options.LabelSelector
+	}
+	if options.FieldSelector != nil {
+		for _, requirement := range options.FieldSelector.Requirements() {
+			if _, _, err := ConvertFieldLabel(requirement.Field, requirement.Value); err != nil {
+				return predicate, errors.NewBadRequest(err.Error())
+			}
+		}
+		predicate.Field = options.FieldSelector
+	}
+	return predicate, nil
+}
@@ -0,0 +1,66 @@
+package widgets
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/util/dryrun"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+)
+
+// StatusREST serves the widgets/status subresource. Updates through it change only the status
+// of a widget, while updates of the widget itself leave the status alone.
+// +k8s:openapi-gen=false
+type StatusREST struct {
+	storage  Storage
+	strategy WidgetStatusStrategy
+}
+
+var _ rest.Getter = &StatusREST{}
+var _ rest.Updater = &StatusREST{}
+var _ rest.Patcher = &StatusREST{}
+var _ rest.Storage = &StatusREST{}
+var _ rest.ResetFieldsStrategy = &StatusREST{}
+
+// NewStatusREST returns the status subresource of the widgets in storage, updated through the status
+// strategy of strategy
+func NewStatusREST(storage Storage, strategy WidgetStrategy) *StatusREST {
+	return &StatusREST{
+		storage:  storage,
+		strategy: NewStatusStrategy(strategy),
+	}
+}
+
+func (r *StatusREST) New() runtime.Object {
+	return &Widget{}
+}
+
+func (r *StatusREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	return r.storage.Get(ctx, genericapirequest.NamespaceValue(ctx), name)
+}
+
+func (r *StatusREST) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	// Status updates never create widgets
+	return update(ctx, r.storage, r.strategy, name, objInfo, createValidation, updateValidation, false,
+		options != nil && dryrun.IsDryRun(options.DryRun))
+}
+
+// GetResetFields returns the fields status updates ignore, for server-side apply
+func (r *StatusREST) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return r.strategy.GetResetFields()
+}
+
+func (r *StatusREST) ConvertToTable(ctx context.Context, object runtime.Object,
+	tableOptions runtime.Object) (*metav1.Table, error) {
+	return rest.NewDefaultTableConvertor(Resource("widgets")).
+		ConvertToTable(ctx, object, tableOptions)
+}
+
+// Destroy does nothing; the storage is shared with and destroyed by WidgetREST
+func (r *StatusREST) Destroy() {
+}
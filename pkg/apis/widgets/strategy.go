@@ -0,0 +1,162 @@
+package widgets
+
+import (
+	"context"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage/names"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+
+	"example.com/mytest-apiserver/pkg/common"
+	"example.com/mytest-apiserver/pkg/rules"
+)
+
+// WidgetStrategy implements the behaviour widgets share with upstream resources on create,
+// update and delete
+// +k8s:openapi-gen=false
+type WidgetStrategy struct {
+	runtime.ObjectTyper
+	names.NameGenerator
+
+	// validator evaluates the CEL rules of the widget schema on create and update
+	validator *rules.Validator
+}
+
+// Strategy is the strategy WidgetREST runs through rest.BeforeCreate, rest.BeforeUpdate
+// and rest.BeforeDelete unless given another one. It only enforces the rules written in Go.
+var Strategy = NewStrategy(scheme, nil)
+
+var _ rest.RESTCreateStrategy = Strategy
+var _ rest.RESTUpdateStrategy = Strategy
+var _ rest.RESTDeleteStrategy = Strategy
+var _ rest.ResetFieldsStrategy = Strategy
+
+// NewStrategy returns the widget strategy for objects typed by typer, which evaluates the CEL
+// rules of the widget schema with validator. The schema is generated from the versioned packages,
+// which import this one, so callers compile the validator from it. Without one only the rules
+// written in Go are enforced.
+func NewStrategy(typer runtime.ObjectTyper, validator *rules.Validator) WidgetStrategy {
+	return WidgetStrategy{typer, names.SimpleNameGenerator, validator}
+}
+
+// validateRules returns the rules of the widget schema obj fails, given old on update
+func (s WidgetStrategy) validateRules(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	if s.validator == nil {
+		return nil
+	}
+	return s.validator.Validate(ctx, obj, old)
+}
+
+// servedVersions are the versions server-side apply tracks the fields of widgets in. The
+// versioned packages import this one, so their group versions cannot be used here.
+var servedVersions = []fieldpath.APIVersion{
+	common.GroupName + "/v1alpha1",
+	common.GroupName + "/v1beta1",
+}
+
+// resetFields returns paths for every served version
+func resetFields(paths ...fieldpath.Path) map[fieldpath.APIVersion]*fieldpath.Set {
+	fields := make(map[fieldpath.APIVersion]*fieldpath.Set, len(servedVersions))
+	for _, version := range servedVersions {
+		fields[version] = fieldpath.NewSet(paths...)
+	}
+	return fields
+}
+
+// GetResetFields returns the fields PrepareForUpdate resets
+func (WidgetStrategy) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return resetFields(fieldpath.MakePathOrDie("status"))
+}
+
+func (WidgetStrategy) NamespaceScoped() bool {
+	return true
+}
+
+// PrepareForCreate populates the fields the server owns on a new widget
+func (WidgetStrategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	widget := obj.(*Widget)
+	widget.Generation = 1
+	widget.Status = WidgetStatus{Phase: "Active"}
+}
+
+// PrepareForUpdate keeps the status of old, which is only updated through the status
+// subresource, and bumps the generation if the spec changed
+func (WidgetStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	widget, oldWidget := obj.(*Widget), old.(*Widget)
+	widget.Status = oldWidget.Status
+	if !apiequality.Semantic.DeepEqual(widget.Spec, oldWidget.Spec) {
+		widget.Generation = oldWidget.Generation + 1
+	}
+}
+
+func (s WidgetStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	allErrs := ValidateWidget(obj.(*Widget))
+	return append(allErrs, s.validateRules(ctx, obj, nil)...)
+}
+
+func (WidgetStrategy) WarningsOnCreate(ctx context.Context, obj runtime.Object) []string {
+	return nil
+}
+
+func (WidgetStrategy) AllowCreateOnUpdate() bool {
+	return false
+}
+
+// AllowUnconditionalUpdate is true since updates without resourceVersion are retried
+// against the latest widget
+func (WidgetStrategy) AllowUnconditionalUpdate() bool {
+	return true
+}
+
+func (WidgetStrategy) Canonicalize(obj runtime.Object) {
+}
+
+func (s WidgetStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	allErrs := ValidateWidgetUpdate(obj.(*Widget), old.(*Widget))
+	return append(allErrs, s.validateRules(ctx, obj, old)...)
+}
+
+func (WidgetStrategy) WarningsOnUpdate(ctx context.Context, obj, old runtime.Object) []string {
+	return nil
+}
+
+// WidgetStatusStrategy is the strategy of the widgets/status subresource
+// +k8s:openapi-gen=false
+type WidgetStatusStrategy struct {
+	WidgetStrategy
+}
+
+var _ rest.RESTUpdateStrategy = WidgetStatusStrategy{}
+var _ rest.ResetFieldsStrategy = WidgetStatusStrategy{}
+
+// NewStatusStrategy returns the strategy of the status subresource of widgets handled by strategy
+func NewStatusStrategy(strategy WidgetStrategy) WidgetStatusStrategy {
+	return WidgetStatusStrategy{strategy}
+}
+
+// GetResetFields returns the fields PrepareForUpdate resets
+func (WidgetStatusStrategy) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return resetFields(
+		fieldpath.MakePathOrDie("spec"),
+		fieldpath.MakePathOrDie("metadata", "labels"),
+		fieldpath.MakePathOrDie("metadata", "annotations"),
+		fieldpath.MakePathOrDie("metadata", "finalizers"),
+		fieldpath.MakePathOrDie("metadata", "ownerReferences"),
+	)
+}
+
+// PrepareForUpdate keeps everything of old but the status, including the metadata users own
+func (WidgetStatusStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	widget, oldWidget := obj.(*Widget), old.(*Widget)
+	widget.Spec = oldWidget.Spec
+	metav1.ResetObjectMetaForStatus(widget, oldWidget)
+}
+
+func (s WidgetStatusStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	allErrs := ValidateWidgetStatusUpdate(obj.(*Widget), old.(*Widget))
+	return append(allErrs, s.validateRules(ctx, obj, old)...)
+}
@@ -0,0 +1,50 @@
+package widgets_test
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+	"example.com/mytest-apiserver/pkg/apis/widgets/install"
+	"example.com/mytest-apiserver/pkg/apis/widgets/v1beta1"
+	generatedopenapi "example.com/mytest-apiserver/pkg/generated/openapi"
+	"example.com/mytest-apiserver/pkg/rules"
+)
+
+func TestStrategy_ValidationRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	install.Install(scheme)
+	validator, err := rules.NewValidator(rules.NewSchemaResolver(generatedopenapi.GetOpenAPIDefinitions, scheme),
+		scheme, v1beta1.SchemeGroupVersion.WithKind("Widget"))
+	if err != nil {
+		t.Fatalf("Failed to compile the widget validation rules: %v", err)
+	}
+	strategy := widgets.NewStrategy(scheme, validator)
+	ctx := context.Background()
+
+	old := &widgets.Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "w", Namespace: "default", ResourceVersion: "1"},
+		Spec:       widgets.WidgetSpec{Name: "w", Size: resource.MustParse("2")},
+	}
+	grown := old.DeepCopy()
+	grown.Spec.Size = resource.MustParse("3")
+	if errs := strategy.ValidateUpdate(ctx, grown, old); len(errs) != 0 {
+		t.Errorf("Expected the widget to grow, got %v", errs)
+	}
+
+	shrunk := old.DeepCopy()
+	shrunk.Spec.Size = resource.MustParse("1999m")
+	errs := strategy.ValidateUpdate(ctx, shrunk, old)
+	if len(errs) != 1 || errs[0].Field != "spec.size" || errs[0].Detail != "cannot shrink" {
+		t.Errorf("Expected the transition rule to reject shrinking spec.size, got %v", errs)
+	}
+
+	// Without a validator only the rules written in Go are enforced
+	if errs := widgets.NewStrategy(scheme, nil).ValidateUpdate(ctx, shrunk, old); len(errs) != 0 {
+		t.Errorf("Expected no rules to be evaluated without a validator, got %v", errs)
+	}
+}
@@ -0,0 +1,107 @@
+package v1alpha1
+
+import (
+	"math"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+// SizeAnnotation holds the exact size of a widget whose size is not a whole number v1alpha1 can
+// serve. Updates through v1alpha1 that leave the rounded size alone keep the exact one.
+const SizeAnnotation = "widgets.things.myorg.io/size"
+
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	// Field selectors are checked against these before reaching storage
+	return scheme.AddFieldLabelConversionFunc(SchemeGroupVersion.WithKind("Widget"), widgets.ConvertFieldLabel)
+}
+
+func Convert_widgets_Widget_To_v1alpha1_Widget(in *widgets.Widget, out *Widget, s conversion.Scope) error {
+	if err := autoConvert_widgets_Widget_To_v1alpha1_Widget(in, out, s); err != nil {
+		return err
+	}
+	_, exact := sizeToInt32(in.Spec.Size)
+	_, annotated := in.Annotations[SizeAnnotation]
+	if exact && !annotated {
+		return nil
+	}
+	out.Annotations = withoutSizeAnnotation(in.Annotations)
+	if !exact {
+		if out.Annotations == nil {
+			out.Annotations = make(map[string]string, 1)
+		}
+		out.Annotations[SizeAnnotation] = in.Spec.Size.String()
+	}
+	return nil
+}
+
+func Convert_v1alpha1_Widget_To_widgets_Widget(in *Widget, out *widgets.Widget, s conversion.Scope) error {
+	if err := autoConvert_v1alpha1_Widget_To_widgets_Widget(in, out, s); err != nil {
+		return err
+	}
+	value, annotated := in.Annotations[SizeAnnotation]
+	if !annotated {
+		return nil
+	}
+	out.Annotations = withoutSizeAnnotation(in.Annotations)
+	if size, err := resource.ParseQuantity(value); err == nil {
+		if rounded, _ := sizeToInt32(size); in.Spec.Size != nil && rounded == *in.Spec.Size {
+			out.Spec.Size = size
+		}
+	}
+	return nil
+}
+
+func Convert_widgets_WidgetSpec_To_v1alpha1_WidgetSpec(in *widgets.WidgetSpec, out *WidgetSpec, s conversion.Scope) error {
+	if err := autoConvert_widgets_WidgetSpec_To_v1alpha1_WidgetSpec(in, out, s); err != nil {
+		return err
+	}
+	size, _ := sizeToInt32(in.Size)
+	out.Size = &size
+	return nil
+}
+
+func Convert_v1alpha1_WidgetSpec_To_widgets_WidgetSpec(in *WidgetSpec, out *widgets.WidgetSpec, s conversion.Scope) error {
+	if err := autoConvert_v1alpha1_WidgetSpec_To_widgets_WidgetSpec(in, out, s); err != nil {
+		return err
+	}
+	out.Size = resource.Quantity{}
+	if in.Size != nil {
+		out.Size = *resource.NewQuantity(int64(*in.Size), resource.DecimalSI)
+	}
+	return nil
+}
+
+// sizeToInt32 returns size rounded up to a whole number and clamped to the int32 range, and
+// whether that is exactly size
+func sizeToInt32(size resource.Quantity) (int32, bool) {
+	switch {
+	case size.Cmp(*resource.NewQuantity(math.MaxInt32, resource.DecimalSI)) > 0:
+		return math.MaxInt32, false
+	case size.Cmp(*resource.NewQuantity(math.MinInt32, resource.DecimalSI)) < 0:
+		return math.MinInt32, false
+	}
+	if value, ok := size.AsInt64(); ok {
+		return int32(value), true
+	}
+	return int32(size.Value()), false
+}
+
+// withoutSizeAnnotation returns a copy of annotations without SizeAnnotation, or nil if
+// nothing else is left; annotations are shared with the object converted from
+func withoutSizeAnnotation(annotations map[string]string) map[string]string {
+	var out map[string]string
+	for key, value := range annotations {
+		if key == SizeAnnotation {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(annotations))
+		}
+		out[key] = value
+	}
+	return out
+}
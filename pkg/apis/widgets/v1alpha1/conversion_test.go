@@ -0,0 +1,82 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(widgets.AddToScheme(scheme))
+	utilruntime.Must(AddToScheme(scheme))
+	return scheme
+}
+
+func TestConvertWidget_Size(t *testing.T) {
+	scheme := newTestScheme()
+	for _, tc := range []struct {
+		size       string
+		expected   int32
+		annotation string
+	}{
+		{"3", 3, ""},
+		{"2Ki", 2048, ""},
+		{"1500m", 2, "1500m"},
+		{"5G", 2147483647, "5G"},
+	} {
+		t.Run(tc.size, func(t *testing.T) {
+			internal := &widgets.Widget{
+				ObjectMeta: metav1.ObjectMeta{Name: "widget", Annotations: map[string]string{"team": "foo"}},
+				Spec:       widgets.WidgetSpec{Size: resource.MustParse(tc.size)},
+			}
+			external := &Widget{}
+			if err := scheme.Convert(internal, external, nil); err != nil {
+				t.Fatalf("Failed to convert to v1alpha1: %v", err)
+			}
+			if ptr.Deref(external.Spec.Size, 0) != tc.expected {
+				t.Errorf("Expected size %d, got %v", tc.expected, external.Spec.Size)
+			}
+			if external.Annotations[SizeAnnotation] != tc.annotation || external.Annotations["team"] != "foo" {
+				t.Errorf("Expected size annotation %q next to the others, got %v", tc.annotation, external.Annotations)
+			}
+			if _, ok := internal.Annotations[SizeAnnotation]; ok {
+				t.Errorf("Expected the internal widget to be left alone, got %v", internal.Annotations)
+			}
+
+			// Round trips through v1alpha1 keep the exact size
+			back := &widgets.Widget{}
+			if err := scheme.Convert(external, back, nil); err != nil {
+				t.Fatalf("Failed to convert from v1alpha1: %v", err)
+			}
+			if back.Spec.Size.Cmp(internal.Spec.Size) != 0 {
+				t.Errorf("Expected size %s after a round trip, got %s", internal.Spec.Size.String(), back.Spec.Size.String())
+			}
+			if _, ok := back.Annotations[SizeAnnotation]; ok || back.Annotations["team"] != "foo" {
+				t.Errorf("Expected only the size annotation to be dropped, got %v", back.Annotations)
+			}
+		})
+	}
+}
+
+func TestConvertWidget_SizeChanged(t *testing.T) {
+	scheme := newTestScheme()
+	external := &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "widget", Annotations: map[string]string{SizeAnnotation: "1500m"}},
+		Spec:       WidgetSpec{Size: ptr.To[int32](5)},
+	}
+	internal := &widgets.Widget{}
+	if err := scheme.Convert(external, internal, nil); err != nil {
+		t.Fatalf("Failed to convert from v1alpha1: %v", err)
+	}
+	if internal.Spec.Size.Value() != 5 || internal.Annotations != nil {
+		t.Errorf("Expected the new size to replace the annotated one, got %s and %v",
+			internal.Spec.Size.String(), internal.Annotations)
+	}
+}
@@ -0,0 +1,59 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Widget represents a sample widget resource
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type Widget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec defines the desired state of Widget
+	Spec WidgetSpec `json:"spec,omitempty"`
+
+	// Status defines the observed state of Widget
+	Status WidgetStatus `json:"status,omitempty"`
+}
+
+// WidgetSpec defines the desired state of Widget
+type WidgetSpec struct {
+	// Name is the name of the widget
+	Name string `json:"name"`
+
+	// Description describes what the widget does
+	Description string `json:"description"`
+
+	// Size indicates the size of the widget, 1 unless set. Sizes that are not whole numbers,
+	// which v1beta1 allows, are rounded up here.
+	// +optional
+	// +default=1
+	Size *int32 `json:"size,omitempty"`
+}
+
+// WidgetStatus defines the observed state of Widget
+type WidgetStatus struct {
+	// Phase indicates the current phase of the widget
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the widget the status was last reported for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report the observed condition of the widget, such as Ready or Degraded
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// WidgetList contains a list of Widget
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type WidgetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is the list of Widget objects
+	Items []Widget `json:"items"`
+}
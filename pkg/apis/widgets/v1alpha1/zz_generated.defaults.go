@@ -0,0 +1,48 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by defaulter-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// RegisterDefaults adds defaulters functions to the given scheme.
+// Public to allow building arbitrary schemes.
+// All generated defaulters are covering - they call all nested defaulters.
+func RegisterDefaults(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&Widget{}, func(obj interface{}) { SetObjectDefaults_Widget(obj.(*Widget)) })
+	scheme.AddTypeDefaultingFunc(&WidgetList{}, func(obj interface{}) { SetObjectDefaults_WidgetList(obj.(*WidgetList)) })
+	return nil
+}
+
+func SetObjectDefaults_Widget(in *Widget) {
+	if in.Spec.Size == nil {
+		var ptrVar1 int32 = 1
+		in.Spec.Size = &ptrVar1
+	}
+}
+
+func SetObjectDefaults_WidgetList(in *WidgetList) {
+	for i := range in.Items {
+		a := &in.Items[i]
+		SetObjectDefaults_Widget(a)
+	}
+}
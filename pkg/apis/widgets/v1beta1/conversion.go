@@ -0,0 +1,36 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	// Field selectors are checked against these before reaching storage
+	return scheme.AddFieldLabelConversionFunc(SchemeGroupVersion.WithKind("Widget"), widgets.ConvertFieldLabel)
+}
+
+func Convert_widgets_WidgetSpec_To_v1beta1_WidgetSpec(in *widgets.WidgetSpec, out *WidgetSpec, s conversion.Scope) error {
+	if err := autoConvert_widgets_WidgetSpec_To_v1beta1_WidgetSpec(in, out, s); err != nil {
+		return err
+	}
+	size := in.Size.DeepCopy()
+	out.Size = &size
+	return nil
+}
+
+// Convert_v1beta1_WidgetSpec_To_widgets_WidgetSpec leaves the size zero if it is not set, which
+// only happens to widgets that were not defaulted
+func Convert_v1beta1_WidgetSpec_To_widgets_WidgetSpec(in *WidgetSpec, out *widgets.WidgetSpec, s conversion.Scope) error {
+	if err := autoConvert_v1beta1_WidgetSpec_To_widgets_WidgetSpec(in, out, s); err != nil {
+		return err
+	}
+	out.Size = resource.Quantity{}
+	if in.Size != nil {
+		out.Size = in.Size.DeepCopy()
+	}
+	return nil
+}
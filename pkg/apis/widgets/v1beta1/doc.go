@@ -0,0 +1,8 @@
+// Package v1beta1 contains the v1beta1 Widget API types
+// +k8s:deepcopy-gen=package
+// +k8s:conversion-gen=example.com/mytest-apiserver/pkg/apis/widgets
+// +k8s:defaulter-gen=TypeMeta
+// +k8s:openapi-gen=true
+// +groupName=things.myorg.io
+
+package v1beta1
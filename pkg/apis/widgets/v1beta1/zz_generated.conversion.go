@@ -0,0 +1,192 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by conversion-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	unsafe "unsafe"
+
+	widgets "example.com/mytest-apiserver/pkg/apis/widgets"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	conversion "k8s.io/apimachinery/pkg/conversion"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+func init() {
+	localSchemeBuilder.Register(RegisterConversions)
+}
+
+// RegisterConversions adds conversion functions to the given scheme.
+// Public to allow building arbitrary schemes.
+func RegisterConversions(s *runtime.Scheme) error {
+	if err := s.AddGeneratedConversionFunc((*Widget)(nil), (*widgets.Widget)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_Widget_To_widgets_Widget(a.(*Widget), b.(*widgets.Widget), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*widgets.Widget)(nil), (*Widget)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_widgets_Widget_To_v1beta1_Widget(a.(*widgets.Widget), b.(*Widget), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*WidgetList)(nil), (*widgets.WidgetList)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_WidgetList_To_widgets_WidgetList(a.(*WidgetList), b.(*widgets.WidgetList), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*widgets.WidgetList)(nil), (*WidgetList)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_widgets_WidgetList_To_v1beta1_WidgetList(a.(*widgets.WidgetList), b.(*WidgetList), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*WidgetStatus)(nil), (*widgets.WidgetStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_WidgetStatus_To_widgets_WidgetStatus(a.(*WidgetStatus), b.(*widgets.WidgetStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*widgets.WidgetStatus)(nil), (*WidgetStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_widgets_WidgetStatus_To_v1beta1_WidgetStatus(a.(*widgets.WidgetStatus), b.(*WidgetStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*WidgetSpec)(nil), (*widgets.WidgetSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_WidgetSpec_To_widgets_WidgetSpec(a.(*WidgetSpec), b.(*widgets.WidgetSpec), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*widgets.WidgetSpec)(nil), (*WidgetSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_widgets_WidgetSpec_To_v1beta1_WidgetSpec(a.(*widgets.WidgetSpec), b.(*WidgetSpec), scope)
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func autoConvert_v1beta1_Widget_To_widgets_Widget(in *Widget, out *widgets.Widget, s conversion.Scope) error {
+	out.ObjectMeta = in.ObjectMeta
+	if err := Convert_v1beta1_WidgetSpec_To_widgets_WidgetSpec(&in.Spec, &out.Spec, s); err != nil {
+		return err
+	}
+	if err := Convert_v1beta1_WidgetStatus_To_widgets_WidgetStatus(&in.Status, &out.Status, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Convert_v1beta1_Widget_To_widgets_Widget is an autogenerated conversion function.
+func Convert_v1beta1_Widget_To_widgets_Widget(in *Widget, out *widgets.Widget, s conversion.Scope) error {
+	return autoConvert_v1beta1_Widget_To_widgets_Widget(in, out, s)
+}
+
+func autoConvert_widgets_Widget_To_v1beta1_Widget(in *widgets.Widget, out *Widget, s conversion.Scope) error {
+	out.ObjectMeta = in.ObjectMeta
+	if err := Convert_widgets_WidgetSpec_To_v1beta1_WidgetSpec(&in.Spec, &out.Spec, s); err != nil {
+		return err
+	}
+	if err := Convert_widgets_WidgetStatus_To_v1beta1_WidgetStatus(&in.Status, &out.Status, s); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Convert_widgets_Widget_To_v1beta1_Widget is an autogenerated conversion function.
+func Convert_widgets_Widget_To_v1beta1_Widget(in *widgets.Widget, out *Widget, s conversion.Scope) error {
+	return autoConvert_widgets_Widget_To_v1beta1_Widget(in, out, s)
+}
+
+func autoConvert_v1beta1_WidgetList_To_widgets_WidgetList(in *WidgetList, out *widgets.WidgetList, s conversion.Scope) error {
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]widgets.Widget, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_Widget_To_widgets_Widget(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	return nil
+}
+
+// Convert_v1beta1_WidgetList_To_widgets_WidgetList is an autogenerated conversion function.
+func Convert_v1beta1_WidgetList_To_widgets_WidgetList(in *WidgetList, out *widgets.WidgetList, s conversion.Scope) error {
+	return autoConvert_v1beta1_WidgetList_To_widgets_WidgetList(in, out, s)
+}
+
+func autoConvert_widgets_WidgetList_To_v1beta1_WidgetList(in *widgets.WidgetList, out *WidgetList, s conversion.Scope) error {
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Widget, len(*in))
+		for i := range *in {
+			if err := Convert_widgets_Widget_To_v1beta1_Widget(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Items = nil
+	}
+	return nil
+}
+
+// Convert_widgets_WidgetList_To_v1beta1_WidgetList is an autogenerated conversion function.
+func Convert_widgets_WidgetList_To_v1beta1_WidgetList(in *widgets.WidgetList, out *WidgetList, s conversion.Scope) error {
+	return autoConvert_widgets_WidgetList_To_v1beta1_WidgetList(in, out, s)
+}
+
+func autoConvert_v1beta1_WidgetSpec_To_widgets_WidgetSpec(in *WidgetSpec, out *widgets.WidgetSpec, s conversion.Scope) error {
+	out.Name = in.Name
+	out.Description = in.Description
+	// WARNING: in.Size requires manual conversion: inconvertible types (*k8s.io/apimachinery/pkg/api/resource.Quantity vs k8s.io/apimachinery/pkg/api/resource.Quantity)
+	return nil
+}
+
+func autoConvert_widgets_WidgetSpec_To_v1beta1_WidgetSpec(in *widgets.WidgetSpec, out *WidgetSpec, s conversion.Scope) error {
+	out.Name = in.Name
+	out.Description = in.Description
+	// WARNING: in.Size requires manual conversion: inconvertible types (k8s.io/apimachinery/pkg/api/resource.Quantity vs *k8s.io/apimachinery/pkg/api/resource.Quantity)
+	return nil
+}
+
+func autoConvert_v1beta1_WidgetStatus_To_widgets_WidgetStatus(in *WidgetStatus, out *widgets.WidgetStatus, s conversion.Scope) error {
+	out.Phase = in.Phase
+	out.ObservedGeneration = in.ObservedGeneration
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
+	return nil
+}
+
+// Convert_v1beta1_WidgetStatus_To_widgets_WidgetStatus is an autogenerated conversion function.
+func Convert_v1beta1_WidgetStatus_To_widgets_WidgetStatus(in *WidgetStatus, out *widgets.WidgetStatus, s conversion.Scope) error {
+	return autoConvert_v1beta1_WidgetStatus_To_widgets_WidgetStatus(in, out, s)
+}
+
+func autoConvert_widgets_WidgetStatus_To_v1beta1_WidgetStatus(in *widgets.WidgetStatus, out *WidgetStatus, s conversion.Scope) error {
+	out.Phase = in.Phase
+	out.ObservedGeneration = in.ObservedGeneration
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
+	return nil
+}
+
+// Convert_widgets_WidgetStatus_To_v1beta1_WidgetStatus is an autogenerated conversion function.
+func Convert_widgets_WidgetStatus_To_v1beta1_WidgetStatus(in *widgets.WidgetStatus, out *WidgetStatus, s conversion.Scope) error {
+	return autoConvert_widgets_WidgetStatus_To_v1beta1_WidgetStatus(in, out, s)
+}
@@ -0,0 +1,48 @@
+package widgets
+
+import (
+	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateWidget returns the problems with a new widget. Its name must be a DNS-1123 subdomain.
+func ValidateWidget(widget *Widget) field.ErrorList {
+	allErrs := apimachineryvalidation.ValidateObjectMeta(&widget.ObjectMeta, true,
+		apimachineryvalidation.NameIsDNSSubdomain, field.NewPath("metadata"))
+	return append(allErrs, validateWidgetSpec(&widget.Spec, nil, field.NewPath("spec"))...)
+}
+
+// ValidateWidgetUpdate returns the problems with a widget replacing old. Names cannot change, and
+// the rest of the metadata is validated for every resource on update. Fields left unchanged are
+// not validated again, so widgets stored before a rule existed can still be updated and deleted.
+func ValidateWidgetUpdate(widget, old *Widget) field.ErrorList {
+	return validateWidgetSpec(&widget.Spec, &old.Spec, field.NewPath("spec"))
+}
+
+// ValidateWidgetStatusUpdate returns the problems with the status of a widget replacing old
+func ValidateWidgetStatusUpdate(widget, old *Widget) field.ErrorList {
+	return validateWidgetStatus(&widget.Status, field.NewPath("status"))
+}
+
+// validateWidgetSpec validates spec, or only the fields that differ from old if it is not nil
+func validateWidgetSpec(spec, old *WidgetSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Name == "" && (old == nil || old.Name != "") {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	}
+	if spec.Size.Sign() < 0 && (old == nil || spec.Size.Cmp(old.Size) != 0) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("size"), spec.Size.String(), "must be greater than or equal to 0"))
+	}
+	return allErrs
+}
+
+func validateWidgetStatus(status *WidgetStatus, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if status.ObservedGeneration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("observedGeneration"), status.ObservedGeneration,
+			"must be greater than or equal to 0"))
+	}
+	allErrs = append(allErrs, metav1validation.ValidateConditions(status.Conditions, fldPath.Child("conditions"))...)
+	return allErrs
+}
@@ -0,0 +1,108 @@
+package widgets
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateWidget(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     WidgetSpec
+		expected []string
+	}{
+		{"valid", WidgetSpec{Name: "widget", Size: resource.MustParse("1")}, nil},
+		{"zero size", WidgetSpec{Name: "widget"}, nil},
+		{"fractional size", WidgetSpec{Name: "widget", Size: resource.MustParse("1500m")}, nil},
+		{"missing name", WidgetSpec{Size: resource.MustParse("1")}, []string{"spec.name"}},
+		{"negative size", WidgetSpec{Name: "widget", Size: resource.MustParse("-1")}, []string{"spec.size"}},
+		{"both", WidgetSpec{Size: resource.MustParse("-1")}, []string{"spec.name", "spec.size"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateWidget(&Widget{ObjectMeta: validObjectMeta, Spec: tc.spec})
+			if paths := errorPaths(errs); !equalPaths(paths, tc.expected) {
+				t.Errorf("Expected errors for %v, got %v", tc.expected, errs)
+			}
+		})
+	}
+}
+
+func TestValidateWidgetUpdate(t *testing.T) {
+	old := &Widget{ObjectMeta: validObjectMeta, Spec: WidgetSpec{Size: resource.MustParse("-1")}}
+	for _, tc := range []struct {
+		name     string
+		spec     WidgetSpec
+		expected []string
+	}{
+		// Fields stored before they were validated are kept as long as they are not changed
+		{"unchanged", WidgetSpec{Size: resource.MustParse("-1")}, nil},
+		{"same size written differently", WidgetSpec{Size: resource.MustParse("-1000m")}, nil},
+		{"fixed", WidgetSpec{Name: "widget", Size: resource.MustParse("1")}, nil},
+		{"changed size", WidgetSpec{Size: resource.MustParse("-2")}, []string{"spec.size"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			widget := old.DeepCopy()
+			widget.Spec = tc.spec
+			if paths := errorPaths(ValidateWidgetUpdate(widget, old)); !equalPaths(paths, tc.expected) {
+				t.Errorf("Expected errors for %v, got %v", tc.expected, paths)
+			}
+		})
+	}
+
+	// A name can still not be removed
+	named := &Widget{ObjectMeta: validObjectMeta, Spec: WidgetSpec{Name: "widget"}}
+	if paths := errorPaths(ValidateWidgetUpdate(&Widget{ObjectMeta: validObjectMeta}, named)); !equalPaths(paths, []string{"spec.name"}) {
+		t.Errorf("Expected an error for removing the name, got %v", paths)
+	}
+}
+
+var validObjectMeta = metav1.ObjectMeta{Name: "widget", Namespace: "default"}
+
+func TestWidgetName(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		meta     metav1.ObjectMeta
+		expected []string
+	}{
+		{"subdomain", metav1.ObjectMeta{Name: "my-widget.example", Namespace: "default"}, nil},
+		// Names are generated before validation
+		{"generated", metav1.ObjectMeta{GenerateName: "my-widget-", Name: "my-widget-x7k2p", Namespace: "default"}, nil},
+		{"neither", metav1.ObjectMeta{Namespace: "default"}, []string{"metadata.name"}},
+		{"uppercase", metav1.ObjectMeta{Name: "MyWidget", Namespace: "default"}, []string{"metadata.name"}},
+		{"underscore", metav1.ObjectMeta{Name: "my_widget", Namespace: "default"}, []string{"metadata.name"}},
+		{"invalid generateName", metav1.ObjectMeta{GenerateName: "MyWidget-", Name: "MyWidget-x7k2p", Namespace: "default"},
+			[]string{"metadata.generateName", "metadata.name"}},
+		{"too long", metav1.ObjectMeta{Name: strings.Repeat("a", 254), Namespace: "default"}, []string{"metadata.name"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateWidget(&Widget{ObjectMeta: tc.meta, Spec: WidgetSpec{Name: "widget"}})
+			if paths := errorPaths(errs); !equalPaths(paths, tc.expected) {
+				t.Errorf("Expected errors for %v, got %v", tc.expected, errs)
+			}
+		})
+	}
+}
+
+func errorPaths(errs field.ErrorList) []string {
+	var paths []string
+	for _, err := range errs {
+		paths = append(paths, err.Field)
+	}
+	return paths
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
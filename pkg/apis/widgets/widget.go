@@ -3,22 +3,30 @@ package widgets
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
-	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/util/dryrun"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 
-	"example.com/mytest-apiserver/pkg/common"
+	"example.com/mytest-apiserver/pkg/store"
 )
 
-// Widget represents a sample widget resource
+// Widget is the internal version of a widget, which every served version converts to and from
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type Widget struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -31,6 +39,7 @@ type Widget struct {
 }
 
 // WidgetSpec defines the desired state of Widget
+// +k8s:deepcopy-gen=true
 type WidgetSpec struct {
 	// Name is the name of the widget
 	Name string `json:"name"`
@@ -38,17 +47,26 @@ type WidgetSpec struct {
 	// Description describes what the widget does
 	Description string `json:"description"`
 
-	// Size indicates the size of the widget
-	Size int32 `json:"size"`
+	// Size indicates the size of the widget. v1alpha1 serves it as a whole number.
+	Size resource.Quantity `json:"size"`
 }
 
 // WidgetStatus defines the observed state of Widget
+// +k8s:deepcopy-gen=true
 type WidgetStatus struct {
 	// Phase indicates the current phase of the widget
 	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the widget the status was last reported for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report the observed condition of the widget, such as Ready or Degraded
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // WidgetList contains a list of Widget
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type WidgetList struct {
 	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata,omitempty"`
@@ -57,125 +75,419 @@ type WidgetList struct {
 	Items []Widget `json:"items"`
 }
 
-func (w *Widget) DeepCopyObject() runtime.Object {
-	return &Widget{
-		TypeMeta:   w.TypeMeta,
-		ObjectMeta: *w.ObjectMeta.DeepCopy(),
-		Spec:       w.Spec,
-		Status:     w.Status,
-	}
+// Storage is the backend WidgetREST keeps widgets in
+type Storage interface {
+	Get(ctx context.Context, namespace, name string) (*Widget, error)
+	List(ctx context.Context, namespace string, options *internalversion.ListOptions) (*WidgetList, error)
+	Create(ctx context.Context, widget *Widget) (*Widget, error)
+	Update(ctx context.Context, widget *Widget) (*Widget, error)
+	Delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions) error
+	// DeleteCollection calls prepare on a copy of every widget in namespace, or in all namespaces
+	// if namespace is empty, matching the selectors in options, then removes or updates each as
+	// prepare decided. Nothing changes if prepare fails for any of them. Storage changing them one
+	// at a time goes on after failing to change one and returns a store.NewPartialDeleteError.
+	// prepare is not called while holding locks on the storage, so it may read it.
+	DeleteCollection(ctx context.Context, namespace string, options *internalversion.ListOptions,
+		prepare func(*Widget) (store.DeleteAction, error)) (*WidgetList, error)
+	Watch(ctx context.Context, namespace string, options *internalversion.ListOptions) (watch.Interface, error)
+	Destroy()
 }
 
-func (wl *WidgetList) DeepCopyObject() runtime.Object {
-	out := &WidgetList{
-		TypeMeta: wl.TypeMeta,
-		ListMeta: wl.ListMeta,
-		Items:    make([]Widget, len(wl.Items)),
-	}
-	for i := range wl.Items {
-		out.Items[i] = *wl.Items[i].DeepCopyObject().(*Widget)
-	}
-	return out
-}
+var _ Storage = &MemoryStorage{}
 
+// +k8s:openapi-gen=false
 type MemoryStorage struct {
-	mu             sync.RWMutex
-	widgets        map[string]*Widget
-	versionCounter int64
+	mu          sync.RWMutex
+	widgets     map[string]*Widget
+	clock       *store.Clock
+	broadcaster *store.Broadcaster
+	snapshots   *store.Snapshots
+
+	// journal persists every change when the storage is file backed
+	journal *store.Journal
+	stopCh  chan struct{}
+
+	// destroyOnce guards Destroy, which the server calls once for every served version
+	destroyOnce sync.Once
 }
 
+// NewMemoryStorage returns in-memory storage with its own revision clock
 func NewMemoryStorage() *MemoryStorage {
+	return NewMemoryStorageWithClock(store.NewClock())
+}
+
+// NewMemoryStorageWithClock returns in-memory storage taking resourceVersions from clock
+func NewMemoryStorageWithClock(clock *store.Clock) *MemoryStorage {
 	return &MemoryStorage{
-		widgets:        make(map[string]*Widget),
-		versionCounter: 1,
+		widgets: make(map[string]*Widget),
+		clock:   clock,
+		broadcaster: store.NewBroadcaster(Resource("widgets"),
+			func() runtime.Object { return &Widget{} }),
+		snapshots: store.NewSnapshots(),
+		stopCh:    make(chan struct{}),
 	}
 }
 
-func (s *MemoryStorage) Get(name string) (*Widget, error) {
+// NewFileStorage returns storage that keeps widgets in memory and persists them in dir encoded
+// with codec, restoring the widgets and resourceVersions left there by a previous run. The clock
+// is advanced past every revision recovered.
+func NewFileStorage(dir string, options *store.FileOptions, codec runtime.Codec, clock *store.Clock) (*MemoryStorage, error) {
+	journal, state, err := store.OpenJournal(dir, codec, options.JournalOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewMemoryStorageWithClock(clock)
+	s.journal = journal
+	for _, obj := range state.Objects {
+		widget := obj.(*Widget)
+		s.widgets[store.Key(widget.Namespace, widget.Name)] = widget
+	}
+	clock.Advance(state.Revision)
+	// Events from before the restart are gone, so watches starting before it have to relist
+	s.broadcaster.ExpireThrough(state.Revision)
+
+	if options.CompactionInterval > 0 {
+		go wait.Until(func() {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			if err := s.compact(); err != nil {
+				klog.Errorf("Failed to compact widget storage: %v", err)
+			}
+		}, options.CompactionInterval, s.stopCh)
+	}
+	return s, nil
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, namespace, name string) (*Widget, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	widget, exists := s.widgets[name]
+	widget, exists := s.widgets[store.Key(namespace, name)]
 	if !exists {
-		return nil, errors.NewNotFound(schema.GroupResource{Group: common.GroupName, Resource: "widgets"}, name)
+		return nil, errors.NewNotFound(Resource("widgets"), name)
 	}
 	return widget.DeepCopyObject().(*Widget), nil
 }
 
-func (s *MemoryStorage) List() (*WidgetList, error) {
+// List returns the widgets in namespace, or in all namespaces if namespace is empty, that
+// match the selectors in options. Results are ordered by namespace and name and paginated
+// with options.Limit and options.Continue.
+func (s *MemoryStorage) List(ctx context.Context, namespace string, options *internalversion.ListOptions) (*WidgetList, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	// The state is consistent as of the current revision since writes hold the lock
+	page, err := s.snapshots.Paginate(namespace, predicate, s.clock.Current(), func() []runtime.Object {
+		objects := make([]runtime.Object, 0, len(s.widgets))
+		for _, widget := range s.widgets {
+			objects = append(objects, widget)
+		}
+		return objects
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	list := &WidgetList{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: common.GroupName + "/" + common.APIVersion,
-			Kind:       "WidgetList",
+		ListMeta: metav1.ListMeta{
+			ResourceVersion:    fmt.Sprintf("%d", page.Revision),
+			Continue:           page.Continue,
+			RemainingItemCount: page.RemainingItemCount,
 		},
-		Items: make([]Widget, 0, len(s.widgets)),
+		Items: make([]Widget, 0, len(page.Objects)),
 	}
-
-	for _, widget := range s.widgets {
-		list.Items = append(list.Items, *widget.DeepCopyObject().(*Widget))
+	for _, obj := range page.Objects {
+		list.Items = append(list.Items, *obj.DeepCopyObject().(*Widget))
 	}
 
 	return list, nil
 }
 
-func (s *MemoryStorage) Create(widget *Widget) (*Widget, error) {
+func (s *MemoryStorage) Create(ctx context.Context, widget *Widget) (*Widget, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if widget.Name == "" {
-		widget.Name = string(uuid.NewUUID())
+	key := store.Key(widget.Namespace, widget.Name)
+	if _, exists := s.widgets[key]; exists {
+		return nil, errors.NewAlreadyExists(Resource("widgets"), widget.Name)
 	}
 
-	if _, exists := s.widgets[widget.Name]; exists {
-		return nil, fmt.Errorf("widget %s already exists", widget.Name)
+	if err := s.put(key, widget, nil); err != nil {
+		return nil, err
 	}
-
-	now := metav1.NewTime(time.Now())
-	widget.CreationTimestamp = now
-	widget.ResourceVersion = fmt.Sprintf("%d", s.versionCounter)
-	s.versionCounter++
-	widget.UID = uuid.NewUUID()
-	widget.Status.Phase = "Active"
-
-	s.widgets[widget.Name] = widget.DeepCopyObject().(*Widget)
+	s.compactIfNeeded()
 	return widget, nil
 }
 
-func (s *MemoryStorage) Update(widget *Widget) (*Widget, error) {
+// Update replaces a stored widget. A non-empty resourceVersion must match the stored one,
+// otherwise the update fails with a conflict.
+func (s *MemoryStorage) Update(ctx context.Context, widget *Widget) (*Widget, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	existing, exists := s.widgets[widget.Name]
+	key := store.Key(widget.Namespace, widget.Name)
+	existing, exists := s.widgets[key]
 	if !exists {
-		return nil, errors.NewNotFound(schema.GroupResource{Group: common.GroupName, Resource: "widgets"}, widget.Name)
+		return nil, errors.NewNotFound(Resource("widgets"), widget.Name)
+	}
+	if widget.ResourceVersion != "" && widget.ResourceVersion != existing.ResourceVersion {
+		return nil, store.NewOptimisticLockError(Resource("widgets"), widget.Name)
 	}
 
 	widget.CreationTimestamp = existing.CreationTimestamp
 	widget.UID = existing.UID
-	widget.ResourceVersion = fmt.Sprintf("%d", s.versionCounter)
-	s.versionCounter++
-
-	s.widgets[widget.Name] = widget.DeepCopyObject().(*Widget)
+	if err := s.put(key, widget, existing); err != nil {
+		return nil, err
+	}
+	s.compactIfNeeded()
 	return widget, nil
 }
 
-func (s *MemoryStorage) Delete(name string) error {
+// Delete removes a stored widget if it satisfies preconditions, otherwise it fails with a conflict
+func (s *MemoryStorage) Delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.widgets[name]; !exists {
-		return errors.NewNotFound(schema.GroupResource{Group: common.GroupName, Resource: "widgets"}, name)
+	key := store.Key(namespace, name)
+	existing, exists := s.widgets[key]
+	if !exists {
+		return errors.NewNotFound(Resource("widgets"), name)
+	}
+	if err := store.CheckPreconditions(Resource("widgets"),
+		preconditions, existing); err != nil {
+		return err
 	}
 
-	delete(s.widgets, name)
+	if err := s.remove(key, existing); err != nil {
+		return err
+	}
+	s.compactIfNeeded()
 	return nil
 }
 
+// DeleteCollection runs prepare on copies of the selected widgets without holding the lock, since it
+// may call admission webhooks or read the widgets itself, then removes or updates them all under
+// the write lock, so no other change interleaves with them. It fails with a conflict and changes
+// nothing if any of them was changed in between; those deleted in between are skipped.
+func (s *MemoryStorage) DeleteCollection(ctx context.Context, namespace string, options *internalversion.ListOptions,
+	prepare func(*Widget) (store.DeleteAction, error)) (*WidgetList, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var keys []string
+	for key, widget := range s.widgets {
+		if namespace != metav1.NamespaceAll && widget.Namespace != namespace {
+			continue
+		}
+		if matches, _ := predicate.Matches(widget); matches {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	selected := make([]*Widget, len(keys))
+	versions := make([]string, len(keys))
+	for i, key := range keys {
+		selected[i] = s.widgets[key].DeepCopyObject().(*Widget)
+		versions[i] = selected[i].ResourceVersion
+	}
+	s.mu.RUnlock()
+
+	// Decide on every widget before changing any
+	actions := make([]store.DeleteAction, len(keys))
+	for i := range selected {
+		if actions[i], err = prepare(selected[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, key := range keys {
+		if existing, exists := s.widgets[key]; exists && existing.ResourceVersion != versions[i] {
+			return nil, store.NewOptimisticLockError(Resource("widgets"), selected[i].Name)
+		}
+	}
+
+	list := &WidgetList{
+		Items: make([]Widget, 0, len(keys)),
+	}
+	defer s.compactIfNeeded()
+	for i, key := range keys {
+		existing, exists := s.widgets[key]
+		if !exists {
+			continue
+		}
+		switch actions[i] {
+		case store.RemoveObject:
+			err = s.remove(key, existing)
+		case store.UpdateObject:
+			err = s.put(key, selected[i], existing)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *selected[i])
+	}
+	list.ResourceVersion = fmt.Sprintf("%d", s.clock.Current())
+	return list, nil
+}
+
+// Watch streams changes to the widgets in namespace, or in all namespaces if namespace is empty,
+// that match the selectors in options. An empty or "0" resourceVersion starts with synthetic ADDED events for every existing
+// widget; any other resourceVersion resumes after it, failing with 504 Timeout if it is newer than
+// the current one.
+func (s *MemoryStorage) Watch(ctx context.Context, namespace string, options *internalversion.ListOptions) (watch.Interface, error) {
+	predicate, err := Predicate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	opts := store.WatchOptions{
+		Revision: s.clock.Current(),
+		Filter: func(obj runtime.Object) bool {
+			if namespace != metav1.NamespaceAll && obj.(*Widget).Namespace != namespace {
+				return false
+			}
+			matches, _ := predicate.Matches(obj)
+			return matches
+		},
+	}
+
+	sendInitialEvents := options.SendInitialEvents != nil && *options.SendInitialEvents
+	if options.ResourceVersion == "" || options.ResourceVersion == "0" || sendInitialEvents {
+		for _, widget := range s.widgets {
+			if !opts.Filter(widget) {
+				continue
+			}
+			opts.Initial = append(opts.Initial, widget.DeepCopyObject())
+		}
+		opts.InitialEventsEnd = sendInitialEvents && options.AllowWatchBookmarks
+	} else {
+		revision, err := store.ParseResourceVersion(options.ResourceVersion)
+		if err != nil {
+			return nil, err
+		}
+		// Events up to a revision that has not happened yet would be skipped without notice
+		if revision > opts.Revision {
+			return nil, storage.NewTooLargeResourceVersionError(uint64(revision), uint64(opts.Revision), 1)
+		}
+		opts.Revision = revision
+	}
+
+	return s.broadcaster.Watch(ctx, opts)
+}
+
+func (s *MemoryStorage) Destroy() {
+	s.destroyOnce.Do(s.destroy)
+}
+
+func (s *MemoryStorage) destroy() {
+	s.broadcaster.Shutdown()
+	if s.journal == nil {
+		return
+	}
+
+	close(s.stopCh)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.compact(); err != nil {
+		klog.Errorf("Failed to compact widget storage: %v", err)
+	}
+	if err := s.journal.Close(); err != nil {
+		klog.Errorf("Failed to close widget storage journal: %v", err)
+	}
+}
+
+// put stores widget at a new revision in place of existing, which is nil for a new widget, and
+// notifies watchers. Callers must hold the write lock.
+func (s *MemoryStorage) put(key string, widget, existing *Widget) error {
+	revision := s.clock.Next()
+	widget.ResourceVersion = fmt.Sprintf("%d", revision)
+	if err := s.persist(revision, key, widget); err != nil {
+		return err
+	}
+
+	s.widgets[key] = widget.DeepCopyObject().(*Widget)
+	if existing == nil {
+		s.broadcaster.Action(watch.Added, widget, nil, revision)
+	} else {
+		s.broadcaster.Action(watch.Modified, widget, existing, revision)
+	}
+	return nil
+}
+
+// remove deletes the stored widget existing and notifies watchers. Callers must hold the write lock.
+func (s *MemoryStorage) remove(key string, existing *Widget) error {
+	// Deletions get their own revision so watchers can resume past them
+	revision := s.clock.Next()
+	if err := s.persist(revision, key, nil); err != nil {
+		return err
+	}
+	delete(s.widgets, key)
+
+	// Stored widgets are shared with list snapshots and never modified in place
+	deleted := existing.DeepCopyObject().(*Widget)
+	deleted.ResourceVersion = fmt.Sprintf("%d", revision)
+	s.broadcaster.Action(watch.Deleted, deleted, nil, revision)
+	return nil
+}
+
+// persist logs the change about to be made at revision; a nil widget is a deletion.
+// Callers must hold the write lock.
+func (s *MemoryStorage) persist(revision int64, key string, widget *Widget) error {
+	if s.journal == nil {
+		return nil
+	}
+	var obj runtime.Object
+	if widget != nil {
+		obj = widget
+	}
+	if err := s.journal.Append(revision, key, obj); err != nil {
+		return errors.NewInternalError(fmt.Errorf("persisting widget: %w", err))
+	}
+	return nil
+}
+
+// compactIfNeeded compacts once enough changes have been logged. Callers must hold the write lock.
+func (s *MemoryStorage) compactIfNeeded() {
+	if s.journal == nil || !s.journal.NeedsCompaction() {
+		return
+	}
+	if err := s.compact(); err != nil {
+		klog.Errorf("Failed to compact widget storage: %v", err)
+	}
+}
+
+// compact snapshots the stored widgets. Callers must hold the lock so no change is logged meanwhile.
+func (s *MemoryStorage) compact() error {
+	objects := make([]runtime.Object, 0, len(s.widgets))
+	for _, widget := range s.widgets {
+		objects = append(objects, widget)
+	}
+	return s.journal.Compact(s.clock.Current(), objects)
+}
+
+// maxGenerateNameAttempts is how many names are generated for a widget before a collision is
+// reported as AlreadyExists
+const maxGenerateNameAttempts = 8
+
+// +k8s:openapi-gen=false
 type WidgetREST struct {
-	storage *MemoryStorage
+	storage  Storage
+	strategy WidgetStrategy
 }
 
 // Ensure WidgetREST implements the required interfaces
@@ -184,12 +496,22 @@ var _ rest.Lister = &WidgetREST{}
 var _ rest.Getter = &WidgetREST{}
 var _ rest.Updater = &WidgetREST{}
 var _ rest.GracefulDeleter = &WidgetREST{}
+var _ rest.CollectionDeleter = &WidgetREST{}
+var _ rest.Watcher = &WidgetREST{}
 var _ rest.Scoper = &WidgetREST{}
 var _ rest.Storage = &WidgetREST{}
+var _ rest.ResetFieldsStrategy = &WidgetREST{}
 
 func NewWidgetREST() *WidgetREST {
+	return NewWidgetRESTWithStorage(NewMemoryStorage(), Strategy)
+}
+
+// NewWidgetRESTWithStorage returns the widgets in storage, which are created, updated and deleted
+// through strategy
+func NewWidgetRESTWithStorage(storage Storage, strategy WidgetStrategy) *WidgetREST {
 	return &WidgetREST{
-		storage: NewMemoryStorage(),
+		storage:  storage,
+		strategy: strategy,
 	}
 }
 
@@ -202,63 +524,283 @@ func (r *WidgetREST) NewList() runtime.Object {
 }
 
 func (r *WidgetREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
-	return r.storage.Get(name)
+	return r.storage.Get(ctx, genericapirequest.NamespaceValue(ctx), name)
 }
 
 func (r *WidgetREST) List(ctx context.Context, options *internalversion.ListOptions) (runtime.Object, error) {
-	return r.storage.List()
+	return r.storage.List(ctx, genericapirequest.NamespaceValue(ctx), options)
 }
 
 func (r *WidgetREST) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc,
 	options *metav1.CreateOptions) (runtime.Object, error) {
-	widget := obj.(*Widget)
-	widget.TypeMeta = metav1.TypeMeta{
-		APIVersion: common.GroupName + "/" + common.APIVersion,
-		Kind:       "Widget",
+	widget, ok := obj.(*Widget)
+	if !ok {
+		return nil, errors.NewBadRequest(fmt.Sprintf("not a Widget: %T", obj))
+	}
+	return create(ctx, r.storage, r.strategy, widget, createValidation,
+		options != nil && dryrun.IsDryRun(options.DryRun))
+}
+
+// create stores a new widget after running strategy and createValidation on it. A widget with only
+// a generateName is given a name from it, and another one if that name turns out to be taken.
+// A dry run returns the widget that would be stored without storing it.
+func create(ctx context.Context, storage Storage, strategy rest.RESTCreateStrategy, widget *Widget,
+	createValidation rest.ValidateObjectFunc, dryRun bool) (*Widget, error) {
+	rest.WipeObjectMetaSystemFields(widget)
+	rest.FillObjectMetaSystemFields(widget)
+	generateName := widget.GenerateName != "" && widget.Name == ""
+	for attempt := 1; ; attempt++ {
+		if generateName {
+			widget.Name = strategy.GenerateName(widget.GenerateName)
+		}
+		if err := rest.BeforeCreate(strategy, ctx, widget); err != nil {
+			return nil, err
+		}
+		if createValidation != nil {
+			if err := createValidation(ctx, widget.DeepCopyObject()); err != nil {
+				return nil, err
+			}
+		}
+		var created *Widget
+		var err error
+		if dryRun {
+			created, err = dryRunCreate(ctx, storage, widget)
+		} else {
+			created, err = storage.Create(ctx, widget)
+		}
+		if generateName && errors.IsAlreadyExists(err) && attempt < maxGenerateNameAttempts {
+			continue
+		}
+		return created, err
+	}
+}
+
+// dryRunCreate returns widget as creating it would, failing if its name is taken
+func dryRunCreate(ctx context.Context, storage Storage, widget *Widget) (*Widget, error) {
+	_, err := storage.Get(ctx, widget.Namespace, widget.Name)
+	if err == nil {
+		return nil, errors.NewAlreadyExists(Resource("widgets"), widget.Name)
 	}
-	return r.storage.Create(widget)
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return widget, nil
 }
 
 func (r *WidgetREST) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo,
 	createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc,
 	forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
-	oldObj, err := r.storage.Get(name)
-	if err != nil {
-		return nil, false, err
-	}
+	return update(ctx, r.storage, r.strategy, name, objInfo, createValidation, updateValidation, forceAllowCreate,
+		options != nil && dryrun.IsDryRun(options.DryRun))
+}
 
-	updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
-	if err != nil {
-		return nil, false, err
+// update replaces the widget called name with the one objInfo computes from it, running strategy
+// so that only the parts of the widget strategy allows to change are updated. If the widget does
+// not exist and forceAllowCreate is set, as for server-side apply, it is created instead. A dry run
+// returns the widget that would be stored without storing it.
+func update(ctx context.Context, storage Storage, strategy rest.RESTCreateUpdateStrategy, name string,
+	objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc,
+	updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate, dryRun bool) (runtime.Object, bool, error) {
+	namespace := genericapirequest.NamespaceValue(ctx)
+	for {
+		oldObj, err := storage.Get(ctx, namespace, name)
+		if errors.IsNotFound(err) && forceAllowCreate {
+			newObj, err := objInfo.UpdatedObject(ctx, &Widget{})
+			if err != nil {
+				return nil, false, err
+			}
+			widget, ok := newObj.(*Widget)
+			if !ok {
+				return nil, false, errors.NewBadRequest(fmt.Sprintf("not a Widget: %T", newObj))
+			}
+			widget.Name = name
+			created, err := create(ctx, storage, strategy, widget, createValidation, dryRun)
+			if errors.IsAlreadyExists(err) {
+				// Created concurrently; apply to that widget instead
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			return created, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if err := store.CheckPreconditions(Resource("widgets"),
+			objInfo.Preconditions(), oldObj); err != nil {
+			return nil, false, err
+		}
+
+		updatedObj, err := objInfo.UpdatedObject(ctx, oldObj)
+		if err != nil {
+			return nil, false, err
+		}
+
+		widget, ok := updatedObj.(*Widget)
+		if !ok {
+			return nil, false, errors.NewBadRequest(fmt.Sprintf("not a Widget: %T", updatedObj))
+		}
+		widget.Name = name
+		if err := rest.BeforeUpdate(strategy, ctx, widget, oldObj); err != nil {
+			return nil, false, err
+		}
+		if updateValidation != nil {
+			if err := updateValidation(ctx, widget.DeepCopyObject(), oldObj.DeepCopyObject()); err != nil {
+				return nil, false, err
+			}
+		}
+
+		// An update without resourceVersion is applied on top of the object it was computed
+		// from, and retried if that object changed in the meantime
+		unconditional := widget.ResourceVersion == ""
+		if unconditional {
+			widget.ResourceVersion = oldObj.ResourceVersion
+		}
+		if dryRun {
+			// The widget read above is current, so only a stale resourceVersion would conflict
+			if widget.ResourceVersion != oldObj.ResourceVersion {
+				return nil, false, store.NewOptimisticLockError(Resource("widgets"), name)
+			}
+			return widget, false, nil
+		}
+
+		// A widget being deleted is removed by the update that removes its last finalizer
+		if widget.DeletionTimestamp != nil && len(widget.Finalizers) == 0 {
+			err := storage.Delete(ctx, namespace, name, &metav1.Preconditions{ResourceVersion: &widget.ResourceVersion})
+			if unconditional && errors.IsConflict(err) {
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			return widget, false, nil
+		}
+
+		updatedWidget, err := storage.Update(ctx, widget)
+		if unconditional && errors.IsConflict(err) {
+			continue
+		}
+		return updatedWidget, false, err
 	}
-
-	widget := updatedObj.(*Widget)
-	widget.Name = name
-	updatedWidget, err := r.storage.Update(widget)
-	return updatedWidget, false, err
 }
 
+// Delete removes the widget called name. A widget with finalizers is only marked for deletion by
+// setting its deletionTimestamp; it is removed once the last finalizer is.
 func (r *WidgetREST) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc,
 	options *metav1.DeleteOptions) (runtime.Object, bool, error) {
-	obj, err := r.storage.Get(name)
-	if err != nil {
-		return nil, false, err
+	namespace := genericapirequest.NamespaceValue(ctx)
+	if options == nil {
+		options = &metav1.DeleteOptions{}
+	}
+	for {
+		widget, err := r.storage.Get(ctx, namespace, name)
+		if err != nil {
+			return nil, false, err
+		}
+		action, err := deleteAction(ctx, r.strategy, widget, options, deleteValidation)
+		if err != nil {
+			return nil, false, err
+		}
+		if action == store.KeepObject || dryrun.IsDryRun(options.DryRun) {
+			return widget, action == store.RemoveObject, nil
+		}
+
+		if action == store.UpdateObject {
+			// The update is conditional on the widget read above, so retry if it changed meanwhile
+			updated, err := r.storage.Update(ctx, widget)
+			if errors.IsConflict(err) && options.Preconditions == nil {
+				continue
+			}
+			return updated, false, err
+		}
+
+		err = r.storage.Delete(ctx, namespace, name, &metav1.Preconditions{ResourceVersion: &widget.ResourceVersion})
+		if errors.IsConflict(err) && options.Preconditions == nil {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return widget, true, nil
+	}
+}
+
+// DeleteCollection deletes the widgets in the request namespace matching listOptions as Delete
+// deletes each of them, in a single change to the storage
+func (r *WidgetREST) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc,
+	options *metav1.DeleteOptions, listOptions *internalversion.ListOptions) (runtime.Object, error) {
+	namespace := genericapirequest.NamespaceValue(ctx)
+	if options == nil {
+		options = &metav1.DeleteOptions{}
+	}
+	// Every selected widget is deleted, not just a page of them
+	if listOptions == nil {
+		listOptions = &internalversion.ListOptions{}
+	} else {
+		listOptions = listOptions.DeepCopy()
+	}
+	listOptions.Limit = 0
+	listOptions.Continue = ""
+
+	prepare := func(widget *Widget) (store.DeleteAction, error) {
+		return deleteAction(ctx, r.strategy, widget, options, deleteValidation)
+	}
+	if dryrun.IsDryRun(options.DryRun) {
+		list, err := r.storage.List(ctx, namespace, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			if _, err := prepare(&list.Items[i]); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return r.storage.DeleteCollection(ctx, namespace, listOptions, prepare)
+}
+
+// deleteAction runs strategy and deleteValidation for deleting widget and decides what the
+// delete does with it. A widget with finalizers is marked for deletion in place.
+func deleteAction(ctx context.Context, strategy rest.RESTDeleteStrategy, widget *Widget,
+	options *metav1.DeleteOptions, deleteValidation rest.ValidateObjectFunc) (store.DeleteAction, error) {
+	if _, _, err := rest.BeforeDelete(strategy, ctx, widget, options); err != nil {
+		return store.KeepObject, err
+	}
+	if deleteValidation != nil {
+		if err := deleteValidation(ctx, widget.DeepCopyObject()); err != nil {
+			return store.KeepObject, err
+		}
 	}
 
-	err = r.storage.Delete(name)
-	return obj, true, err
+	if len(widget.Finalizers) == 0 {
+		return store.RemoveObject, nil
+	}
+	if widget.DeletionTimestamp != nil {
+		return store.KeepObject, nil
+	}
+	now := metav1.Now()
+	widget.DeletionTimestamp = &now
+	widget.DeletionGracePeriodSeconds = ptr.To[int64](0)
+	return store.UpdateObject, nil
 }
 
-func (r *WidgetREST) Watch(ctx context.Context, options *metav1.ListOptions) (watch.Interface, error) {
-	return nil, fmt.Errorf("watch not implemented")
+func (r *WidgetREST) Watch(ctx context.Context, options *internalversion.ListOptions) (watch.Interface, error) {
+	return r.storage.Watch(ctx, genericapirequest.NamespaceValue(ctx), options)
 }
 
 func (r *WidgetREST) ConvertToTable(ctx context.Context, object runtime.Object,
 	tableOptions runtime.Object) (*metav1.Table, error) {
-	return rest.NewDefaultTableConvertor(schema.GroupResource{Group: common.GroupName, Resource: "widgets"}).
+	return rest.NewDefaultTableConvertor(Resource("widgets")).
 		ConvertToTable(ctx, object, tableOptions)
 }
 
+// GetResetFields returns the fields updates of widgets ignore, for server-side apply
+func (r *WidgetREST) GetResetFields() map[fieldpath.APIVersion]*fieldpath.Set {
+	return r.strategy.GetResetFields()
+}
+
 func (r *WidgetREST) NamespaceScoped() bool {
 	return true
 }
@@ -268,5 +810,5 @@ func (r *WidgetREST) GetSingularName() string {
 }
 
 func (r *WidgetREST) Destroy() {
-	// Cleanup resources if needed
+	r.storage.Destroy()
 }
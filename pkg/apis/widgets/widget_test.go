@@ -1,11 +1,31 @@
 package widgets
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	apistorage "k8s.io/apiserver/pkg/storage"
+
+	"example.com/mytest-apiserver/pkg/store"
 )
 
 func TestWidgetStorage_Create(t *testing.T) {
@@ -13,17 +33,18 @@ func TestWidgetStorage_Create(t *testing.T) {
 
 	widget := &Widget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-widget",
+			Name:      "test-widget",
+			Namespace: "default",
 		},
 		Spec: WidgetSpec{
 			Name:        "Test Widget",
 			Description: "A test widget",
-			Size:        42,
+			Size:        resource.MustParse("42"),
 		},
 	}
 
 	// Test successful creation
-	created, err := storage.Create(widget)
+	created, err := storage.Create(context.Background(), widget)
 	if err != nil {
 		t.Fatalf("Failed to create widget: %v", err)
 	}
@@ -32,26 +53,18 @@ func TestWidgetStorage_Create(t *testing.T) {
 		t.Errorf("Expected name 'test-widget', got '%s'", created.Name)
 	}
 
-	if created.Spec.Size != 42 {
-		t.Errorf("Expected size 42, got %d", created.Spec.Size)
-	}
-
-	if created.Status.Phase != "Active" {
-		t.Errorf("Expected status 'Active', got '%s'", created.Status.Phase)
+	if created.Spec.Size.Value() != 42 {
+		t.Errorf("Expected size 42, got %s", created.Spec.Size.String())
 	}
 
 	if created.ResourceVersion == "" {
 		t.Error("ResourceVersion should be set")
 	}
 
-	if created.UID == "" {
-		t.Error("UID should be set")
-	}
-
 	// Test duplicate creation
-	_, err = storage.Create(widget)
-	if err == nil {
-		t.Error("Expected error when creating duplicate widget")
+	_, err = storage.Create(context.Background(), widget)
+	if !errors.IsAlreadyExists(err) {
+		t.Errorf("Expected AlreadyExists when creating duplicate widget, got %v", err)
 	}
 }
 
@@ -59,7 +72,7 @@ func TestWidgetStorage_Get(t *testing.T) {
 	storage := NewMemoryStorage()
 
 	// Test getting non-existent widget
-	_, err := storage.Get("non-existent")
+	_, err := storage.Get(context.Background(), "default", "non-existent")
 	if err == nil {
 		t.Error("Expected error when getting non-existent widget")
 	}
@@ -67,21 +80,22 @@ func TestWidgetStorage_Get(t *testing.T) {
 	// Create a widget
 	widget := &Widget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-widget",
+			Name:      "test-widget",
+			Namespace: "default",
 		},
 		Spec: WidgetSpec{
 			Name:        "Test Widget",
 			Description: "A test widget",
-			Size:        42,
+			Size:        resource.MustParse("42"),
 		},
 	}
-	_, err = storage.Create(widget)
+	_, err = storage.Create(context.Background(), widget)
 	if err != nil {
 		t.Fatalf("Failed to create widget: %v", err)
 	}
 
 	// Test getting existing widget
-	retrieved, err := storage.Get("test-widget")
+	retrieved, err := storage.Get(context.Background(), "default", "test-widget")
 	if err != nil {
 		t.Fatalf("Failed to get widget: %v", err)
 	}
@@ -90,8 +104,8 @@ func TestWidgetStorage_Get(t *testing.T) {
 		t.Errorf("Expected name 'test-widget', got '%s'", retrieved.Name)
 	}
 
-	if retrieved.Spec.Size != 42 {
-		t.Errorf("Expected size 42, got %d", retrieved.Spec.Size)
+	if retrieved.Spec.Size.Value() != 42 {
+		t.Errorf("Expected size 42, got %s", retrieved.Spec.Size.String())
 	}
 }
 
@@ -101,13 +115,14 @@ func TestWidgetStorage_Update(t *testing.T) {
 	// Test updating non-existent widget
 	widget := &Widget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "non-existent",
+			Name:      "non-existent",
+			Namespace: "default",
 		},
 		Spec: WidgetSpec{
-			Size: 100,
+			Size: resource.MustParse("100"),
 		},
 	}
-	_, err := storage.Update(widget)
+	_, err := storage.Update(context.Background(), widget)
 	if err == nil {
 		t.Error("Expected error when updating non-existent widget")
 	}
@@ -115,15 +130,16 @@ func TestWidgetStorage_Update(t *testing.T) {
 	// Create a widget
 	originalWidget := &Widget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-widget",
+			Name:      "test-widget",
+			Namespace: "default",
 		},
 		Spec: WidgetSpec{
 			Name:        "Test Widget",
 			Description: "A test widget",
-			Size:        42,
+			Size:        resource.MustParse("42"),
 		},
 	}
-	created, err := storage.Create(originalWidget)
+	created, err := storage.Create(context.Background(), originalWidget)
 	if err != nil {
 		t.Fatalf("Failed to create widget: %v", err)
 	}
@@ -133,15 +149,15 @@ func TestWidgetStorage_Update(t *testing.T) {
 
 	// Update the widget (add small delay to ensure different timestamp)
 	time.Sleep(time.Millisecond)
-	created.Spec.Size = 100
+	created.Spec.Size = resource.MustParse("100")
 	created.Spec.Description = "Updated description"
-	updated, err := storage.Update(created)
+	updated, err := storage.Update(context.Background(), created)
 	if err != nil {
 		t.Fatalf("Failed to update widget: %v", err)
 	}
 
-	if updated.Spec.Size != 100 {
-		t.Errorf("Expected size 100, got %d", updated.Spec.Size)
+	if updated.Spec.Size.Value() != 100 {
+		t.Errorf("Expected size 100, got %s", updated.Spec.Size.String())
 	}
 
 	if updated.Spec.Description != "Updated description" {
@@ -167,7 +183,7 @@ func TestWidgetStorage_Delete(t *testing.T) {
 	storage := NewMemoryStorage()
 
 	// Test deleting non-existent widget
-	err := storage.Delete("non-existent")
+	err := storage.Delete(context.Background(), "default", "non-existent", nil)
 	if err == nil {
 		t.Error("Expected error when deleting non-existent widget")
 	}
@@ -175,26 +191,27 @@ func TestWidgetStorage_Delete(t *testing.T) {
 	// Create a widget
 	widget := &Widget{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "test-widget",
+			Name:      "test-widget",
+			Namespace: "default",
 		},
 		Spec: WidgetSpec{
 			Name: "Test Widget",
-			Size: 42,
+			Size: resource.MustParse("42"),
 		},
 	}
-	_, err = storage.Create(widget)
+	_, err = storage.Create(context.Background(), widget)
 	if err != nil {
 		t.Fatalf("Failed to create widget: %v", err)
 	}
 
 	// Delete the widget
-	err = storage.Delete("test-widget")
+	err = storage.Delete(context.Background(), "default", "test-widget", nil)
 	if err != nil {
 		t.Fatalf("Failed to delete widget: %v", err)
 	}
 
 	// Verify it's deleted
-	_, err = storage.Get("test-widget")
+	_, err = storage.Get(context.Background(), "default", "test-widget")
 	if err == nil {
 		t.Error("Widget should be deleted")
 	}
@@ -204,7 +221,7 @@ func TestWidgetStorage_List(t *testing.T) {
 	storage := NewMemoryStorage()
 
 	// Test listing empty storage
-	list, err := storage.List()
+	list, err := storage.List(context.Background(), "default", &internalversion.ListOptions{})
 	if err != nil {
 		t.Fatalf("Failed to list widgets: %v", err)
 	}
@@ -217,21 +234,22 @@ func TestWidgetStorage_List(t *testing.T) {
 	for i := 0; i < 3; i++ {
 		widget := &Widget{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: fmt.Sprintf("widget-%d", i),
+				Name:      fmt.Sprintf("widget-%d", i),
+				Namespace: "default",
 			},
 			Spec: WidgetSpec{
 				Name: fmt.Sprintf("Widget %d", i),
-				Size: int32(i * 10),
+				Size: *resource.NewQuantity(int64(i*10), resource.DecimalSI),
 			},
 		}
-		_, err = storage.Create(widget)
+		_, err = storage.Create(context.Background(), widget)
 		if err != nil {
 			t.Fatalf("Failed to create widget %d: %v", i, err)
 		}
 	}
 
 	// List all widgets
-	list, err = storage.List()
+	list, err = storage.List(context.Background(), "default", &internalversion.ListOptions{})
 	if err != nil {
 		t.Fatalf("Failed to list widgets: %v", err)
 	}
@@ -239,11 +257,6 @@ func TestWidgetStorage_List(t *testing.T) {
 	if len(list.Items) != 3 {
 		t.Errorf("Expected 3 widgets, got %d", len(list.Items))
 	}
-
-	// Verify list metadata
-	if list.Kind != "WidgetList" {
-		t.Errorf("Expected kind 'WidgetList', got '%s'", list.Kind)
-	}
 }
 
 func TestWidgetStorage_ThreadSafety(t *testing.T) {
@@ -258,14 +271,15 @@ func TestWidgetStorage_ThreadSafety(t *testing.T) {
 			for j := 0; j < numOperations; j++ {
 				widget := &Widget{
 					ObjectMeta: metav1.ObjectMeta{
-						Name: fmt.Sprintf("widget-%d-%d", id, j),
+						Name:      fmt.Sprintf("widget-%d-%d", id, j),
+						Namespace: "default",
 					},
 					Spec: WidgetSpec{
 						Name: fmt.Sprintf("Widget %d-%d", id, j),
-						Size: int32(j),
+						Size: *resource.NewQuantity(int64(j), resource.DecimalSI),
 					},
 				}
-				_, err := storage.Create(widget)
+				_, err := storage.Create(context.Background(), widget)
 				if err != nil {
 					t.Errorf("Failed to create widget %d-%d: %v", id, j, err)
 				}
@@ -280,7 +294,7 @@ func TestWidgetStorage_ThreadSafety(t *testing.T) {
 	}
 
 	// Verify all widgets were created
-	list, err := storage.List()
+	list, err := storage.List(context.Background(), "default", &internalversion.ListOptions{})
 	if err != nil {
 		t.Fatalf("Failed to list widgets: %v", err)
 	}
@@ -290,3 +304,1242 @@ func TestWidgetStorage_ThreadSafety(t *testing.T) {
 		t.Errorf("Expected %d widgets, got %d", expected, len(list.Items))
 	}
 }
+
+func TestWidgetStorage_Watch(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	existing, err := storage.Create(context.Background(), &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"},
+		Spec: WidgetSpec{
+			Name: "Test Widget",
+			Size: resource.MustParse("42"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+
+	// A watch without resourceVersion starts with the current state
+	w, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to watch widgets: %v", err)
+	}
+	defer w.Stop()
+
+	expectEvent := func(w watch.Interface, eventType watch.EventType, name string) *Widget {
+		t.Helper()
+		select {
+		case event := <-w.ResultChan():
+			widget := event.Object.(*Widget)
+			if event.Type != eventType || widget.Name != name {
+				t.Fatalf("Expected %s event for %s, got %s for %s", eventType, name, event.Type, widget.Name)
+			}
+			return widget
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s event for %s", eventType, name)
+		}
+		return nil
+	}
+
+	expectEvent(w, watch.Added, "existing")
+
+	created, err := storage.Create(context.Background(), &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget", Namespace: "default"},
+		Spec: WidgetSpec{
+			Name: "Test Widget",
+			Size: resource.MustParse("42"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	expectEvent(w, watch.Added, "test-widget")
+
+	updated, err := storage.Update(context.Background(), created)
+	if err != nil {
+		t.Fatalf("Failed to update widget: %v", err)
+	}
+	modified := expectEvent(w, watch.Modified, "test-widget")
+	if modified.ResourceVersion != updated.ResourceVersion {
+		t.Errorf("Expected MODIFIED event at resourceVersion %s, got %s", updated.ResourceVersion, modified.ResourceVersion)
+	}
+
+	if err := storage.Delete(context.Background(), "default", "test-widget", nil); err != nil {
+		t.Fatalf("Failed to delete widget: %v", err)
+	}
+	deleted := expectEvent(w, watch.Deleted, "test-widget")
+
+	// Resuming from the first object's resourceVersion replays everything after it
+	resumed, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: existing.ResourceVersion})
+	if err != nil {
+		t.Fatalf("Failed to resume watch: %v", err)
+	}
+	defer resumed.Stop()
+
+	expectEvent(resumed, watch.Added, "test-widget")
+	expectEvent(resumed, watch.Modified, "test-widget")
+	if replayed := expectEvent(resumed, watch.Deleted, "test-widget"); replayed.ResourceVersion != deleted.ResourceVersion {
+		t.Errorf("Expected replayed DELETED event at resourceVersion %s, got %s", deleted.ResourceVersion, replayed.ResourceVersion)
+	}
+
+	// Invalid resourceVersions are rejected
+	_, err = storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "abc"})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for invalid resourceVersion, got %v", err)
+	}
+
+	// So are resourceVersions that have not been reached yet, rather than skipping events up to them
+	_, err = storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "1000"})
+	if !apistorage.IsTooLargeResourceVersion(err) {
+		t.Errorf("Expected 504 Timeout for a resourceVersion newer than the current one, got %v", err)
+	}
+}
+
+func TestWidgetStorage_Namespaces(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	// The same name may be used in different namespaces
+	for _, namespace := range []string{"default", "team-a"} {
+		_, err := storage.Create(context.Background(), &Widget{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: namespace},
+			Spec: WidgetSpec{
+				Name: "Test Widget",
+				Size: resource.MustParse("42"),
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create widget in namespace %s: %v", namespace, err)
+		}
+	}
+
+	retrieved, err := storage.Get(context.Background(), "team-a", "shared")
+	if err != nil {
+		t.Fatalf("Failed to get widget: %v", err)
+	}
+	if retrieved.Namespace != "team-a" {
+		t.Errorf("Expected namespace 'team-a', got '%s'", retrieved.Namespace)
+	}
+
+	list, err := storage.List(context.Background(), "team-a", &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list widgets: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Namespace != "team-a" {
+		t.Errorf("Expected only the widget in 'team-a', got %d items", len(list.Items))
+	}
+
+	list, err = storage.List(context.Background(), metav1.NamespaceAll, &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list widgets: %v", err)
+	}
+	if len(list.Items) != 2 {
+		t.Errorf("Expected 2 widgets across all namespaces, got %d", len(list.Items))
+	}
+
+	if err := storage.Delete(context.Background(), "default", "shared", nil); err != nil {
+		t.Fatalf("Failed to delete widget: %v", err)
+	}
+	if _, err := storage.Get(context.Background(), "team-a", "shared"); err != nil {
+		t.Errorf("Deleting in one namespace should not affect another: %v", err)
+	}
+}
+
+func TestWidgetREST_NamespaceMismatch(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "team-a")
+
+	_, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget", Namespace: "default"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for mismatched namespace, got %v", err)
+	}
+
+	// The namespace is taken from the request when the object omits it
+	created, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	if created.(*Widget).Namespace != "team-a" {
+		t.Errorf("Expected namespace 'team-a', got '%s'", created.(*Widget).Namespace)
+	}
+
+	_, err = rest.Get(genericapirequest.WithNamespace(context.Background(), "default"), "test-widget", &metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected NotFound in another namespace, got %v", err)
+	}
+}
+
+func TestWidgetStorage_UpdateConflict(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	created, err := storage.Create(context.Background(), &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget", Namespace: "default"},
+		Spec: WidgetSpec{
+			Name: "Test Widget",
+			Size: resource.MustParse("42"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+
+	// Two clients read the same version
+	first := created.DeepCopyObject().(*Widget)
+	second := created.DeepCopyObject().(*Widget)
+
+	first.Spec.Size = resource.MustParse("1")
+	if _, err := storage.Update(context.Background(), first); err != nil {
+		t.Fatalf("Failed to update widget: %v", err)
+	}
+
+	second.Spec.Size = resource.MustParse("2")
+	_, err = storage.Update(context.Background(), second)
+	if !errors.IsConflict(err) {
+		t.Fatalf("Expected conflict for stale resourceVersion, got %v", err)
+	}
+
+	retrieved, err := storage.Get(context.Background(), "default", "test-widget")
+	if err != nil {
+		t.Fatalf("Failed to get widget: %v", err)
+	}
+	if retrieved.Spec.Size.Value() != 1 {
+		t.Errorf("Expected the first update to be preserved, got Size %s", retrieved.Spec.Size.String())
+	}
+}
+
+type testUpdateInfo struct {
+	preconditions *metav1.Preconditions
+	update        func(*Widget)
+}
+
+func (i *testUpdateInfo) Preconditions() *metav1.Preconditions {
+	return i.preconditions
+}
+
+func (i *testUpdateInfo) UpdatedObject(ctx context.Context, oldObj runtime.Object) (runtime.Object, error) {
+	widget := oldObj.DeepCopyObject().(*Widget)
+	i.update(widget)
+	return widget, nil
+}
+
+func TestWidgetREST_UpdatePreconditions(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec: WidgetSpec{
+			Name: "Test Widget",
+			Size: resource.MustParse("42"),
+		},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	created := obj.(*Widget)
+
+	setField := func(widget *Widget) { widget.Spec.Size = resource.MustParse("1") }
+
+	wrongUID := types.UID("wrong-uid")
+	_, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		preconditions: &metav1.Preconditions{UID: &wrongUID},
+		update:        setField,
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for UID precondition, got %v", err)
+	}
+
+	staleVersion := "0"
+	_, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		preconditions: &metav1.Preconditions{ResourceVersion: &staleVersion},
+		update:        setField,
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for resourceVersion precondition, got %v", err)
+	}
+
+	updated, _, err := rest.Update(ctx, "test-widget", &testUpdateInfo{
+		preconditions: &metav1.Preconditions{UID: &created.UID, ResourceVersion: &created.ResourceVersion},
+		update:        setField,
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Expected update with matching preconditions to succeed: %v", err)
+	}
+	if updated.(*Widget).Spec.Size.Value() != 1 {
+		t.Errorf("Expected Size 1, got %s", updated.(*Widget).Spec.Size.String())
+	}
+}
+
+// testCodec persists widgets in the file storage tests, which cannot import the served versions
+var testCodec = func() runtime.Codec {
+	gv := schema.GroupVersion{Group: "test", Version: "v1"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(gv, &Widget{}, &WidgetList{})
+	codecs := serializer.NewCodecFactory(scheme)
+	return codecs.CodecForVersions(codecs.LegacyCodec(gv), codecs.UniversalDeserializer(), gv, gv)
+}()
+
+func TestWidgetStorage_FileRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to create file storage: %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := storage.Create(ctx, &Widget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       WidgetSpec{Name: "Widget", Size: resource.MustParse("3")},
+		}); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+	if err := storage.Delete(ctx, "default", "b", nil); err != nil {
+		t.Fatalf("Failed to delete widget: %v", err)
+	}
+	before, err := storage.Get(ctx, "default", "a")
+	if err != nil {
+		t.Fatalf("Failed to get widget: %v", err)
+	}
+	storage.Destroy()
+	// The server destroys the storage once for every version it serves
+	storage.Destroy()
+
+	storage, err = NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer storage.Destroy()
+
+	after, err := storage.Get(ctx, "default", "a")
+	if err != nil {
+		t.Fatalf("Expected widget to survive a restart: %v", err)
+	}
+	if after.ResourceVersion != before.ResourceVersion || after.UID != before.UID || !apiequality.Semantic.DeepEqual(after.Spec, before.Spec) {
+		t.Errorf("Expected widget to be restored unchanged, got %+v, want %+v", after, before)
+	}
+	if _, err := storage.Get(ctx, "default", "b"); !errors.IsNotFound(err) {
+		t.Errorf("Expected deleted widget to stay deleted, got %v", err)
+	}
+
+	// Revisions continue after the deletion instead of being reused
+	created, err := storage.Create(ctx, &Widget{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	if created.ResourceVersion != "4" {
+		t.Errorf("Expected resourceVersion 4 after restart, got %s", created.ResourceVersion)
+	}
+}
+
+func TestWidgetStorage_FileRestartWatch(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to create file storage: %v", err)
+	}
+	var last *Widget
+	for _, name := range []string{"a", "b", "c"} {
+		if last, err = storage.Create(ctx, &Widget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       WidgetSpec{Name: "Widget", Size: resource.MustParse("3")},
+		}); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+	storage.Destroy()
+
+	storage, err = NewFileStorage(dir, store.NewFileOptions(), testCodec, store.NewClock())
+	if err != nil {
+		t.Fatalf("Failed to reopen file storage: %v", err)
+	}
+	defer storage.Destroy()
+
+	// The events before the restart are gone, so watches from before it have to relist
+	if _, err := storage.Watch(ctx, "default", &internalversion.ListOptions{ResourceVersion: "1"}); !errors.IsResourceExpired(err) {
+		t.Errorf("Expected 410 Gone for a watch from before the restart, got %v", err)
+	}
+
+	w, err := storage.Watch(ctx, "default", &internalversion.ListOptions{ResourceVersion: last.ResourceVersion})
+	if err != nil {
+		t.Fatalf("Expected a watch from the last recovered resourceVersion to start: %v", err)
+	}
+	defer w.Stop()
+	if _, err := storage.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "d", Namespace: "default"},
+		Spec:       WidgetSpec{Name: "Widget", Size: resource.MustParse("3")},
+	}); err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	select {
+	case event := <-w.ResultChan():
+		if event.Type != watch.Added || event.Object.(*Widget).Name != "d" {
+			t.Errorf("Expected ADDED event for d, got %s for %v", event.Type, event.Object)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the event after the restart")
+	}
+}
+
+func TestWidgetStorage_Selectors(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	for _, widget := range []*Widget{
+		{ObjectMeta: metav1.ObjectMeta{Name: "small", Namespace: "default", Labels: map[string]string{"team": "foo"}}, Spec: WidgetSpec{Size: resource.MustParse("1")}, Status: WidgetStatus{Phase: "Active"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default", Labels: map[string]string{"team": "bar"}}, Spec: WidgetSpec{Size: resource.MustParse("10")}, Status: WidgetStatus{Phase: "Active"}},
+	} {
+		if _, err := storage.Create(ctx, widget); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		options  *internalversion.ListOptions
+		expected []string
+	}{
+		{"label", &internalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"team": "foo"})}, []string{"small"}},
+		{"name", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", "large")}, []string{"large"}},
+		{"size", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.size", "10")}, []string{"large"}},
+		{"phase", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("status.phase", "Active")}, []string{"large", "small"}},
+		{"no match", &internalversion.ListOptions{FieldSelector: fields.OneTermNotEqualSelector("status.phase", "Active")}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := storage.List(ctx, "default", tc.options)
+			if err != nil {
+				t.Fatalf("Failed to list widgets: %v", err)
+			}
+			var names []string
+			for _, widget := range list.Items {
+				names = append(names, widget.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+
+	_, err := storage.List(ctx, "default", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.name", "x")})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for unsupported field selector, got %v", err)
+	}
+	_, err = storage.Watch(ctx, "default", &internalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.name", "x")})
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for unsupported field selector, got %v", err)
+	}
+}
+
+func TestWidgetStorage_WatchSelectors(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	w, err := storage.Watch(ctx, "default", &internalversion.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"team": "foo"}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch widgets: %v", err)
+	}
+	defer w.Stop()
+
+	widget, err := storage.Create(ctx, &Widget{ObjectMeta: metav1.ObjectMeta{Name: "w", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+
+	// Objects entering and leaving the selection are seen as added and deleted
+	widget.Labels = map[string]string{"team": "foo"}
+	if widget, err = storage.Update(ctx, widget); err != nil {
+		t.Fatalf("Failed to update widget: %v", err)
+	}
+	widget.Labels = map[string]string{"team": "bar"}
+	if widget, err = storage.Update(ctx, widget); err != nil {
+		t.Fatalf("Failed to update widget: %v", err)
+	}
+
+	for _, expected := range []watch.EventType{watch.Added, watch.Deleted} {
+		select {
+		case event := <-w.ResultChan():
+			if event.Type != expected {
+				t.Errorf("Expected %s event, got %s", expected, event.Type)
+			}
+			if event.Type == watch.Deleted && event.Object.(*Widget).ResourceVersion != widget.ResourceVersion {
+				t.Errorf("Expected DELETED event at resourceVersion %s, got %s",
+					widget.ResourceVersion, event.Object.(*Widget).ResourceVersion)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s event", expected)
+		}
+	}
+}
+
+func TestWidgetStorage_Pagination(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	for _, name := range []string{"c", "a", "e", "b", "d"} {
+		if _, err := storage.Create(ctx, &Widget{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+
+	list, err := storage.List(ctx, "default", &internalversion.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to list widgets: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Name != "a" || list.Items[1].Name != "b" {
+		t.Errorf("Expected first page [a b], got %v", list.Items)
+	}
+	if list.Continue == "" || list.RemainingItemCount == nil || *list.RemainingItemCount != 3 {
+		t.Fatalf("Expected continue token with 3 remaining items, got %q and %v", list.Continue, list.RemainingItemCount)
+	}
+	revision := list.ResourceVersion
+
+	// Changes after the first page do not show up in later pages
+	if err := storage.Delete(ctx, "default", "c", nil); err != nil {
+		t.Fatalf("Failed to delete widget: %v", err)
+	}
+
+	var names []string
+	for list.Continue != "" {
+		if list, err = storage.List(ctx, "default", &internalversion.ListOptions{Limit: 2, Continue: list.Continue}); err != nil {
+			t.Fatalf("Failed to list widgets: %v", err)
+		}
+		if list.ResourceVersion != revision {
+			t.Errorf("Expected all pages at resourceVersion %s, got %s", revision, list.ResourceVersion)
+		}
+		for _, widget := range list.Items {
+			names = append(names, widget.Name)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"c", "d", "e"}) {
+		t.Errorf("Expected remaining pages [c d e], got %v", names)
+	}
+}
+
+func TestWidgetREST_Validation(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	_, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+		Spec:       WidgetSpec{Size: resource.MustParse("-1")},
+	}, nil, &metav1.CreateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Fatalf("Expected 422 Invalid, got %v", err)
+	}
+	causes := err.(*errors.StatusError).ErrStatus.Details.Causes
+	if len(causes) != 2 || causes[0].Field != "spec.name" || causes[1].Field != "spec.size" {
+		t.Errorf("Expected causes for spec.name and spec.size, got %+v", causes)
+	}
+
+	// The validation callbacks handed in by the handlers run as well
+	rejected := errors.NewForbidden(schema.GroupResource{Resource: "widgets"}, "test-widget", fmt.Errorf("denied"))
+	_, err = rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, func(ctx context.Context, obj runtime.Object) error {
+		return rejected
+	}, &metav1.CreateOptions{})
+	if err != rejected {
+		t.Errorf("Expected createValidation error, got %v", err)
+	}
+	if _, err := rest.Get(ctx, "test-widget", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected rejected widget not to be stored, got %v", err)
+	}
+
+	if _, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+
+	_, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.Spec.Size = resource.MustParse("-5") },
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid on update, got %v", err)
+	}
+
+	oldSize := resource.MustParse("-1")
+	_, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.Spec.Size = resource.MustParse("5") },
+	}, nil, func(ctx context.Context, obj, old runtime.Object) error {
+		oldSize = old.(*Widget).Spec.Size
+		return rejected
+	}, false, &metav1.UpdateOptions{})
+	if err != rejected {
+		t.Errorf("Expected updateValidation error, got %v", err)
+	}
+	if !oldSize.IsZero() {
+		t.Errorf("Expected updateValidation to see the old widget, got Size %s", oldSize.String())
+	}
+}
+
+func TestWidgetREST_Create(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+		Status:     WidgetStatus{Phase: "Broken"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	created := obj.(*Widget)
+	if created.Status.Phase != "Active" {
+		t.Errorf("Expected status 'Active', got '%s'", created.Status.Phase)
+	}
+	if created.UID == "" || created.CreationTimestamp.IsZero() {
+		t.Error("UID and CreationTimestamp should be set")
+	}
+
+	obj, err = rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "widget-"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	if name := obj.(*Widget).Name; !strings.HasPrefix(name, "widget-") || len(name) == len("widget-") {
+		t.Errorf("Expected a name generated from 'widget-', got '%s'", name)
+	}
+
+	// Object metadata is validated like that of upstream resources
+	_, err = rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget", Labels: map[string]string{"bad key": "value"}},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid for an invalid label, got %v", err)
+	}
+
+	// Updates keep the fields the server owns
+	updated, _, err := rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) {
+			widget.UID = ""
+			widget.CreationTimestamp = metav1.Time{}
+			widget.Spec.Size = resource.MustParse("3")
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update widget: %v", err)
+	}
+	if updated.(*Widget).UID != created.UID || !updated.(*Widget).CreationTimestamp.Equal(&created.CreationTimestamp) {
+		t.Error("UID and CreationTimestamp should remain the same")
+	}
+
+	wrongUID := types.UID("wrong-uid")
+	_, _, err = rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &wrongUID}})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for a mismatched UID precondition, got %v", err)
+	}
+}
+
+func TestWidgetREST_Status(t *testing.T) {
+	storage := NewMemoryStorage()
+	widgetREST := NewWidgetRESTWithStorage(storage, Strategy)
+	statusREST := NewStatusREST(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	if _, err := widgetREST.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget", Labels: map[string]string{"team": "foo"}},
+		Spec:       WidgetSpec{Name: "Test Widget", Size: resource.MustParse("1")},
+	}, nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+
+	// Updates of the widget ignore status changes
+	obj, _, err := widgetREST.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) {
+			widget.Spec.Size = resource.MustParse("2")
+			widget.Status.Phase = "Failed"
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update widget: %v", err)
+	}
+	if widget := obj.(*Widget); widget.Spec.Size.Value() != 2 || widget.Status.Phase != "Active" {
+		t.Errorf("Expected size 2 and phase 'Active', got %s and '%s'", widget.Spec.Size.String(), widget.Status.Phase)
+	}
+
+	// Updates of the status ignore everything else
+	obj, _, err = statusREST.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) {
+			widget.Spec.Size = resource.MustParse("3")
+			widget.Labels = map[string]string{"team": "bar"}
+			widget.Status.Phase = "Failed"
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to update widget status: %v", err)
+	}
+	widget := obj.(*Widget)
+	if widget.Spec.Size.Value() != 2 || widget.Labels["team"] != "foo" || widget.Status.Phase != "Failed" {
+		t.Errorf("Expected only the phase to change, got size %s, labels %v and phase '%s'",
+			widget.Spec.Size.String(), widget.Labels, widget.Status.Phase)
+	}
+
+	obj, err = statusREST.Get(ctx, "test-widget", &metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get widget status: %v", err)
+	}
+	if obj.(*Widget).Status.Phase != "Failed" {
+		t.Errorf("Expected phase 'Failed', got '%s'", obj.(*Widget).Status.Phase)
+	}
+}
+
+func TestWidgetREST_Generation(t *testing.T) {
+	storage := NewMemoryStorage()
+	widgetREST := NewWidgetRESTWithStorage(storage, Strategy)
+	statusREST := NewStatusREST(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := widgetREST.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget", Generation: 5},
+		Spec:       WidgetSpec{Name: "Test Widget", Size: resource.MustParse("1")},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	if generation := obj.(*Widget).Generation; generation != 1 {
+		t.Errorf("Expected generation 1 on create, got %d", generation)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		rest       rest.Updater
+		update     func(*Widget)
+		generation int64
+	}{
+		{"spec change", widgetREST, func(widget *Widget) { widget.Spec.Size = resource.MustParse("2") }, 2},
+		{"metadata change", widgetREST, func(widget *Widget) { widget.Labels = map[string]string{"team": "foo"} }, 2},
+		{"generation change", widgetREST, func(widget *Widget) { widget.Generation = 10 }, 2},
+		{"status change", statusREST, func(widget *Widget) {
+			widget.Status.ObservedGeneration = 2
+			widget.Status.Conditions = []metav1.Condition{{
+				Type:               "Ready",
+				Status:             metav1.ConditionTrue,
+				Reason:             "Reconciled",
+				LastTransitionTime: metav1.Now(),
+			}}
+		}, 2},
+		{"another spec change", widgetREST, func(widget *Widget) { widget.Spec.Description = "changed" }, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obj, _, err := tc.rest.Update(ctx, "test-widget", &testUpdateInfo{update: tc.update},
+				nil, nil, false, &metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("Failed to update widget: %v", err)
+			}
+			if generation := obj.(*Widget).Generation; generation != tc.generation {
+				t.Errorf("Expected generation %d, got %d", tc.generation, generation)
+			}
+		})
+	}
+
+	obj, err = widgetREST.Get(ctx, "test-widget", &metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get widget: %v", err)
+	}
+	status := obj.(*Widget).Status
+	if status.ObservedGeneration != 2 || len(status.Conditions) != 1 || status.Conditions[0].Type != "Ready" {
+		t.Errorf("Expected observed generation 2 and a Ready condition, got %+v", status)
+	}
+
+	_, _, err = statusREST.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) {
+			widget.Status.Conditions = append(widget.Status.Conditions, metav1.Condition{Type: "Degraded"})
+		},
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid for an incomplete condition, got %v", err)
+	}
+}
+
+func TestWidgetREST_Finalizers(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	if _, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget", Finalizers: []string{"example.com/cleanup"}},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+
+	// A dry run changes nothing
+	obj, deleted, err := rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil || deleted || obj.(*Widget).DeletionTimestamp == nil {
+		t.Fatalf("Expected a dry run to report the deletionTimestamp, got %v, %v, %v", obj, deleted, err)
+	}
+	if obj, _ := rest.Get(ctx, "test-widget", &metav1.GetOptions{}); obj.(*Widget).DeletionTimestamp != nil {
+		t.Error("Expected a dry run not to mark the widget for deletion")
+	}
+
+	// Deleting a widget with finalizers only marks it for deletion
+	obj, deleted, err = rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("Failed to delete widget: %v", err)
+	}
+	widget := obj.(*Widget)
+	if deleted || widget.DeletionTimestamp == nil || widget.DeletionGracePeriodSeconds == nil ||
+		*widget.DeletionGracePeriodSeconds != 0 {
+		t.Fatalf("Expected the widget to be marked for deletion, got deleted %v and %+v", deleted, widget.ObjectMeta)
+	}
+
+	// Deleting it again changes nothing
+	obj, deleted, err = rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{})
+	if err != nil || deleted || obj.(*Widget).ResourceVersion != widget.ResourceVersion {
+		t.Errorf("Expected a repeated delete to be a no-op, got %v, %v", deleted, err)
+	}
+
+	// No finalizers can be added and the deletionTimestamp cannot be cleared
+	_, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.Finalizers = append(widget.Finalizers, "example.com/other") },
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid adding a finalizer, got %v", err)
+	}
+	obj, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.DeletionTimestamp = nil },
+	}, nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil || obj.(*Widget).DeletionTimestamp == nil {
+		t.Errorf("Expected the deletionTimestamp to be kept, got %v", err)
+	}
+
+	// Removing the last finalizer removes the widget
+	if _, _, err := rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.Finalizers = nil },
+	}, nil, nil, false, &metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to remove finalizer: %v", err)
+	}
+	if _, err := rest.Get(ctx, "test-widget", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected the widget to be removed with its last finalizer, got %v", err)
+	}
+}
+
+func TestWidgetREST_DeleteOptions(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	obj, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	created := obj.(*Widget)
+
+	staleVersion := "0"
+	_, _, err = rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &staleVersion},
+	})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for a stale resourceVersion precondition, got %v", err)
+	}
+
+	_, deleted, err := rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil || !deleted {
+		t.Errorf("Expected a dry run to report the deletion, got %v, %v", deleted, err)
+	}
+	if _, err := rest.Get(ctx, "test-widget", &metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected a dry run not to delete the widget, got %v", err)
+	}
+
+	_, deleted, err = rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &created.UID, ResourceVersion: &created.ResourceVersion},
+	})
+	if err != nil || !deleted {
+		t.Errorf("Expected the widget to be deleted, got %v, %v", deleted, err)
+	}
+	if _, err := rest.Get(ctx, "test-widget", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected the widget to be gone, got %v", err)
+	}
+}
+
+func TestWidgetREST_DeleteCollection(t *testing.T) {
+	storage := NewMemoryStorage()
+	rest := NewWidgetRESTWithStorage(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+
+	for _, widget := range []*Widget{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default", Labels: map[string]string{"tier": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default", Labels: map[string]string{"tier": "web"},
+			Finalizers: []string{"example.com/cleanup"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "default", Labels: map[string]string{"tier": "db"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "d", Namespace: "other", Labels: map[string]string{"tier": "web"}}},
+	} {
+		widget.Spec = WidgetSpec{Name: widget.Name}
+		if _, err := rest.Create(genericapirequest.WithNamespace(context.Background(), widget.Namespace), widget,
+			nil, &metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Failed to create widget %s: %v", widget.Name, err)
+		}
+	}
+	selector := &internalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"tier": "web"})}
+
+	remaining := func(namespace string) []string {
+		t.Helper()
+		list, err := storage.List(context.Background(), namespace, &internalversion.ListOptions{})
+		if err != nil {
+			t.Fatalf("Failed to list widgets: %v", err)
+		}
+		var names []string
+		for _, widget := range list.Items {
+			if widget.DeletionTimestamp == nil {
+				names = append(names, widget.Name)
+			}
+		}
+		return names
+	}
+
+	// A dry run reports what would be deleted without changing anything
+	obj, err := rest.DeleteCollection(ctx, nil, &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}, selector)
+	if err != nil {
+		t.Fatalf("Failed to delete widgets: %v", err)
+	}
+	if items := obj.(*WidgetList).Items; len(items) != 2 || items[1].DeletionTimestamp == nil {
+		t.Errorf("Expected a dry run to report a and b marked for deletion, got %+v", items)
+	}
+	if names := remaining("default"); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("Expected a dry run not to delete anything, got %v", names)
+	}
+
+	// A failing validation aborts before anything is deleted
+	_, err = rest.DeleteCollection(ctx, func(ctx context.Context, obj runtime.Object) error {
+		if obj.(*Widget).Name == "b" {
+			return errors.NewForbidden(schema.GroupResource{Resource: "widgets"}, "b", fmt.Errorf("denied"))
+		}
+		return nil
+	}, &metav1.DeleteOptions{}, selector)
+	if !errors.IsForbidden(err) {
+		t.Errorf("Expected the validation error, got %v", err)
+	}
+	if names := remaining("default"); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("Expected nothing to be deleted, got %v", names)
+	}
+
+	w, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "4"})
+	if err != nil {
+		t.Fatalf("Failed to watch widgets: %v", err)
+	}
+	defer w.Stop()
+
+	obj, err = rest.DeleteCollection(ctx, nil, &metav1.DeleteOptions{}, selector)
+	if err != nil {
+		t.Fatalf("Failed to delete widgets: %v", err)
+	}
+	if items := obj.(*WidgetList).Items; len(items) != 2 || items[0].Name != "a" || items[1].Name != "b" {
+		t.Errorf("Expected a and b to be deleted, got %+v", items)
+	}
+
+	// Only the selected widgets in the request namespace are affected; b waits for its finalizer
+	if names := remaining("default"); !reflect.DeepEqual(names, []string{"c"}) {
+		t.Errorf("Expected only c to be left, got %v", names)
+	}
+	if _, err := storage.Get(context.Background(), "other", "d"); err != nil {
+		t.Errorf("Expected the widget in another namespace to be kept, got %v", err)
+	}
+	b, err := storage.Get(context.Background(), "default", "b")
+	if err != nil || b.DeletionTimestamp == nil {
+		t.Errorf("Expected b to be marked for deletion, got %v, %v", b, err)
+	}
+
+	for _, expected := range []struct {
+		eventType watch.EventType
+		name      string
+	}{{watch.Deleted, "a"}, {watch.Modified, "b"}} {
+		select {
+		case event := <-w.ResultChan():
+			if widget := event.Object.(*Widget); event.Type != expected.eventType || widget.Name != expected.name {
+				t.Errorf("Expected %s event for %s, got %s for %s", expected.eventType, expected.name, event.Type, widget.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s event for %s", expected.eventType, expected.name)
+		}
+	}
+}
+
+func TestWidgetStorage_DeleteCollectionUnlocked(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+	for _, name := range []string{"a", "b"} {
+		if _, err := storage.Create(ctx, &Widget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       WidgetSpec{Name: name},
+		}); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+
+	// prepare may read the storage, as delete validation does through listers
+	list, err := storage.DeleteCollection(ctx, "default", &internalversion.ListOptions{},
+		func(widget *Widget) (store.DeleteAction, error) {
+			_, err := storage.Get(ctx, widget.Namespace, widget.Name)
+			return store.RemoveObject, err
+		})
+	if err != nil || len(list.Items) != 2 {
+		t.Fatalf("Expected both widgets to be deleted, got %v: %v", list, err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := storage.Create(ctx, &Widget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       WidgetSpec{Name: name},
+		}); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+
+	// A widget changed while deciding fails the whole collection, leaving every widget alone
+	_, err = storage.DeleteCollection(ctx, "default", &internalversion.ListOptions{},
+		func(widget *Widget) (store.DeleteAction, error) {
+			if widget.Name == "b" {
+				current, err := storage.Get(ctx, widget.Namespace, widget.Name)
+				if err != nil {
+					return store.KeepObject, err
+				}
+				current.Labels = map[string]string{"changed": "true"}
+				if _, err := storage.Update(ctx, current); err != nil {
+					return store.KeepObject, err
+				}
+			}
+			return store.RemoveObject, nil
+		})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected a conflict for a widget changed while deciding, got %v", err)
+	}
+	remaining, err := storage.List(ctx, "default", &internalversion.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list widgets: %v", err)
+	}
+	if len(remaining.Items) != 2 {
+		t.Errorf("Expected no widget to be deleted after the conflict, got %v", remaining.Items)
+	}
+}
+
+func TestWidgetREST_Errors(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+	newWidget := func() *Widget {
+		return &Widget{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+			Spec:       WidgetSpec{Name: "Test Widget"},
+		}
+	}
+
+	expectStatus := func(err error, reason metav1.StatusReason, code int32) {
+		t.Helper()
+		status, ok := err.(errors.APIStatus)
+		if !ok {
+			t.Fatalf("Expected a status error with reason %s, got %v", reason, err)
+		}
+		s := status.Status()
+		if s.Reason != reason || s.Code != code {
+			t.Errorf("Expected %s (%d), got %s (%d): %s", reason, code, s.Reason, s.Code, s.Message)
+		}
+		if reason != metav1.StatusReasonBadRequest && (s.Details == nil || s.Details.Group != "things.myorg.io" ||
+			s.Details.Name != "test-widget") {
+			t.Errorf("Expected details naming things.myorg.io test-widget, got %+v", s.Details)
+		}
+	}
+
+	if _, err := rest.Create(ctx, newWidget(), nil, &metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	_, err := rest.Create(ctx, newWidget(), nil, &metav1.CreateOptions{})
+	expectStatus(err, metav1.StatusReasonAlreadyExists, 409)
+	if details := err.(errors.APIStatus).Status().Details; details.Kind != "widgets" {
+		t.Errorf("Expected details for widgets, got %+v", details)
+	}
+
+	_, err = rest.Get(ctx, "missing", &metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+
+	stale := "0"
+	_, _, err = rest.Delete(ctx, "test-widget", nil, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &stale},
+	})
+	expectStatus(err, metav1.StatusReasonConflict, 409)
+
+	invalid := newWidget()
+	invalid.Name = "other-widget"
+	invalid.Spec = WidgetSpec{}
+	_, err = rest.Create(ctx, invalid, nil, &metav1.CreateOptions{})
+	if !errors.IsInvalid(err) || err.(errors.APIStatus).Status().Details.Kind != "Widget" {
+		t.Errorf("Expected Widget to be Invalid, got %v", err)
+	}
+
+	_, err = rest.Create(ctx, &metav1.Status{}, nil, &metav1.CreateOptions{})
+	expectStatus(err, metav1.StatusReasonBadRequest, 400)
+
+	_, err = rest.Watch(ctx, &internalversion.ListOptions{ResourceVersion: "abc"})
+	expectStatus(err, metav1.StatusReasonBadRequest, 400)
+}
+
+// sequenceNameGenerator generates the given names in turn, repeating the last one
+type sequenceNameGenerator []string
+
+func (g *sequenceNameGenerator) GenerateName(base string) string {
+	name := (*g)[0]
+	if len(*g) > 1 {
+		*g = (*g)[1:]
+	}
+	return name
+}
+
+func TestWidgetREST_Names(t *testing.T) {
+	rest := NewWidgetREST()
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+	create := func(meta metav1.ObjectMeta) (*Widget, error) {
+		obj, err := rest.Create(ctx, &Widget{ObjectMeta: meta, Spec: WidgetSpec{Name: "Test Widget"}}, nil, &metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return obj.(*Widget), nil
+	}
+
+	if _, err := create(metav1.ObjectMeta{}); !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid without name or generateName, got %v", err)
+	}
+	if _, err := create(metav1.ObjectMeta{Name: "My_Widget"}); !errors.IsInvalid(err) {
+		t.Errorf("Expected 422 Invalid for a name that is not a DNS subdomain, got %v", err)
+	}
+	if _, err := create(metav1.ObjectMeta{Name: "taken"}); err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+
+	// A generated name that is taken is replaced by another one
+	rest.strategy.NameGenerator = &sequenceNameGenerator{"taken", "taken", "free"}
+	created, err := create(metav1.ObjectMeta{GenerateName: "widget-"})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	if created.Name != "free" || created.GenerateName != "widget-" {
+		t.Errorf("Expected the widget to be named 'free', got %q", created.Name)
+	}
+
+	// Eventually the collision is reported
+	rest.strategy.NameGenerator = &sequenceNameGenerator{"taken"}
+	if _, err := create(metav1.ObjectMeta{GenerateName: "widget-"}); !errors.IsAlreadyExists(err) {
+		t.Errorf("Expected AlreadyExists once no generated name is free, got %v", err)
+	}
+}
+
+func TestWidgetREST_DryRun(t *testing.T) {
+	storage := NewMemoryStorage()
+	rest := NewWidgetRESTWithStorage(storage, Strategy)
+	status := NewStatusREST(storage, Strategy)
+	ctx := genericapirequest.WithNamespace(context.Background(), "default")
+	dryRun := []string{metav1.DryRunAll}
+
+	w, err := storage.Watch(context.Background(), "default", &internalversion.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		t.Fatalf("Failed to watch widgets: %v", err)
+	}
+	defer w.Stop()
+
+	// A dry-run create returns the widget as it would be stored
+	obj, err := rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "widget-"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{DryRun: dryRun})
+	if err != nil {
+		t.Fatalf("Failed to dry-run create: %v", err)
+	}
+	would := obj.(*Widget)
+	if would.Name == "" || would.UID == "" || would.Generation != 1 || would.Status.Phase != "Active" {
+		t.Errorf("Expected the server populated fields, got %+v", would)
+	}
+	if _, err := rest.Get(ctx, would.Name, &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected a dry-run create not to store the widget, got %v", err)
+	}
+	_, _, err = rest.Update(ctx, "applied", &testUpdateInfo{
+		update: func(widget *Widget) { widget.Spec = WidgetSpec{Name: "Test Widget"} },
+	}, nil, nil, true, &metav1.UpdateOptions{DryRun: dryRun})
+	if err != nil {
+		t.Fatalf("Failed to dry-run create on update: %v", err)
+	}
+	if _, err := rest.Get(ctx, "applied", &metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected a dry-run create on update not to store the widget, got %v", err)
+	}
+
+	obj, err = rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	created := obj.(*Widget)
+	if created.ResourceVersion != "1" {
+		t.Errorf("Expected dry runs not to use up resourceVersions, got %s", created.ResourceVersion)
+	}
+	_, err = rest.Create(ctx, &Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-widget"},
+		Spec:       WidgetSpec{Name: "Test Widget"},
+	}, nil, &metav1.CreateOptions{DryRun: dryRun})
+	if !errors.IsAlreadyExists(err) {
+		t.Errorf("Expected a dry-run create of a taken name to fail, got %v", err)
+	}
+
+	// Dry-run updates return the result, including conflicts, without storing it
+	obj, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.Spec.Size = resource.MustParse("3") },
+	}, nil, nil, false, &metav1.UpdateOptions{DryRun: dryRun})
+	if err != nil {
+		t.Fatalf("Failed to dry-run update: %v", err)
+	}
+	if updated := obj.(*Widget); updated.Spec.Size.Value() != 3 || updated.Generation != 2 {
+		t.Errorf("Expected the updated spec and generation, got %+v", updated)
+	}
+	_, _, err = rest.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.ResourceVersion = "0" },
+	}, nil, nil, false, &metav1.UpdateOptions{DryRun: dryRun})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected a dry-run update with a stale resourceVersion to conflict, got %v", err)
+	}
+	if _, _, err := status.Update(ctx, "test-widget", &testUpdateInfo{
+		update: func(widget *Widget) { widget.Status.Phase = "Ready" },
+	}, nil, nil, false, &metav1.UpdateOptions{DryRun: dryRun}); err != nil {
+		t.Fatalf("Failed to dry-run status update: %v", err)
+	}
+
+	current, err := storage.Get(context.Background(), "default", "test-widget")
+	if err != nil {
+		t.Fatalf("Failed to get widget: %v", err)
+	}
+	if !reflect.DeepEqual(current, created) {
+		t.Errorf("Expected dry runs to leave the widget unchanged, got %+v", current)
+	}
+
+	// Watchers only saw the real create
+	select {
+	case event := <-w.ResultChan():
+		if event.Type != watch.Added || event.Object.(*Widget).Name != "test-widget" {
+			t.Errorf("Expected only the ADDED event for test-widget, got %s for %s", event.Type, event.Object.(*Widget).Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the ADDED event")
+	}
+	select {
+	case event := <-w.ResultChan():
+		t.Errorf("Expected no events for dry runs, got %s", event.Type)
+	default:
+	}
+}
@@ -3,7 +3,4 @@ package common
 const (
 	// GroupName is the API group name for all custom resources
 	GroupName = "things.myorg.io"
-
-	// APIVersion is the API version for all custom resources
-	APIVersion = "v1alpha1"
 )
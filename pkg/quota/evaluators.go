@@ -0,0 +1,122 @@
+// Package quota measures how much of a ResourceQuota the widgets and gadgets of a namespace
+// use, for the ResourceQuota admission plugin and for keeping the usage recorded in quotas
+// current
+package quota
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+	"k8s.io/apiserver/pkg/quota/v1/generic"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+// WidgetSize is the quota resource the spec.size of the widgets in a namespace adds up to
+const WidgetSize corev1.ResourceName = "things.myorg.io/widget-size"
+
+// NewConfiguration returns the quota configuration of this server: widgets count towards
+// count/widgets.things.myorg.io and WidgetSize, gadgets towards count/gadgets.things.myorg.io
+func NewConfiguration(widgetLister widgets.WidgetLister, gadgetLister gadgets.GadgetLister) quotav1.Configuration {
+	return generic.NewConfiguration(NewEvaluators(widgetLister, gadgetLister), nil)
+}
+
+// NewEvaluators returns the evaluators of widgets and gadgets, which count the objects listed
+// by widgetLister and gadgetLister
+func NewEvaluators(widgetLister widgets.WidgetLister, gadgetLister gadgets.GadgetLister) []quotav1.Evaluator {
+	return []quotav1.Evaluator{
+		&widgetEvaluator{lister: widgetLister},
+		generic.NewObjectCountEvaluator(gadgets.Resource("gadgets"), func(namespace string) ([]runtime.Object, error) {
+			list, err := gadgetLister.Gadgets(namespace).List(labels.Everything())
+			if err != nil {
+				return nil, err
+			}
+			objects := make([]runtime.Object, len(list))
+			for i := range list {
+				objects[i] = list[i]
+			}
+			return objects, nil
+		}, ""),
+	}
+}
+
+// widgetEvaluator counts widgets and adds up their sizes. The ResourceQuota admission plugin
+// takes one evaluator per resource, so it covers the object count of widgets too.
+type widgetEvaluator struct {
+	lister widgets.WidgetLister
+}
+
+var _ quotav1.Evaluator = &widgetEvaluator{}
+
+// widgetResources are the quota resources widgets count towards
+var widgetResources = []corev1.ResourceName{
+	generic.ObjectCountQuotaResourceNameFor(widgets.Resource("widgets")),
+	WidgetSize,
+}
+
+func (e *widgetEvaluator) Constraints(required []corev1.ResourceName, item runtime.Object) error {
+	return nil
+}
+
+func (e *widgetEvaluator) GroupResource() schema.GroupResource {
+	return widgets.Resource("widgets")
+}
+
+// Handles reports whether a changes the usage of widgets: creating one does, and so may an
+// update resizing one
+func (e *widgetEvaluator) Handles(a admission.Attributes) bool {
+	if a.GetSubresource() != "" {
+		return false
+	}
+	return a.GetOperation() == admission.Create || a.GetOperation() == admission.Update
+}
+
+func (e *widgetEvaluator) Matches(resourceQuota *corev1.ResourceQuota, item runtime.Object) (bool, error) {
+	return generic.Matches(resourceQuota, item, e.MatchingResources, generic.MatchesNoScopeFunc)
+}
+
+func (e *widgetEvaluator) MatchingResources(input []corev1.ResourceName) []corev1.ResourceName {
+	return quotav1.Intersection(input, widgetResources)
+}
+
+func (e *widgetEvaluator) MatchingScopes(item runtime.Object, scopes []corev1.ScopedResourceSelectorRequirement) ([]corev1.ScopedResourceSelectorRequirement, error) {
+	return []corev1.ScopedResourceSelectorRequirement{}, nil
+}
+
+func (e *widgetEvaluator) UncoveredQuotaScopes(limitedScopes []corev1.ScopedResourceSelectorRequirement,
+	matchedQuotaScopes []corev1.ScopedResourceSelectorRequirement) ([]corev1.ScopedResourceSelectorRequirement, error) {
+	return []corev1.ScopedResourceSelectorRequirement{}, nil
+}
+
+// Usage returns one widget of the size of item
+func (e *widgetEvaluator) Usage(item runtime.Object) (corev1.ResourceList, error) {
+	widget, ok := item.(*widgets.Widget)
+	if !ok {
+		return nil, fmt.Errorf("expected a widget, got %T", item)
+	}
+	return corev1.ResourceList{
+		widgetResources[0]: *resource.NewQuantity(1, resource.DecimalSI),
+		WidgetSize:         widget.Spec.Size.DeepCopy(),
+	}, nil
+}
+
+func (e *widgetEvaluator) UsageStats(options quotav1.UsageStatsOptions) (quotav1.UsageStats, error) {
+	return generic.CalculateUsageStats(options, func(namespace string) ([]runtime.Object, error) {
+		list, err := e.lister.Widgets(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]runtime.Object, len(list))
+		for i := range list {
+			objects[i] = list[i]
+		}
+		return objects, nil
+	}, generic.MatchesNoScopeFunc, e.Usage)
+}
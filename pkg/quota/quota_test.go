@@ -0,0 +1,137 @@
+package quota
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/admission"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+	"k8s.io/apiserver/pkg/quota/v1/generic"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+)
+
+const (
+	widgetCount corev1.ResourceName = "count/widgets.things.myorg.io"
+	gadgetCount corev1.ResourceName = "count/gadgets.things.myorg.io"
+)
+
+// newConfiguration returns the quota configuration of storage holding a widget of each size in
+// namespace default and one in namespace other, and a gadget in namespace default
+func newConfiguration(t *testing.T, sizes ...string) quotav1.Configuration {
+	t.Helper()
+	ctx := context.Background()
+	widgetStorage := widgets.NewMemoryStorage()
+	gadgetStorage := gadgets.NewGadgetStorage()
+	for i, size := range append(sizes, "100") {
+		namespace := "default"
+		if i == len(sizes) {
+			namespace = "other"
+		}
+		widget := &widgets.Widget{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "w" + size},
+			Spec:       widgets.WidgetSpec{Name: "w", Size: resource.MustParse(size)},
+		}
+		if _, err := widgetStorage.Create(ctx, widget); err != nil {
+			t.Fatalf("Failed to create widget: %v", err)
+		}
+	}
+	gadget := &gadgets.Gadget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "g"},
+		Spec:       gadgets.GadgetSpec{Type: "sensor", Version: "1.0.0"},
+	}
+	if _, err := gadgetStorage.Create(ctx, gadget); err != nil {
+		t.Fatalf("Failed to create gadget: %v", err)
+	}
+	return NewConfiguration(widgets.NewLister(widgetStorage), gadgets.NewLister(gadgetStorage))
+}
+
+func TestCalculateUsage(t *testing.T) {
+	configuration := newConfiguration(t, "1500m", "2")
+	hard := corev1.ResourceList{
+		widgetCount:                resource.MustParse("10"),
+		WidgetSize:                 resource.MustParse("10"),
+		gadgetCount:                resource.MustParse("10"),
+		corev1.ResourceRequestsCPU: resource.MustParse("1"),
+	}
+	usage, err := quotav1.CalculateUsage("default", nil, hard, generic.NewRegistry(configuration.Evaluators()), nil)
+	if err != nil {
+		t.Fatalf("Failed to calculate usage: %v", err)
+	}
+	expected := corev1.ResourceList{
+		widgetCount: resource.MustParse("2"),
+		WidgetSize:  resource.MustParse("3500m"),
+		gadgetCount: resource.MustParse("1"),
+	}
+	if !quotav1.Equals(usage, expected) {
+		t.Errorf("Expected usage %v, got %v", expected, usage)
+	}
+}
+
+func TestWidgetEvaluator_Handles(t *testing.T) {
+	evaluator := NewEvaluators(nil, nil)[0]
+	widget := &widgets.Widget{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "w"}}
+	for _, tc := range []struct {
+		operation   admission.Operation
+		subresource string
+		expected    bool
+	}{
+		{admission.Create, "", true},
+		{admission.Update, "", true},
+		{admission.Update, "status", false},
+		{admission.Delete, "", false},
+	} {
+		a := admission.NewAttributesRecord(widget, nil, widgets.SchemeGroupVersion.WithKind("Widget"), "default", "w",
+			widgets.SchemeGroupVersion.WithResource("widgets"), tc.subresource, tc.operation, nil, false, nil)
+		if handles := evaluator.Handles(a); handles != tc.expected {
+			t.Errorf("Expected %s %q to be handled %t, got %t", tc.operation, tc.subresource, tc.expected, handles)
+		}
+	}
+}
+
+func TestUsageController_Sync(t *testing.T) {
+	tracked := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "things"},
+		Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+			WidgetSize:                 resource.MustParse("10"),
+			corev1.ResourceRequestsCPU: resource.MustParse("1"),
+		}},
+		Status: corev1.ResourceQuotaStatus{Used: corev1.ResourceList{
+			WidgetSize:                 resource.MustParse("7"),
+			corev1.ResourceRequestsCPU: resource.MustParse("500m"),
+		}},
+	}
+	untracked := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pods"},
+		Spec:       corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")}},
+	}
+	client := fake.NewClientset(tracked, untracked)
+	controller := NewUsageController(client, newConfiguration(t, "1", "2"))
+	if err := controller.Sync(context.Background()); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+
+	// The usage of widgets is recounted, that of other resources kept
+	got, err := client.CoreV1().ResourceQuotas("default").Get(context.Background(), "things", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get quota: %v", err)
+	}
+	expected := corev1.ResourceList{WidgetSize: resource.MustParse("3"), corev1.ResourceRequestsCPU: resource.MustParse("500m")}
+	if !quotav1.Equals(got.Status.Used, expected) || !quotav1.Equals(got.Status.Hard, tracked.Spec.Hard) {
+		t.Errorf("Expected usage %v of %v, got %v of %v", expected, tracked.Spec.Hard, got.Status.Used, got.Status.Hard)
+	}
+
+	// Quotas not limiting widgets or gadgets are left alone
+	got, err = client.CoreV1().ResourceQuotas("default").Get(context.Background(), "pods", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get quota: %v", err)
+	}
+	if len(got.Status.Hard) != 0 || len(got.Status.Used) != 0 {
+		t.Errorf("Expected the pod quota not to be updated, got %v", got.Status)
+	}
+}
@@ -0,0 +1,85 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	quotav1 "k8s.io/apiserver/pkg/quota/v1"
+	"k8s.io/apiserver/pkg/quota/v1/generic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// UsageController recounts the usage ResourceQuotas record for widgets and gadgets. The
+// ResourceQuota admission plugin only adds to the usage as objects are created or grown, and
+// refuses quotas that do not record a usage yet; the kube-controller-manager recounts object
+// counts but knows nothing of WidgetSize. Recounting records the usage of new quotas and gives
+// back what deleted widgets and gadgets used.
+type UsageController struct {
+	client   kubernetes.Interface
+	registry quotav1.Registry
+}
+
+// NewUsageController returns a controller updating the quotas of client with the usage
+// measured by the evaluators of configuration
+func NewUsageController(client kubernetes.Interface, configuration quotav1.Configuration) *UsageController {
+	return &UsageController{client: client, registry: generic.NewRegistry(configuration.Evaluators())}
+}
+
+// Run recounts every period until ctx is done
+func (c *UsageController) Run(ctx context.Context, period time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.Sync(ctx); err != nil {
+			klog.ErrorS(err, "Failed to recount the quota usage of widgets and gadgets")
+		}
+	}, period)
+}
+
+// Sync recounts the usage of every quota limiting widgets or gadgets
+func (c *UsageController) Sync(ctx context.Context) error {
+	quotas, err := c.client.CoreV1().ResourceQuotas(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range quotas.Items {
+		if err := c.syncQuota(ctx, &quotas.Items[i]); err != nil {
+			errs = append(errs, fmt.Errorf("recounting quota %s/%s: %w", quotas.Items[i].Namespace, quotas.Items[i].Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// syncQuota records the measured usage of the resources of resourceQuota the evaluators cover,
+// keeping the usage of other resources as recorded. Like the kube-controller-manager, it also
+// records the limits of the quota in its status, which the admission plugin enforces.
+func (c *UsageController) syncQuota(ctx context.Context, resourceQuota *corev1.ResourceQuota) error {
+	usage, err := quotav1.CalculateUsage(resourceQuota.Namespace, resourceQuota.Spec.Scopes, resourceQuota.Spec.Hard,
+		c.registry, resourceQuota.Spec.ScopeSelector)
+	if err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		return nil
+	}
+
+	hard := quotav1.Add(corev1.ResourceList{}, resourceQuota.Spec.Hard)
+	used := quotav1.Add(corev1.ResourceList{}, resourceQuota.Status.Used)
+	for name, quantity := range usage {
+		used[name] = quantity
+	}
+	used = quotav1.Mask(used, quotav1.ResourceNames(hard))
+	if quotav1.Equals(hard, resourceQuota.Status.Hard) && quotav1.Equals(used, resourceQuota.Status.Used) {
+		return nil
+	}
+
+	updated := resourceQuota.DeepCopy()
+	updated.Status = corev1.ResourceQuotaStatus{Hard: hard, Used: used}
+	_, err = c.client.CoreV1().ResourceQuotas(updated.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,409 @@
+// Package rules compiles the CEL validation rules OpenAPI schemas declare in
+// x-kubernetes-validations and evaluates them against objects, as the API server does for
+// custom resources
+package rules
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common/types"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	apiservercel "k8s.io/apiserver/pkg/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+	"k8s.io/apiserver/pkg/cel/library"
+	celopenapi "k8s.io/apiserver/pkg/cel/openapi"
+	"k8s.io/apiserver/pkg/cel/openapi/resolver"
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+const (
+	// selfVar is the value a rule is declared on
+	selfVar = "self"
+
+	// oldSelfVar is the value self replaces on update. Rules using it are transition rules,
+	// which are only evaluated on update.
+	oldSelfVar = "oldSelf"
+)
+
+// fieldPathPattern matches the fieldPath of a rule, which names the field below self that
+// failures are reported for, such as .spec.size
+var fieldPathPattern = regexp.MustCompile(`^(\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+
+// NewSchemaResolver resolves the schemas of the kinds in schemes from the definitions
+// getDefinitions generates. Types with separate OpenAPI v2 and v3 definitions, such as
+// resource.Quantity, resolve to the v2 one, which CEL can type.
+func NewSchemaResolver(getDefinitions common.GetOpenAPIDefinitions, schemes ...*runtime.Scheme) resolver.SchemaResolver {
+	return resolver.NewDefinitionsSchemaResolver(func(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+		defs := getDefinitions(ref)
+		for name, def := range defs {
+			if v2, ok := def.Schema.Extensions[common.ExtensionV2Schema].(spec.Schema); ok {
+				def.Schema = v2
+				defs[name] = def
+			}
+		}
+		return defs
+	}, schemes...)
+}
+
+// Validator evaluates the rules of the schema of one kind. Objects are converted to the
+// version of that kind first, so the rules see the same fields whichever version they were
+// written in.
+type Validator struct {
+	gv        schema.GroupVersion
+	convertor runtime.ObjectConvertor
+	root      *node
+}
+
+// NewValidator compiles the rules of the schema resolver returns for gvk. Rules that do not
+// compile, do not return a bool or could cost more than the limits allow are reported.
+func NewValidator(resolver resolver.SchemaResolver, convertor runtime.ObjectConvertor,
+	gvk schema.GroupVersionKind) (*Validator, error) {
+	s, err := resolver.ResolveSchema(gvk)
+	if err != nil {
+		return nil, err
+	}
+	root, err := compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("compiling validation rules of %s: %w", gvk, err)
+	}
+	return &Validator{gv: gvk.GroupVersion(), convertor: convertor, root: root}, nil
+}
+
+// Validate returns the rules obj fails. On create old is nil and transition rules are
+// skipped. On update, values that did not change since old are not validated again, so
+// objects stored before a rule was added can still be updated.
+func (v *Validator) Validate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	if v.root == nil {
+		return nil
+	}
+	self, err := v.toUnstructured(obj)
+	if err != nil {
+		return field.ErrorList{field.InternalError(nil, err)}
+	}
+	var oldSelf interface{}
+	if old != nil {
+		if oldSelf, err = v.toUnstructured(old); err != nil {
+			return field.ErrorList{field.InternalError(nil, err)}
+		}
+	}
+	budget := int64(celconfig.RuntimeCELCostBudget)
+	return v.root.validate(ctx, nil, self, oldSelf, &budget)
+}
+
+func (v *Validator) toUnstructured(obj runtime.Object) (map[string]interface{}, error) {
+	versioned, err := v.convertor.ConvertToVersion(obj.DeepCopyObject(), v.gv)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(versioned)
+}
+
+// node holds the compiled rules of a schema and the nodes of the properties, items or
+// additional properties below it that have rules
+type node struct {
+	schema *spec.Schema
+	rules  []*rule
+
+	properties map[string]*node
+	items      *node
+	values     *node
+}
+
+// rule is one compiled x-kubernetes-validations rule
+type rule struct {
+	expression string
+	message    string
+	fieldPath  []string
+	transition bool
+	program    cel.Program
+}
+
+// compile returns the node of s, or nil if neither s nor the schemas below it have rules
+func compile(s *spec.Schema) (*node, error) {
+	var total uint64
+	return compileNode(s, true, nil, 1, &total)
+}
+
+// compileNode compiles the rules of s, which are evaluated up to cardinality times per
+// object, adding their estimated cost to total
+func compileNode(s *spec.Schema, isRoot bool, fldPath *field.Path, cardinality uint64, total *uint64) (*node, error) {
+	n := &node{schema: s}
+	validations := (&celopenapi.Schema{Schema: s}).XValidations()
+	if len(validations) != 0 {
+		declType := celopenapi.SchemaDeclType(s, isRoot)
+		if declType == nil {
+			return nil, fmt.Errorf("%s: rules are only supported on schemas CEL can type", describe(fldPath))
+		}
+		env, err := newEnv(declType)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", describe(fldPath), err)
+		}
+		for _, validation := range validations {
+			r, cost, err := compileRule(env, declType, validation.Rule(), validation.Message(), validation.FieldPath())
+			if err != nil {
+				return nil, fmt.Errorf("%s: rule %q: %w", describe(fldPath), validation.Rule(), err)
+			}
+			if *total = addCost(*total, multiplyCost(cost, cardinality)); *total > celconfig.RuntimeCELCostBudget {
+				return nil, fmt.Errorf("%s: rule %q: the estimated cost of the rules exceeds the budget of %d",
+					describe(fldPath), validation.Rule(), celconfig.RuntimeCELCostBudget)
+			}
+			n.rules = append(n.rules, r)
+		}
+	}
+
+	for name := range s.Properties {
+		prop := s.Properties[name]
+		child, err := compileNode(&prop, (&celopenapi.Schema{Schema: &prop}).IsXEmbeddedResource(),
+			fldPath.Child(name), cardinality, total)
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			if n.properties == nil {
+				n.properties = make(map[string]*node)
+			}
+			n.properties[name] = child
+		}
+	}
+	var err error
+	if s.Items != nil && s.Items.Schema != nil {
+		if n.items, err = compileNode(s.Items.Schema, false, fldPath.Child("items"),
+			multiplyCost(cardinality, maxElements(s)), total); err != nil {
+			return nil, err
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		if n.values, err = compileNode(s.AdditionalProperties.Schema, false, fldPath.Child("additionalProperties"),
+			multiplyCost(cardinality, maxElements(s)), total); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(n.rules) == 0 && n.properties == nil && n.items == nil && n.values == nil {
+		return nil, nil
+	}
+	return n, nil
+}
+
+// newEnv returns the environment the rules of a schema typed declType are compiled in
+func newEnv(declType *apiservercel.DeclType) (*cel.Env, error) {
+	scopedType := declType.MaybeAssignTypeName("selfType")
+	envSet, err := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), true).Extend(
+		environment.VersionedOptions{
+			IntroducedVersion: version.MajorMinor(1, 0),
+			EnvOptions: []cel.EnvOption{
+				cel.Variable(selfVar, scopedType.CelType()),
+				cel.Variable(oldSelfVar, scopedType.CelType()),
+			},
+			DeclTypes: []*apiservercel.DeclType{scopedType},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return envSet.NewExpressionsEnv(), nil
+}
+
+// compileRule compiles expression and returns its estimated worst case cost
+func compileRule(env *cel.Env, declType *apiservercel.DeclType, expression, message, fieldPath string) (*rule, uint64, error) {
+	if fieldPath != "" && !fieldPathPattern.MatchString(fieldPath) {
+		return nil, 0, fmt.Errorf("fieldPath %q must be a path of field names, such as .spec.size", fieldPath)
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, 0, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, 0, fmt.Errorf("must evaluate to a bool, not %v", ast.OutputType())
+	}
+
+	cost, err := env.EstimateCost(ast, &library.CostEstimator{SizeEstimator: &sizeEstimator{declType}})
+	if err != nil {
+		return nil, 0, err
+	}
+	if cost.Max > celconfig.PerCallLimit {
+		return nil, 0, fmt.Errorf("the estimated cost of %d exceeds the limit of %d per call", cost.Max, celconfig.PerCallLimit)
+	}
+	program, err := env.Program(ast,
+		cel.CostLimit(celconfig.PerCallLimit),
+		cel.CostTracking(&library.CostEstimator{}),
+		cel.InterruptCheckFrequency(celconfig.CheckFrequency),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r := &rule{expression: expression, message: message, program: program}
+	if fieldPath != "" {
+		r.fieldPath = strings.Split(fieldPath, ".")[1:]
+	}
+	for _, ref := range ast.NativeRep().ReferenceMap() {
+		if ref.Name == oldSelfVar {
+			r.transition = true
+		}
+	}
+	return r, cost.Max, nil
+}
+
+// validate evaluates the rules of n and the nodes below it against obj, which replaces old
+// on update. budget is the cost left for the object; once it runs out no more rules are
+// evaluated.
+func (n *node) validate(ctx context.Context, fldPath *field.Path, obj, old interface{}, budget *int64) field.ErrorList {
+	if obj == nil || (old != nil && equality.Semantic.DeepEqual(obj, old)) {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	for _, r := range n.rules {
+		if r.transition && old == nil {
+			continue
+		}
+		activation := map[string]interface{}{selfVar: celopenapi.UnstructuredToVal(obj, n.schema)}
+		if old != nil {
+			activation[oldSelfVar] = celopenapi.UnstructuredToVal(old, n.schema)
+		}
+		result, details, err := r.program.ContextEval(ctx, activation)
+		if details != nil && details.ActualCost() != nil {
+			*budget -= int64(*details.ActualCost())
+		}
+		if *budget < 0 {
+			return append(allErrs, field.Invalid(fldPath, field.OmitValueType{},
+				"validation failed due to running out of cost budget, no further validation rules will be run"))
+		}
+		switch {
+		case err != nil:
+			allErrs = append(allErrs, field.Invalid(fldPath, field.OmitValueType{}, fmt.Sprintf("rule %q: %v", r.expression, err)))
+		case result != types.True:
+			errPath, value := fldPath, obj
+			for _, name := range r.fieldPath {
+				errPath = errPath.Child(name)
+				if fields, ok := value.(map[string]interface{}); ok {
+					value = fields[name]
+				} else {
+					value = nil
+				}
+			}
+			message := r.message
+			if message == "" {
+				message = fmt.Sprintf("failed rule: %s", r.expression)
+			}
+			allErrs = append(allErrs, field.Invalid(errPath, errorValue(value), message))
+		}
+	}
+
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		oldValue, _ := old.(map[string]interface{})
+		for name, child := range n.properties {
+			allErrs = append(allErrs, child.validate(ctx, fldPath.Child(name), value[name], oldValue[name], budget)...)
+		}
+		if n.values != nil {
+			for key, v := range value {
+				allErrs = append(allErrs, n.values.validate(ctx, fldPath.Key(key), v, oldValue[key], budget)...)
+			}
+		}
+	case []interface{}:
+		if n.items != nil {
+			// Items are correlated with old ones by key in lists of type map only
+			oldItems, _ := old.([]interface{})
+			oldList := celopenapi.MakeMapList(n.schema, oldItems)
+			for i, item := range value {
+				allErrs = append(allErrs, n.items.validate(ctx, fldPath.Index(i), item, oldList.Get(item), budget)...)
+			}
+		}
+	}
+	return allErrs
+}
+
+// errorValue returns value to report in a field error. Objects and lists are reported by
+// their type, as for custom resources.
+func errorValue(value interface{}) interface{} {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return value
+}
+
+// sizeEstimator estimates the size of self and oldSelf and the values below them from the
+// limits of their schema, so that the cost of rules can be estimated when they are compiled
+type sizeEstimator struct {
+	declType *apiservercel.DeclType
+}
+
+func (e *sizeEstimator) EstimateSize(element checker.AstNode) *checker.SizeEstimate {
+	path := element.Path()
+	if len(path) == 0 || (path[0] != selfVar && path[0] != oldSelfVar) {
+		return nil
+	}
+	t := e.declType
+	for _, name := range path[1:] {
+		switch {
+		case t.IsObject():
+			f, ok := t.Fields[name]
+			if !ok {
+				return nil
+			}
+			t = f.Type
+		case name == "@items" || name == "@values":
+			t = t.ElemType
+		case name == "@keys":
+			t = t.KeyType
+		default:
+			return nil
+		}
+		if t == nil {
+			return nil
+		}
+	}
+	return &checker.SizeEstimate{Min: 0, Max: uint64(t.MaxElements)}
+}
+
+func (e *sizeEstimator) EstimateCallCost(function, overloadID string, target *checker.AstNode,
+	args []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+// maxElements returns the most items or properties the list or map s may hold
+func maxElements(s *spec.Schema) uint64 {
+	if declType := celopenapi.SchemaDeclType(s, false); declType != nil {
+		return uint64(declType.MaxElements)
+	}
+	return math.MaxUint64
+}
+
+func addCost(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+func multiplyCost(a, b uint64) uint64 {
+	if a != 0 && b > math.MaxUint64/a {
+		return math.MaxUint64
+	}
+	return a * b
+}
+
+// describe names the schema at fldPath in compilation errors
+func describe(fldPath *field.Path) string {
+	if fldPath == nil {
+		return "<root>"
+	}
+	return fldPath.String()
+}
@@ -0,0 +1,204 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// withRules returns s declaring rules, each given as rule, message and fieldPath
+func withRules(s *spec.Schema, rules ...[3]string) *spec.Schema {
+	var validations []interface{}
+	for _, r := range rules {
+		validations = append(validations, map[string]interface{}{"rule": r[0], "message": r[1], "fieldPath": r[2]})
+	}
+	s.AddExtension("x-kubernetes-validations", validations)
+	return s
+}
+
+// itemSchema is a list item with a key and a value that cannot decrease
+func itemSchema() *spec.Schema {
+	return withRules(&spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:     []string{"object"},
+		Required: []string{"key"},
+		Properties: map[string]spec.Schema{
+			"key":   *spec.StringProperty(),
+			"value": *spec.Int64Property(),
+		},
+	}}, [3]string{"self.value >= oldSelf.value", "cannot decrease", ".value"})
+}
+
+// objectSchema has a spec whose count must be positive and cannot change, and a list of map
+// items whose values cannot decrease
+func objectSchema() *spec.Schema {
+	items := spec.ArrayProperty(itemSchema())
+	items.MaxItems = ptr(int64(10))
+	items.AddExtension("x-kubernetes-list-type", "map")
+	items.AddExtension("x-kubernetes-list-map-keys", []interface{}{"key"})
+	specSchema := withRules(&spec.Schema{SchemaProps: spec.SchemaProps{
+		Type: []string{"object"},
+		Properties: map[string]spec.Schema{
+			"count": *spec.Int64Property(),
+			"items": *items,
+		},
+	}},
+		[3]string{"self.count > 0", "", ""},
+		[3]string{"self.count == oldSelf.count", "is immutable", ".count"},
+	)
+	return &spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:       []string{"object"},
+		Properties: map[string]spec.Schema{"spec": *specSchema},
+	}}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func validate(t *testing.T, n *node, obj, old interface{}) []string {
+	t.Helper()
+	budget := int64(celconfig.RuntimeCELCostBudget)
+	var errs []string
+	for _, err := range n.validate(context.Background(), nil, obj, old, &budget) {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+func object(count int64, items ...map[string]interface{}) map[string]interface{} {
+	list := make([]interface{}, len(items))
+	for i, item := range items {
+		list[i] = item
+	}
+	return map[string]interface{}{"spec": map[string]interface{}{"count": count, "items": list}}
+}
+
+func withStatus(obj map[string]interface{}, status string) map[string]interface{} {
+	obj["status"] = status
+	return obj
+}
+
+func item(key string, value int64) map[string]interface{} {
+	return map[string]interface{}{"key": key, "value": value}
+}
+
+func TestValidate(t *testing.T) {
+	n, err := compile(objectSchema())
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		obj, old map[string]interface{}
+		expected []string
+	}{
+		{
+			// Transition rules are skipped on create
+			name: "valid create",
+			obj:  object(1, item("a", 1)),
+		},
+		{
+			name:     "failed rule without message",
+			obj:      object(0),
+			expected: []string{"spec: Invalid value: \"object\": failed rule: self.count > 0"},
+		},
+		{
+			name:     "transition rule reported for its field path",
+			obj:      object(2),
+			old:      object(1),
+			expected: []string{"spec.count: Invalid value: 2: is immutable"},
+		},
+		{
+			name:     "items correlated by key",
+			obj:      object(1, item("b", 5), item("a", 1)),
+			old:      object(1, item("a", 2), item("b", 5)),
+			expected: []string{"spec.items[1].value: Invalid value: 1: cannot decrease"},
+		},
+		{
+			name: "new items have no old value",
+			obj:  object(1, item("a", 2), item("c", 0)),
+			old:  object(1, item("a", 2)),
+		},
+		{
+			// The rules of values that did not change are not evaluated again
+			name: "unchanged values ratchet",
+			obj:  withStatus(object(0, item("a", 1)), "updated"),
+			old:  object(0, item("a", 1)),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var old interface{}
+			if tc.old != nil {
+				old = tc.old
+			}
+			errs := validate(t, n, tc.obj, old)
+			if strings.Join(errs, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("Expected errors %q, got %q", tc.expected, errs)
+			}
+		})
+	}
+}
+
+func TestCompile_NoRules(t *testing.T) {
+	n, err := compile(&spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:       []string{"object"},
+		Properties: map[string]spec.Schema{"name": *spec.StringProperty()},
+	}})
+	if err != nil || n != nil {
+		t.Errorf("Expected a schema without rules to compile to nothing, got %v, %v", n, err)
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		rule     [3]string
+		expected string
+	}{
+		{"syntax", [3]string{"self.count >", "", ""}, "Syntax error"},
+		{"unknown field", [3]string{"self.missing > 0", "", ""}, "undefined field 'missing'"},
+		{"not a bool", [3]string{"self.count + 1", "", ""}, "must evaluate to a bool"},
+		{"field path", [3]string{"self.count > 0", "", "spec[0]"}, "must be a path of field names"},
+		{
+			"cost",
+			[3]string{"self.names.all(a, self.names.all(b, self.names.all(c, a + b + c != '')))", "", ""},
+			"exceeds the limit",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := withRules(&spec.Schema{SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"count": *spec.Int64Property(),
+					"names": *spec.ArrayProperty(spec.StringProperty()),
+				},
+			}}, tc.rule)
+			if _, err := compile(s); err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Budget(t *testing.T) {
+	names := spec.ArrayProperty(spec.StringProperty())
+	names.MaxItems = ptr(int64(100))
+	n, err := compile(withRules(&spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:       []string{"object"},
+		Properties: map[string]spec.Schema{"names": *names},
+	}}, [3]string{"self.names.all(name, name != '')", "", ""}))
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+
+	obj := map[string]interface{}{"names": []interface{}{"a", "b", "c", "d", "e", "f", "g", "h"}}
+	budget := int64(10)
+	errs := n.validate(context.Background(), nil, obj, nil, &budget)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "running out of cost budget") {
+		t.Errorf("Expected the budget to run out, got %v", errs)
+	}
+}
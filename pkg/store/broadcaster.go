@@ -0,0 +1,292 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// DefaultHistorySize is the number of events kept for watches resuming from a resourceVersion
+	DefaultHistorySize = 1000
+
+	// DefaultBufferSize is the number of events buffered per watcher before it is terminated
+	DefaultBufferSize = 100
+)
+
+// Event is a single change recorded by a Broadcaster
+type Event struct {
+	Type   watch.EventType
+	Object runtime.Object
+
+	// PrevObject is the object before a modification, at the revision of the event
+	PrevObject runtime.Object
+	Revision   int64
+}
+
+// WatchOptions controls where a new watch starts
+type WatchOptions struct {
+	// Revision is the resourceVersion the watch starts after; recorded events
+	// newer than it are replayed before live events
+	Revision int64
+
+	// Initial objects are delivered as ADDED events before anything else
+	Initial []runtime.Object
+
+	// InitialEventsEnd sends a bookmark annotated with metav1.InitialEventsAnnotationKey
+	// once the initial objects have been delivered
+	InitialEventsEnd bool
+
+	// Filter restricts the recorded events delivered to the watcher; nil matches everything.
+	// A modification that makes an object start or stop matching is delivered as ADDED or DELETED.
+	Filter func(runtime.Object) bool
+}
+
+// Broadcaster fans out the events of a single resource to its watchers and keeps
+// a bounded history of recent events so watches can resume from a resourceVersion.
+// Each watcher has its own bounded buffer; a watcher that falls behind is closed
+// and has to re-establish its watch.
+type Broadcaster struct {
+	resource    schema.GroupResource
+	newFunc     func() runtime.Object
+	historySize int
+	bufferSize  int
+
+	mu       sync.Mutex
+	history  []Event
+	oldest   int64
+	watchers map[int64]*watcher
+	nextID   int64
+}
+
+func NewBroadcaster(resource schema.GroupResource, newFunc func() runtime.Object) *Broadcaster {
+	return &Broadcaster{
+		resource:    resource,
+		newFunc:     newFunc,
+		historySize: DefaultHistorySize,
+		bufferSize:  DefaultBufferSize,
+		watchers:    make(map[int64]*watcher),
+	}
+}
+
+// Action records an event and delivers it to all watchers. prevObj is the object a
+// modification replaced and nil for other events. Callers must invoke it while holding
+// the lock that orders their writes so events arrive in revision order.
+func (b *Broadcaster) Action(eventType watch.EventType, obj, prevObj runtime.Object, revision int64) {
+	event := Event{Type: eventType, Object: obj.DeepCopyObject(), Revision: revision}
+	if prevObj != nil {
+		event.PrevObject = prevObj.DeepCopyObject()
+		if accessor, err := meta.Accessor(event.PrevObject); err == nil {
+			accessor.SetResourceVersion(fmt.Sprintf("%d", revision))
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.oldest = b.history[0].Revision
+		b.history = b.history[1:]
+	}
+
+	for id, w := range b.watchers {
+		if _, ok := w.convert(event); !ok {
+			continue
+		}
+		select {
+		case w.input <- event:
+		default:
+			// The watcher cannot keep up; close it so the client re-watches
+			delete(b.watchers, id)
+			close(w.input)
+		}
+	}
+}
+
+// ExpireThrough drops the history up to and including revision, as when events before a restart
+// were not retained, so watches starting before it fail with 410 Gone and clients relist
+func (b *Broadcaster) ExpireThrough(revision int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if revision <= b.oldest {
+		return
+	}
+	b.oldest = revision
+	for len(b.history) > 0 && b.history[0].Revision <= revision {
+		b.history = b.history[1:]
+	}
+}
+
+// Watch starts a new watcher. It fails with 410 Gone if events newer than
+// opts.Revision are no longer retained.
+func (b *Broadcaster) Watch(ctx context.Context, opts WatchOptions) (watch.Interface, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if opts.Revision < b.oldest {
+		return nil, errors.NewResourceExpired(fmt.Sprintf("too old resource version: %d (%d)", opts.Revision, b.oldest+1))
+	}
+
+	w := &watcher{
+		broadcaster: b,
+		id:          b.nextID,
+		filter:      opts.Filter,
+		input:       make(chan Event, b.bufferSize),
+		result:      make(chan watch.Event),
+		done:        make(chan struct{}),
+	}
+	b.nextID++
+	b.watchers[w.id] = w
+
+	var replay []watch.Event
+	for _, event := range b.history {
+		if event.Revision <= opts.Revision {
+			continue
+		}
+		if converted, ok := w.convert(event); ok {
+			replay = append(replay, converted)
+		}
+	}
+
+	initial := make([]watch.Event, 0, len(opts.Initial)+len(replay)+1)
+	for _, obj := range opts.Initial {
+		initial = append(initial, watch.Event{Type: watch.Added, Object: obj})
+	}
+	if opts.InitialEventsEnd {
+		bookmark, err := b.bookmark(opts.Revision)
+		if err != nil {
+			delete(b.watchers, w.id)
+			return nil, err
+		}
+		initial = append(initial, watch.Event{Type: watch.Bookmark, Object: bookmark})
+	}
+	initial = append(initial, replay...)
+
+	go w.run(ctx, initial)
+	return w, nil
+}
+
+// Shutdown closes all watchers
+func (b *Broadcaster) Shutdown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, w := range b.watchers {
+		delete(b.watchers, id)
+		close(w.input)
+	}
+}
+
+func (b *Broadcaster) bookmark(revision int64) (runtime.Object, error) {
+	obj := b.newFunc()
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	accessor.SetResourceVersion(fmt.Sprintf("%d", revision))
+	accessor.SetAnnotations(map[string]string{metav1.InitialEventsAnnotationKey: "true"})
+	return obj, nil
+}
+
+func (b *Broadcaster) remove(id int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if w, ok := b.watchers[id]; ok {
+		delete(b.watchers, id)
+		close(w.input)
+	}
+}
+
+type watcher struct {
+	broadcaster *Broadcaster
+	id          int64
+	filter      func(runtime.Object) bool
+	input       chan Event
+	result      chan watch.Event
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// convert returns the event as this watcher sees it through its filter, if at all
+func (w *watcher) convert(event Event) (watch.Event, bool) {
+	if w.filter == nil {
+		return watch.Event{Type: event.Type, Object: event.Object}, true
+	}
+
+	matches := w.filter(event.Object)
+	if event.PrevObject == nil {
+		return watch.Event{Type: event.Type, Object: event.Object}, matches
+	}
+	matched := w.filter(event.PrevObject)
+	switch {
+	case matches && matched:
+		return watch.Event{Type: event.Type, Object: event.Object}, true
+	case matches:
+		return watch.Event{Type: watch.Added, Object: event.Object}, true
+	case matched:
+		return watch.Event{Type: watch.Deleted, Object: event.PrevObject}, true
+	default:
+		return watch.Event{}, false
+	}
+}
+
+func (w *watcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+func (w *watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.broadcaster.remove(w.id)
+	})
+}
+
+func (w *watcher) run(ctx context.Context, initial []watch.Event) {
+	defer close(w.result)
+
+	for _, event := range initial {
+		if !w.send(ctx, event) {
+			return
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-w.input:
+			if !ok {
+				return
+			}
+			converted, _ := w.convert(event)
+			if !w.send(ctx, converted) {
+				return
+			}
+		case <-w.done:
+			return
+		case <-ctx.Done():
+			w.Stop()
+			return
+		}
+	}
+}
+
+func (w *watcher) send(ctx context.Context, event watch.Event) bool {
+	select {
+	case w.result <- event:
+		return true
+	case <-w.done:
+		return false
+	case <-ctx.Done():
+		w.Stop()
+		return false
+	}
+}
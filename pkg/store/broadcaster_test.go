@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func newTestObject(name string, revision int64) runtime.Object {
+	return &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: fmt.Sprintf("%d", revision)},
+	}
+}
+
+func newTestBroadcaster() *Broadcaster {
+	return NewBroadcaster(schema.GroupResource{Group: "test", Resource: "things"},
+		func() runtime.Object { return &metav1.PartialObjectMetadata{} })
+}
+
+func nextEvent(t *testing.T, w watch.Interface) watch.Event {
+	t.Helper()
+	select {
+	case event, ok := <-w.ResultChan():
+		if !ok {
+			t.Fatal("Watch channel closed unexpectedly")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for watch event")
+	}
+	return watch.Event{}
+}
+
+func eventName(event watch.Event) string {
+	return event.Object.(*metav1.PartialObjectMetadata).Name
+}
+
+func TestBroadcaster_LiveEvents(t *testing.T) {
+	b := newTestBroadcaster()
+
+	w, err := b.Watch(context.Background(), WatchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer w.Stop()
+
+	b.Action(watch.Added, newTestObject("a", 1), nil, 1)
+	b.Action(watch.Modified, newTestObject("a", 2), nil, 2)
+	b.Action(watch.Deleted, newTestObject("a", 3), nil, 3)
+
+	for _, expected := range []watch.EventType{watch.Added, watch.Modified, watch.Deleted} {
+		event := nextEvent(t, w)
+		if event.Type != expected {
+			t.Errorf("Expected %s event, got %s", expected, event.Type)
+		}
+	}
+}
+
+func TestBroadcaster_Resume(t *testing.T) {
+	b := newTestBroadcaster()
+	for i := int64(1); i <= 3; i++ {
+		b.Action(watch.Added, newTestObject(fmt.Sprintf("obj-%d", i), i), nil, i)
+	}
+
+	w, err := b.Watch(context.Background(), WatchOptions{Revision: 1})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer w.Stop()
+
+	for _, expected := range []string{"obj-2", "obj-3"} {
+		if name := eventName(nextEvent(t, w)); name != expected {
+			t.Errorf("Expected replayed event for %s, got %s", expected, name)
+		}
+	}
+}
+
+func TestBroadcaster_TooOldResourceVersion(t *testing.T) {
+	b := newTestBroadcaster()
+	b.historySize = 2
+	for i := int64(1); i <= 5; i++ {
+		b.Action(watch.Added, newTestObject(fmt.Sprintf("obj-%d", i), i), nil, i)
+	}
+
+	_, err := b.Watch(context.Background(), WatchOptions{Revision: 1})
+	if !errors.IsResourceExpired(err) {
+		t.Errorf("Expected 410 Gone for too old resource version, got %v", err)
+	}
+
+	w, err := b.Watch(context.Background(), WatchOptions{Revision: 3})
+	if err != nil {
+		t.Fatalf("Expected watch from retained revision to succeed: %v", err)
+	}
+	w.Stop()
+}
+
+func TestBroadcaster_InitialEvents(t *testing.T) {
+	b := newTestBroadcaster()
+
+	w, err := b.Watch(context.Background(), WatchOptions{
+		Revision:         7,
+		Initial:          []runtime.Object{newTestObject("a", 3), newTestObject("b", 7)},
+		InitialEventsEnd: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer w.Stop()
+
+	for _, expected := range []string{"a", "b"} {
+		event := nextEvent(t, w)
+		if event.Type != watch.Added || eventName(event) != expected {
+			t.Errorf("Expected ADDED event for %s, got %s %s", expected, event.Type, eventName(event))
+		}
+	}
+
+	bookmark := nextEvent(t, w)
+	if bookmark.Type != watch.Bookmark {
+		t.Fatalf("Expected BOOKMARK event, got %s", bookmark.Type)
+	}
+	meta := bookmark.Object.(*metav1.PartialObjectMetadata)
+	if meta.ResourceVersion != "7" || meta.Annotations[metav1.InitialEventsAnnotationKey] != "true" {
+		t.Errorf("Unexpected bookmark metadata: %+v", meta.ObjectMeta)
+	}
+}
+
+func TestBroadcaster_SlowWatcherIsClosed(t *testing.T) {
+	b := newTestBroadcaster()
+	b.bufferSize = 2
+
+	w, err := b.Watch(context.Background(), WatchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer w.Stop()
+
+	// Nobody reads from the watch, so the buffer overflows
+	for i := int64(1); i <= 10; i++ {
+		b.Action(watch.Added, newTestObject(fmt.Sprintf("obj-%d", i), i), nil, i)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Expected slow watcher to be closed")
+		}
+	}
+}
+
+func TestBroadcaster_StopAndContextCancel(t *testing.T) {
+	b := newTestBroadcaster()
+
+	w, err := b.Watch(context.Background(), WatchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	w.Stop()
+	w.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err = b.Watch(ctx, WatchOptions{})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-w.ResultChan():
+		if ok {
+			t.Error("Expected no events after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected watch to close after context cancellation")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.watchers) != 0 {
+		t.Errorf("Expected all watchers to be removed, got %d", len(b.watchers))
+	}
+}
+
+func TestBroadcaster_FilterTransitions(t *testing.T) {
+	b := newTestBroadcaster()
+
+	selected := func(name string, revision int64, selected bool) runtime.Object {
+		obj := newTestObject(name, revision).(*metav1.PartialObjectMetadata)
+		if selected {
+			obj.Labels = map[string]string{"selected": "true"}
+		}
+		return obj
+	}
+	filter := func(obj runtime.Object) bool {
+		return obj.(*metav1.PartialObjectMetadata).Labels["selected"] == "true"
+	}
+
+	w, err := b.Watch(context.Background(), WatchOptions{Filter: filter})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer w.Stop()
+
+	b.Action(watch.Added, selected("a", 1, false), nil, 1)
+	b.Action(watch.Modified, selected("a", 2, true), selected("a", 1, false), 2)
+	b.Action(watch.Modified, selected("a", 3, true), selected("a", 2, true), 3)
+	b.Action(watch.Modified, selected("a", 4, false), selected("a", 3, true), 4)
+	b.Action(watch.Deleted, selected("a", 5, false), nil, 5)
+
+	for _, expected := range []struct {
+		eventType watch.EventType
+		revision  string
+	}{
+		{watch.Added, "2"},
+		{watch.Modified, "3"},
+		{watch.Deleted, "4"},
+	} {
+		event := nextEvent(t, w)
+		meta := event.Object.(*metav1.PartialObjectMetadata)
+		if event.Type != expected.eventType || meta.ResourceVersion != expected.revision {
+			t.Errorf("Expected %s at %s, got %s at %s", expected.eventType, expected.revision, event.Type, meta.ResourceVersion)
+		}
+	}
+
+	// Replayed events are converted the same way
+	resumed, err := b.Watch(context.Background(), WatchOptions{Revision: 3, Filter: filter})
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer resumed.Stop()
+	if event := nextEvent(t, resumed); event.Type != watch.Deleted {
+		t.Errorf("Expected replayed DELETED event, got %s", event.Type)
+	}
+}
@@ -0,0 +1,39 @@
+package store
+
+import "sync"
+
+// Clock is the logical clock resourceVersions are taken from. A single Clock is shared by
+// all resources a server stores so their resourceVersions are comparable and never reused.
+type Clock struct {
+	mu       sync.Mutex
+	revision int64
+}
+
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Current returns the latest revision handed out
+func (c *Clock) Current() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.revision
+}
+
+// Next advances the clock and returns the new revision
+func (c *Clock) Next() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revision++
+	return c.revision
+}
+
+// Advance moves the clock forward to at least revision, e.g. past revisions recovered
+// from persisted state. It never moves the clock backwards.
+func (c *Clock) Advance(revision int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if revision > c.revision {
+		c.revision = revision
+	}
+}
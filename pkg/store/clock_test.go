@@ -0,0 +1,25 @@
+package store
+
+import "testing"
+
+func TestClock(t *testing.T) {
+	c := NewClock()
+	if c.Current() != 0 {
+		t.Errorf("Expected a new clock at 0, got %d", c.Current())
+	}
+	if next := c.Next(); next != 1 || c.Current() != 1 {
+		t.Errorf("Expected Next to return and keep 1, got %d and %d", next, c.Current())
+	}
+
+	c.Advance(10)
+	if c.Current() != 10 {
+		t.Errorf("Expected clock at 10 after Advance, got %d", c.Current())
+	}
+	c.Advance(5)
+	if c.Current() != 10 {
+		t.Errorf("Expected Advance never to move backwards, got %d", c.Current())
+	}
+	if next := c.Next(); next != 11 {
+		t.Errorf("Expected 11, got %d", next)
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DeleteAction is what deleting an object amounts to, given its finalizers
+type DeleteAction int
+
+const (
+	// KeepObject leaves the object as it is, e.g. because it is already being deleted
+	KeepObject DeleteAction = iota
+	// RemoveObject removes the object from storage
+	RemoveObject
+	// UpdateObject stores the object marked for deletion until its finalizers are removed
+	UpdateObject
+)
+
+// DeleteFailure is an object a delete collection failed to remove or update, named
+// namespace/name, and the error it failed with
+type DeleteFailure struct {
+	Name string
+	Err  error
+}
+
+// NewPartialDeleteError reports a delete collection of resource that removed or marked for
+// deletion the objects named in deleted but failed on those in failed, which must not be empty.
+// Storage deleting one object at a time returns it, as the changes it made are kept. It has the
+// code and reason of the first failure, and a cause for every failure.
+func NewPartialDeleteError(resource schema.GroupResource, deleted []string, failed []DeleteFailure) *errors.StatusError {
+	status := metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   http.StatusInternalServerError,
+		Reason: metav1.StatusReasonInternalError,
+		Details: &metav1.StatusDetails{
+			Group: resource.Group,
+			Kind:  resource.Resource,
+		},
+	}
+	if apiStatus, ok := failed[0].Err.(errors.APIStatus); ok {
+		status.Code = apiStatus.Status().Code
+		status.Reason = apiStatus.Status().Reason
+	}
+
+	names := make([]string, len(failed))
+	for i, failure := range failed {
+		names[i] = failure.Name
+		status.Details.Causes = append(status.Details.Causes, metav1.StatusCause{
+			Message: fmt.Sprintf("%s: %v", failure.Name, failure.Err),
+		})
+	}
+	status.Message = fmt.Sprintf("deleted only some of the selected %s: deleted [%s], not deleted [%s]",
+		resource.String(), strings.Join(deleted, ", "), strings.Join(names, ", "))
+	return &errors.StatusError{ErrStatus: status}
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPartialDeleteError(t *testing.T) {
+	resource := schema.GroupResource{Group: "things.myorg.io", Resource: "widgets"}
+	err := NewPartialDeleteError(resource, []string{"default/a", "default/c"}, []DeleteFailure{
+		{Name: "default/b", Err: errors.NewConflict(resource, "b", fmt.Errorf("changed"))},
+		{Name: "default/d", Err: fmt.Errorf("connection lost")},
+	})
+
+	if !errors.IsConflict(err) || err.Status().Code != http.StatusConflict {
+		t.Errorf("Expected the code and reason of the first failure, got %v", err.Status())
+	}
+	if message := err.Error(); !strings.Contains(message, "deleted [default/a, default/c]") ||
+		!strings.Contains(message, "not deleted [default/b, default/d]") {
+		t.Errorf("Expected the message to list what was and was not deleted, got %q", message)
+	}
+	causes := err.Status().Details.Causes
+	if len(causes) != 2 || !strings.HasPrefix(causes[0].Message, "default/b: ") ||
+		causes[1].Message != "default/d: connection lost" {
+		t.Errorf("Expected a cause for every failure, got %v", causes)
+	}
+
+	// Failures that are not API errors are internal errors
+	err = NewPartialDeleteError(resource, nil, []DeleteFailure{{Name: "default/a", Err: fmt.Errorf("connection lost")}})
+	if err.Status().Reason != metav1.StatusReasonInternalError || err.Status().Code != http.StatusInternalServerError {
+		t.Errorf("Expected an internal error, got %v", err.Status())
+	}
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/generic"
+	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/apiserver/pkg/storage"
+	storeerr "k8s.io/apiserver/pkg/storage/errors"
+	"k8s.io/apiserver/pkg/storage/names"
+)
+
+// Etcd keeps the objects of one namespaced resource in etcd. It is built on the generic
+// registry store, which provides the key layout, the storage codec and the watch cache
+// configured through the etcd flags, but only its storage is used: the REST handlers on
+// top implement the API semantics for every backend alike.
+type Etcd struct {
+	resource schema.GroupResource
+	store    *genericregistry.Store
+}
+
+// NewEtcd returns etcd storage for resource. attrFunc returns the labels and fields objects
+// are selected by, including in the watch cache.
+func NewEtcd(typer runtime.ObjectTyper, resource schema.GroupResource, singular string,
+	newFunc, newListFunc func() runtime.Object, attrFunc storage.AttrFunc, optsGetter generic.RESTOptionsGetter) (*Etcd, error) {
+	strategy := storageStrategy{ObjectTyper: typer, NameGenerator: names.SimpleNameGenerator}
+	s := &genericregistry.Store{
+		NewFunc:                   newFunc,
+		NewListFunc:               newListFunc,
+		DefaultQualifiedResource:  resource,
+		SingularQualifiedResource: schema.GroupResource{Group: resource.Group, Resource: singular},
+		CreateStrategy:            strategy,
+		DeleteStrategy:            strategy,
+		TableConvertor:            rest.NewDefaultTableConvertor(resource),
+	}
+	if err := s.CompleteWithOptions(&generic.StoreOptions{RESTOptions: optsGetter, AttrFunc: attrFunc}); err != nil {
+		return nil, err
+	}
+
+	return &Etcd{resource: resource, store: s}, nil
+}
+
+func (e *Etcd) Get(ctx context.Context, namespace, name string, out runtime.Object) error {
+	key, err := e.store.KeyFunc(genericapirequest.WithNamespace(ctx, namespace), name)
+	if err != nil {
+		return err
+	}
+	if err := e.store.Storage.Get(ctx, key, storage.GetOptions{}, out); err != nil {
+		return storeerr.InterpretGetError(err, e.resource, name)
+	}
+	return nil
+}
+
+// List fills listObj with the objects matching predicate in namespace, or in all namespaces
+// if namespace is empty
+func (e *Etcd) List(ctx context.Context, namespace string, predicate storage.SelectionPredicate, listObj runtime.Object) error {
+	opts := storage.ListOptions{
+		Predicate: predicate,
+		Recursive: true,
+	}
+	if err := e.store.Storage.GetList(ctx, e.store.KeyRootFunc(genericapirequest.WithNamespace(ctx, namespace)), opts, listObj); err != nil {
+		return storeerr.InterpretListError(err, e.resource)
+	}
+	return nil
+}
+
+func (e *Etcd) Create(ctx context.Context, obj, out runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	key, err := e.store.KeyFunc(genericapirequest.WithNamespace(ctx, accessor.GetNamespace()), accessor.GetName())
+	if err != nil {
+		return err
+	}
+
+	// etcd assigns the resourceVersion
+	accessor.SetResourceVersion("")
+	if err := e.store.Storage.Create(ctx, key, obj, out, 0, false); err != nil {
+		return storeerr.InterpretCreateError(err, e.resource, accessor.GetName())
+	}
+	return nil
+}
+
+// Update replaces the stored object. A non-empty resourceVersion on obj must match the
+// stored one; the UID and creation timestamp are always carried over.
+func (e *Etcd) Update(ctx context.Context, obj, out runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	key, err := e.store.KeyFunc(genericapirequest.WithNamespace(ctx, accessor.GetNamespace()), accessor.GetName())
+	if err != nil {
+		return err
+	}
+
+	err = e.store.Storage.GuaranteedUpdate(ctx, key, out, false, nil,
+		func(existing runtime.Object, _ storage.ResponseMeta) (runtime.Object, *uint64, error) {
+			existingAccessor, err := meta.Accessor(existing)
+			if err != nil {
+				return nil, nil, err
+			}
+			if rv := accessor.GetResourceVersion(); rv != "" && rv != existingAccessor.GetResourceVersion() {
+				return nil, nil, NewOptimisticLockError(e.resource, accessor.GetName())
+			}
+
+			updated := obj.DeepCopyObject()
+			updatedAccessor, err := meta.Accessor(updated)
+			if err != nil {
+				return nil, nil, err
+			}
+			updatedAccessor.SetUID(existingAccessor.GetUID())
+			updatedAccessor.SetCreationTimestamp(existingAccessor.GetCreationTimestamp())
+			return updated, nil, nil
+		}, false, nil)
+	if err != nil {
+		return storeerr.InterpretUpdateError(err, e.resource, accessor.GetName())
+	}
+	return nil
+}
+
+// Delete removes the object if it satisfies preconditions and stores its final state in out
+func (e *Etcd) Delete(ctx context.Context, namespace, name string, preconditions *metav1.Preconditions, out runtime.Object) error {
+	key, err := e.store.KeyFunc(genericapirequest.WithNamespace(ctx, namespace), name)
+	if err != nil {
+		return err
+	}
+	var storagePreconditions *storage.Preconditions
+	if preconditions != nil {
+		storagePreconditions = &storage.Preconditions{UID: preconditions.UID, ResourceVersion: preconditions.ResourceVersion}
+	}
+	if err := e.store.Storage.Delete(ctx, key, out, storagePreconditions, storage.ValidateAllObjectFunc, false, nil, storage.DeleteOptions{}); err != nil {
+		return storeerr.InterpretDeleteError(err, e.resource, name)
+	}
+	return nil
+}
+
+// Watch streams changes to the objects matching predicate in namespace, or in all
+// namespaces if namespace is empty
+func (e *Etcd) Watch(ctx context.Context, namespace string, predicate storage.SelectionPredicate,
+	options *internalversion.ListOptions) (watch.Interface, error) {
+	predicate.AllowWatchBookmarks = options.AllowWatchBookmarks
+
+	opts := storage.ListOptions{
+		ResourceVersion:      options.ResourceVersion,
+		ResourceVersionMatch: options.ResourceVersionMatch,
+		Predicate:            predicate,
+		Recursive:            true,
+		SendInitialEvents:    options.SendInitialEvents,
+	}
+	w, err := e.store.Storage.Watch(ctx, e.store.KeyRootFunc(genericapirequest.WithNamespace(ctx, namespace)), opts)
+	if err != nil {
+		return nil, storeerr.InterpretWatchError(err, e.resource, "")
+	}
+	return w, nil
+}
+
+// Destroy releases the etcd client and stops the watch cache
+func (e *Etcd) Destroy() {
+	if e.store.DestroyFunc != nil {
+		e.store.DestroyFunc()
+	}
+}
+
+// storageStrategy only tells the generic registry store how the resource is scoped;
+// the store's own create and delete flows are never used.
+type storageStrategy struct {
+	runtime.ObjectTyper
+	names.NameGenerator
+}
+
+func (storageStrategy) NamespaceScoped() bool {
+	return true
+}
+
+func (storageStrategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {}
+
+func (storageStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	return nil
+}
+
+func (storageStrategy) WarningsOnCreate(ctx context.Context, obj runtime.Object) []string {
+	return nil
+}
+
+func (storageStrategy) Canonicalize(obj runtime.Object) {}
@@ -0,0 +1,228 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+)
+
+// startEtcd runs a throwaway etcd from $ETCD_BIN or the PATH and returns its client URL.
+// Tests needing etcd are skipped when no binary is available.
+func startEtcd(t *testing.T) string {
+	t.Helper()
+
+	binary := os.Getenv("ETCD_BIN")
+	if binary == "" {
+		var err error
+		if binary, err = exec.LookPath("etcd"); err != nil {
+			t.Skip("etcd binary not found; set ETCD_BIN or add etcd to PATH")
+		}
+	}
+
+	clientURL := fmt.Sprintf("http://127.0.0.1:%d", freePort(t))
+	peerURL := fmt.Sprintf("http://127.0.0.1:%d", freePort(t))
+	cmd := exec.Command(binary,
+		"--data-dir", t.TempDir(),
+		"--listen-client-urls", clientURL,
+		"--advertise-client-urls", clientURL,
+		"--listen-peer-urls", peerURL,
+		"--initial-advertise-peer-urls", peerURL,
+		"--initial-cluster", "default="+peerURL)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start etcd: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		if resp, err := http.Get(clientURL + "/health"); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return clientURL
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for etcd to become healthy")
+	return ""
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func newTestEtcd(t *testing.T) *Etcd {
+	t.Helper()
+	endpoint := startEtcd(t)
+
+	gv := schema.GroupVersion{Group: "test.myorg.io", Version: "v1"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(gv, &metav1.PartialObjectMetadata{}, &metav1.PartialObjectMetadataList{})
+	metav1.AddToGroupVersion(scheme, gv)
+	codecs := serializer.NewCodecFactory(scheme)
+
+	resource := schema.GroupResource{Group: gv.Group, Resource: "things"}
+	config := storagebackend.NewDefaultConfig("/registry/"+gv.Group,
+		codecs.CodecForVersions(codecs.LegacyCodec(gv), codecs.UniversalDeserializer(), gv, gv))
+	config.Transport.ServerList = []string{endpoint}
+
+	etcd, err := NewEtcd(scheme, resource, "thing",
+		func() runtime.Object { return &metav1.PartialObjectMetadata{} },
+		func() runtime.Object { return &metav1.PartialObjectMetadataList{} },
+		storage.DefaultNamespaceScopedAttr,
+		generic.RESTOptions{
+			StorageConfig:  config.ForResource(resource),
+			Decorator:      generic.UndecoratedStorage,
+			ResourcePrefix: resource.Resource,
+		})
+	if err != nil {
+		t.Fatalf("Failed to create etcd storage: %v", err)
+	}
+	t.Cleanup(etcd.Destroy)
+	return etcd
+}
+
+func newThing(namespace, name string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "test.myorg.io/v1", Kind: "PartialObjectMetadata"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID("uid-" + name)},
+	}
+}
+
+func TestEtcd_CRUD(t *testing.T) {
+	etcd := newTestEtcd(t)
+	ctx := context.Background()
+
+	created := &metav1.PartialObjectMetadata{}
+	if err := etcd.Create(ctx, newThing("default", "a"), created); err != nil {
+		t.Fatalf("Failed to create object: %v", err)
+	}
+	if created.ResourceVersion == "" {
+		t.Error("ResourceVersion should be set")
+	}
+
+	err := etcd.Create(ctx, newThing("default", "a"), &metav1.PartialObjectMetadata{})
+	if !errors.IsAlreadyExists(err) {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+
+	if err := etcd.Create(ctx, newThing("team-a", "a"), &metav1.PartialObjectMetadata{}); err != nil {
+		t.Fatalf("Failed to create object in another namespace: %v", err)
+	}
+
+	retrieved := &metav1.PartialObjectMetadata{}
+	if err := etcd.Get(ctx, "default", "a", retrieved); err != nil {
+		t.Fatalf("Failed to get object: %v", err)
+	}
+	if retrieved.UID != "uid-a" {
+		t.Errorf("Expected UID 'uid-a', got '%s'", retrieved.UID)
+	}
+
+	list := &metav1.PartialObjectMetadataList{}
+	if err := etcd.List(ctx, "default", storage.Everything, list); err != nil {
+		t.Fatalf("Failed to list objects: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("Expected 1 object in 'default', got %d", len(list.Items))
+	}
+	if err := etcd.List(ctx, metav1.NamespaceAll, storage.Everything, list); err != nil {
+		t.Fatalf("Failed to list objects: %v", err)
+	}
+	if len(list.Items) != 2 {
+		t.Errorf("Expected 2 objects across namespaces, got %d", len(list.Items))
+	}
+
+	// Updates carry over the UID and reject stale resourceVersions
+	update := retrieved.DeepCopy()
+	update.UID = "changed"
+	update.Labels = map[string]string{"updated": "true"}
+	updated := &metav1.PartialObjectMetadata{}
+	if err := etcd.Update(ctx, update, updated); err != nil {
+		t.Fatalf("Failed to update object: %v", err)
+	}
+	if updated.UID != "uid-a" || updated.Labels["updated"] != "true" {
+		t.Errorf("Unexpected updated object: %+v", updated.ObjectMeta)
+	}
+	err = etcd.Update(ctx, retrieved, &metav1.PartialObjectMetadata{})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict for stale resourceVersion, got %v", err)
+	}
+
+	// Deletes honour preconditions
+	err = etcd.Delete(ctx, "default", "a", &metav1.Preconditions{ResourceVersion: &retrieved.ResourceVersion},
+		&metav1.PartialObjectMetadata{})
+	if !errors.IsConflict(err) {
+		t.Errorf("Expected conflict deleting with a stale resourceVersion, got %v", err)
+	}
+	if err := etcd.Delete(ctx, "default", "a", &metav1.Preconditions{ResourceVersion: &updated.ResourceVersion},
+		&metav1.PartialObjectMetadata{}); err != nil {
+		t.Fatalf("Failed to delete object: %v", err)
+	}
+	err = etcd.Get(ctx, "default", "a", &metav1.PartialObjectMetadata{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected NotFound after delete, got %v", err)
+	}
+	err = etcd.Delete(ctx, "default", "a", nil, &metav1.PartialObjectMetadata{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected NotFound deleting a missing object, got %v", err)
+	}
+}
+
+func TestEtcd_Watch(t *testing.T) {
+	etcd := newTestEtcd(t)
+	ctx := context.Background()
+
+	created := &metav1.PartialObjectMetadata{}
+	if err := etcd.Create(ctx, newThing("default", "a"), created); err != nil {
+		t.Fatalf("Failed to create object: %v", err)
+	}
+
+	w, err := etcd.Watch(ctx, "default", storage.Everything, &internalversion.ListOptions{ResourceVersion: created.ResourceVersion})
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer w.Stop()
+
+	// Events in other namespaces are filtered out
+	if err := etcd.Create(ctx, newThing("team-a", "b"), &metav1.PartialObjectMetadata{}); err != nil {
+		t.Fatalf("Failed to create object: %v", err)
+	}
+	if err := etcd.Delete(ctx, "default", "a", nil, &metav1.PartialObjectMetadata{}); err != nil {
+		t.Fatalf("Failed to delete object: %v", err)
+	}
+
+	select {
+	case event := <-w.ResultChan():
+		if event.Type != watch.Deleted || event.Object.(*metav1.PartialObjectMetadata).Name != "a" {
+			t.Errorf("Expected DELETED event for 'a', got %s %v", event.Type, event.Object)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for watch event")
+	}
+}
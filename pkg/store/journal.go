@@ -0,0 +1,406 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+)
+
+// FsyncPolicy controls when journal writes are flushed to disk
+type FsyncPolicy string
+
+const (
+	// FsyncAlways flushes every record before the write is acknowledged
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval flushes periodically; a crash may lose the most recent writes
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves flushing to the operating system
+	FsyncNever FsyncPolicy = "never"
+)
+
+// JournalOptions configures a Journal
+type JournalOptions struct {
+	FsyncPolicy   FsyncPolicy
+	FsyncInterval time.Duration
+
+	// CompactionThreshold is the number of log records after which the owner should compact
+	CompactionThreshold int
+}
+
+// Record is a single mutation in the write-ahead log
+type Record struct {
+	Revision int64           `json:"revision"`
+	Key      string          `json:"key"`
+	Deleted  bool            `json:"deleted,omitempty"`
+	Object   json.RawMessage `json:"object,omitempty"`
+}
+
+// RecoveredState is the state of a resource rebuilt from its journal
+type RecoveredState struct {
+	// Revision is the newest revision that was persisted
+	Revision int64
+	Objects  []runtime.Object
+}
+
+type snapshot struct {
+	Revision int64             `json:"revision"`
+	Objects  []json.RawMessage `json:"objects"`
+}
+
+// Journal persists the state of one resource in a directory as a snapshot plus an
+// append-only write-ahead log. Every record carries a checksum so a write torn by a
+// crash is detected and discarded on recovery; compaction replaces the snapshot
+// atomically and starts a new log.
+type Journal struct {
+	dir     string
+	codec   runtime.Codec
+	options JournalOptions
+
+	mu      sync.Mutex
+	wal     logFile
+	records int
+	// size is the length of the complete records in the log, which a failed append is cut back to
+	size int64
+	// failed is set once the log could not be cut back, after which nothing more is appended
+	failed  error
+	dirty   bool
+	stopCh  chan struct{}
+	stopped sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// logFile is the file the log is appended to
+type logFile interface {
+	WriteString(s string) (int, error)
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// OpenJournal recovers the state persisted in dir, creating the directory if needed,
+// and opens the log for appending. Objects are encoded with codec, which decodes every
+// version they may have been persisted in, as etcd storage encodes them.
+func OpenJournal(dir string, codec runtime.Codec, options JournalOptions) (*Journal, *RecoveredState, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, nil, err
+	}
+
+	objects, revision, err := readSnapshot(filepath.Join(dir, snapshotFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	walPath := filepath.Join(dir, walFile)
+	records, revision, err := replayLog(walPath, objects, revision)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state := &RecoveredState{Revision: revision}
+	for key, data := range objects {
+		obj, err := runtime.Decode(codec, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding %s from journal in %s: %w", key, dir, err)
+		}
+		state.Objects = append(state.Objects, obj)
+	}
+
+	wal, err := os.OpenFile(walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := wal.Stat()
+	if err != nil {
+		wal.Close()
+		return nil, nil, err
+	}
+
+	j := &Journal{
+		dir:     dir,
+		codec:   codec,
+		options: options,
+		wal:     wal,
+		records: records,
+		size:    info.Size(),
+		stopCh:  make(chan struct{}),
+	}
+	if options.FsyncPolicy == FsyncInterval && options.FsyncInterval > 0 {
+		j.stopped.Add(1)
+		go j.syncLoop()
+	}
+	return j, state, nil
+}
+
+// Append writes a record to the log for a stored object, or a deletion if obj is nil. A record
+// that fails to be written or flushed is cut from the log again, so records appended after it
+// are recovered; if that fails too, every later append fails.
+func (j *Journal) Append(revision int64, key string, obj runtime.Object) error {
+	record := Record{Revision: revision, Key: key, Deleted: obj == nil}
+	if obj != nil {
+		data, err := runtime.Encode(j.codec, obj)
+		if err != nil {
+			return err
+		}
+		record.Object = data
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.failed != nil {
+		return j.failed
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)
+	_, err = j.wal.WriteString(line)
+	if err == nil && j.options.FsyncPolicy == FsyncAlways {
+		err = j.wal.Sync()
+	}
+	if err != nil {
+		if truncateErr := j.wal.Truncate(j.size); truncateErr != nil {
+			j.failed = fmt.Errorf("journal in %s is unusable after failing to remove a partial record: %w",
+				j.dir, truncateErr)
+		}
+		return err
+	}
+	j.size += int64(len(line))
+	j.records++
+	if j.options.FsyncPolicy != FsyncAlways {
+		j.dirty = true
+	}
+	return nil
+}
+
+// NeedsCompaction reports whether the log has grown past the compaction threshold
+func (j *Journal) NeedsCompaction() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.options.CompactionThreshold > 0 && j.records >= j.options.CompactionThreshold
+}
+
+// Compact replaces the snapshot with the given state and truncates the log. Callers
+// must prevent concurrent appends so the state matches everything logged so far.
+func (j *Journal) Compact(revision int64, objects []runtime.Object) error {
+	snap := snapshot{Revision: revision, Objects: make([]json.RawMessage, 0, len(objects))}
+	for _, obj := range objects {
+		data, err := runtime.Encode(j.codec, obj)
+		if err != nil {
+			return err
+		}
+		snap.Objects = append(snap.Objects, data)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.records == 0 {
+		return nil
+	}
+	if err := writeFileAtomic(filepath.Join(j.dir, snapshotFile), data); err != nil {
+		return err
+	}
+
+	// Records up to revision are now covered by the snapshot; if we crash before the
+	// truncation below they are skipped on recovery.
+	if err := j.wal.Truncate(0); err != nil {
+		return err
+	}
+	j.size = 0
+	if err := j.wal.Sync(); err != nil {
+		return err
+	}
+	j.records = 0
+	j.dirty = false
+	return nil
+}
+
+// Close flushes and closes the log. Calling it again returns the result of the first call.
+func (j *Journal) Close() error {
+	j.closeOnce.Do(func() {
+		j.closeErr = j.close()
+	})
+	return j.closeErr
+}
+
+func (j *Journal) close() error {
+	close(j.stopCh)
+	j.stopped.Wait()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.wal.Sync(); err != nil {
+		j.wal.Close()
+		return err
+	}
+	return j.wal.Close()
+}
+
+func (j *Journal) syncLoop() {
+	defer j.stopped.Done()
+
+	ticker := time.NewTicker(j.options.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			if j.dirty {
+				if err := j.wal.Sync(); err != nil {
+					klog.Errorf("Failed to sync journal in %s: %v", j.dir, err)
+				} else {
+					j.dirty = false
+				}
+			}
+			j.mu.Unlock()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func readSnapshot(path string) (map[string]json.RawMessage, int64, error) {
+	objects := make(map[string]json.RawMessage)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return objects, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, 0, fmt.Errorf("reading snapshot %s: %w", path, err)
+	}
+	for _, raw := range snap.Objects {
+		var meta struct {
+			Metadata struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, 0, fmt.Errorf("reading snapshot %s: %w", path, err)
+		}
+		objects[Key(meta.Metadata.Namespace, meta.Metadata.Name)] = raw
+	}
+	return objects, snap.Revision, nil
+}
+
+// replayLog applies the log records newer than revision to objects. A corrupt or
+// incomplete record ends the log: it and anything after it are truncated away.
+func replayLog(path string, objects map[string]json.RawMessage, revision int64) (int, int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0o600)
+	if os.IsNotExist(err) {
+		return 0, revision, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var (
+		reader  = bufio.NewReader(f)
+		offset  int64
+		records int
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		record, ok := parseRecord(line)
+		if err != nil || !ok {
+			klog.Warningf("Discarding torn or corrupt journal tail in %s at offset %d", path, offset)
+			if err := f.Truncate(offset); err != nil {
+				return 0, 0, err
+			}
+			if err := f.Sync(); err != nil {
+				return 0, 0, err
+			}
+			break
+		}
+		offset += int64(len(line))
+		records++
+
+		if record.Revision <= revision {
+			continue
+		}
+		revision = record.Revision
+		if record.Deleted {
+			delete(objects, record.Key)
+		} else {
+			objects[record.Key] = record.Object
+		}
+	}
+	return records, revision, nil
+}
+
+func parseRecord(line []byte) (Record, bool) {
+	var record Record
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	checksum, data, found := bytes.Cut(line, []byte(" "))
+	if !found || fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)) != string(checksum) {
+		return record, false
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, false
+	}
+	return record, true
+}
+
+// writeFileAtomic replaces path with data so that either the old or the new content
+// survives a crash
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,248 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+// testCodec encodes the objects of the journal tests as test/v1 PartialObjectMetadata
+var testCodec = func() runtime.Codec {
+	gv := schema.GroupVersion{Group: "test", Version: "v1"}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(gv, &metav1.PartialObjectMetadata{})
+	codecs := serializer.NewCodecFactory(scheme)
+	return codecs.CodecForVersions(codecs.LegacyCodec(gv), codecs.UniversalDeserializer(), gv, gv)
+}()
+
+func openTestJournal(t *testing.T, dir string, options JournalOptions) (*Journal, *RecoveredState) {
+	t.Helper()
+	j, state, err := OpenJournal(dir, testCodec, options)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	return j, state
+}
+
+func recoveredNames(state *RecoveredState) map[string]string {
+	names := make(map[string]string)
+	for _, obj := range state.Objects {
+		meta := obj.(*metav1.PartialObjectMetadata)
+		names[meta.Name] = meta.ResourceVersion
+	}
+	return names
+}
+
+func TestJournal_Recover(t *testing.T) {
+	dir := t.TempDir()
+	j, state := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	if state.Revision != 0 || len(state.Objects) != 0 {
+		t.Fatalf("Expected empty state, got %+v", state)
+	}
+
+	for _, record := range []struct {
+		revision int64
+		key      string
+		obj      runtime.Object
+	}{
+		{1, "default/a", newTestObject("a", 1)},
+		{2, "default/b", newTestObject("b", 2)},
+		{3, "default/a", newTestObject("a", 3)},
+		{4, "default/b", nil},
+	} {
+		if err := j.Append(record.revision, record.key, record.obj); err != nil {
+			t.Fatalf("Failed to append record: %v", err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Failed to close journal: %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Expected closing the journal again to succeed: %v", err)
+	}
+
+	j, state = openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	defer j.Close()
+
+	if state.Revision != 4 {
+		t.Errorf("Expected revision 4 including the deletion, got %d", state.Revision)
+	}
+	names := recoveredNames(state)
+	if len(names) != 1 || names["a"] != "3" {
+		t.Errorf("Expected only 'a' at resourceVersion 3, got %v", names)
+	}
+}
+
+func TestJournal_TornTail(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	if err := j.Append(1, "default/a", newTestObject("a", 1)); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Failed to close journal: %v", err)
+	}
+
+	// Simulate a crash in the middle of writing the second record
+	f, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	if _, err := f.WriteString(`0badc0de {"revision":2,"key":"default/b","obj`); err != nil {
+		t.Fatalf("Failed to write torn record: %v", err)
+	}
+	f.Close()
+
+	j, state := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	if state.Revision != 1 || len(state.Objects) != 1 {
+		t.Fatalf("Expected only the complete record to be recovered, got revision %d with %d objects",
+			state.Revision, len(state.Objects))
+	}
+
+	// The torn tail is discarded so new records follow the last complete one
+	if err := j.Append(2, "default/c", newTestObject("c", 2)); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	j.Close()
+
+	j, state = openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	defer j.Close()
+	if names := recoveredNames(state); len(names) != 2 || names["c"] != "2" {
+		t.Errorf("Expected 'a' and 'c' after recovery, got %v", names)
+	}
+}
+
+// tornLogFile writes only part of the next record it is given, as when the disk fills up
+type tornLogFile struct {
+	logFile
+	tear bool
+}
+
+func (f *tornLogFile) WriteString(s string) (int, error) {
+	if !f.tear {
+		return f.logFile.WriteString(s)
+	}
+	f.tear = false
+	n, _ := f.logFile.WriteString(s[:len(s)/2])
+	return n, fmt.Errorf("no space left on device")
+}
+
+func TestJournal_FailedAppend(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	if err := j.Append(1, "default/a", newTestObject("a", 1)); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	j.wal = &tornLogFile{logFile: j.wal, tear: true}
+	if err := j.Append(2, "default/b", newTestObject("b", 2)); err == nil {
+		t.Fatal("Expected the torn append to fail")
+	}
+
+	// The partial record is cut from the log, so the records appended after it are recovered
+	if err := j.Append(3, "default/c", newTestObject("c", 3)); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("Failed to close journal: %v", err)
+	}
+
+	j, state := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	defer j.Close()
+	if names := recoveredNames(state); state.Revision != 3 || len(names) != 2 || names["a"] != "1" || names["c"] != "3" {
+		t.Errorf("Expected 'a' and 'c' at revision 3 after recovery, got %v at revision %d", names, state.Revision)
+	}
+}
+
+func TestJournal_Compact(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncNever, CompactionThreshold: 2})
+
+	if err := j.Append(1, "default/a", newTestObject("a", 1)); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	if j.NeedsCompaction() {
+		t.Error("Did not expect compaction below the threshold")
+	}
+	if err := j.Append(2, "default/b", nil); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	if !j.NeedsCompaction() {
+		t.Error("Expected compaction at the threshold")
+	}
+
+	if err := j.Compact(2, []runtime.Object{newTestObject("a", 1)}); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if j.NeedsCompaction() {
+		t.Error("Expected the log to be empty after compaction")
+	}
+	if info, err := os.Stat(filepath.Join(dir, walFile)); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty log after compaction, got %v, %v", info, err)
+	}
+
+	if err := j.Append(3, "default/c", newTestObject("c", 3)); err != nil {
+		t.Fatalf("Failed to append record: %v", err)
+	}
+	j.Close()
+
+	j, state := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncNever})
+	defer j.Close()
+	if state.Revision != 3 {
+		t.Errorf("Expected revision 3, got %d", state.Revision)
+	}
+	if names := recoveredNames(state); len(names) != 2 || names["a"] != "1" || names["c"] != "3" {
+		t.Errorf("Expected 'a' from the snapshot and 'c' from the log, got %v", names)
+	}
+}
+
+func TestJournal_CrashDuringCompaction(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	for i, name := range []string{"a", "b"} {
+		revision := int64(i + 1)
+		if err := j.Append(revision, "default/"+name, newTestObject(name, revision)); err != nil {
+			t.Fatalf("Failed to append record: %v", err)
+		}
+	}
+	j.Close()
+
+	// The snapshot covering revision 2 was written but the log was never truncated;
+	// 'b' was deleted after the snapshot state was taken
+	if err := writeFileAtomic(filepath.Join(dir, snapshotFile),
+		[]byte(`{"revision":2,"objects":[{"apiVersion":"test/v1","kind":"PartialObjectMetadata","metadata":{"name":"a","namespace":"default","resourceVersion":"1"}}]}`)); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	j, state := openTestJournal(t, dir, JournalOptions{FsyncPolicy: FsyncAlways})
+	defer j.Close()
+	if state.Revision != 2 {
+		t.Errorf("Expected revision 2, got %d", state.Revision)
+	}
+	if names := recoveredNames(state); len(names) != 1 || names["a"] != "1" {
+		t.Errorf("Expected log records covered by the snapshot to be skipped, got %v", names)
+	}
+}
+
+func TestFileOptions_Validate(t *testing.T) {
+	o := NewFileOptions()
+	if errs := o.Validate(); len(errs) != 0 {
+		t.Errorf("Expected defaults to be valid, got %v", errs)
+	}
+
+	o.FsyncPolicy = "sometimes"
+	if errs := o.Validate(); len(errs) != 1 {
+		t.Errorf("Expected an error for an unknown fsync policy, got %v", errs)
+	}
+
+	o.FsyncPolicy = string(FsyncInterval)
+	o.FsyncInterval = 0
+	if errs := o.Validate(); len(errs) != 1 {
+		t.Errorf("Expected an error for a zero fsync interval, got %v", errs)
+	}
+}
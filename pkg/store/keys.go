@@ -0,0 +1,25 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Key returns the storage key of a namespaced object
+func Key(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// ParseResourceVersion parses a resourceVersion sent by a client. An empty string parses as 0.
+func ParseResourceVersion(resourceVersion string) (int64, error) {
+	if resourceVersion == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(resourceVersion, 10, 64)
+	if err != nil || version < 0 {
+		return 0, errors.NewBadRequest(fmt.Sprintf("invalid resource version %q", resourceVersion))
+	}
+	return version, nil
+}
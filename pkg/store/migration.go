@@ -0,0 +1,82 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// MigrationState records in a file how far a storage version migration got, so that an
+// interrupted migration resumes where it stopped
+type MigrationState struct {
+	// StorageVersion is the version objects are migrated to
+	StorageVersion string `json:"storageVersion"`
+	// Resources is the progress of each resource, by resource name
+	Resources map[string]*ResourceMigration `json:"resources"`
+
+	path string
+}
+
+// ResourceMigration is the progress of migrating one resource, whose objects are rewritten in
+// key order
+type ResourceMigration struct {
+	// LastKey is the key of the last object rewritten
+	LastKey string `json:"lastKey,omitempty"`
+	// Migrated counts the objects rewritten so far
+	Migrated int64 `json:"migrated"`
+	// Complete is set once every object has been rewritten
+	Complete bool `json:"complete,omitempty"`
+}
+
+// LoadMigrationState reads the state of a migration to storageVersion from path. Without a
+// state file, or with one left by a migration to another version, the migration starts over.
+func LoadMigrationState(path, storageVersion string) (*MigrationState, error) {
+	state := &MigrationState{
+		StorageVersion: storageVersion,
+		Resources:      make(map[string]*ResourceMigration),
+		path:           path,
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved MigrationState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("reading migration state %s: %w", path, err)
+	}
+	if saved.StorageVersion == storageVersion && saved.Resources != nil {
+		state.Resources = saved.Resources
+	}
+	return state, nil
+}
+
+// Resource returns the progress of migrating resource
+func (s *MigrationState) Resource(resource string) *ResourceMigration {
+	progress, ok := s.Resources[resource]
+	if !ok {
+		progress = &ResourceMigration{}
+		s.Resources[resource] = progress
+	}
+	return progress
+}
+
+// Save replaces the state file with the current progress
+func (s *MigrationState) Save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// Remove deletes the state file, so that the next migration starts over
+func (s *MigrationState) Remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
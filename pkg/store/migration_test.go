@@ -0,0 +1,66 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationState_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migration.json")
+	state, err := LoadMigrationState(path, "test/v2")
+	if err != nil {
+		t.Fatalf("Failed to load migration state: %v", err)
+	}
+	if progress := state.Resource("things"); progress.LastKey != "" || progress.Migrated != 0 || progress.Complete {
+		t.Fatalf("Expected a new migration, got %+v", progress)
+	}
+
+	progress := state.Resource("things")
+	progress.LastKey = "default/b"
+	progress.Migrated = 2
+	state.Resource("others").Complete = true
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save migration state: %v", err)
+	}
+
+	state, err = LoadMigrationState(path, "test/v2")
+	if err != nil {
+		t.Fatalf("Failed to reload migration state: %v", err)
+	}
+	if progress := state.Resource("things"); progress.LastKey != "default/b" || progress.Migrated != 2 || progress.Complete {
+		t.Errorf("Expected to resume after default/b, got %+v", progress)
+	}
+	if !state.Resource("others").Complete {
+		t.Error("Expected others to stay complete")
+	}
+
+	// A migration to another version starts over
+	state, err = LoadMigrationState(path, "test/v3")
+	if err != nil {
+		t.Fatalf("Failed to load migration state: %v", err)
+	}
+	if progress := state.Resource("things"); progress.LastKey != "" || progress.Migrated != 0 {
+		t.Errorf("Expected a migration to another version to start over, got %+v", progress)
+	}
+
+	if err := state.Remove(); err != nil {
+		t.Fatalf("Failed to remove migration state: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the state file to be removed, got %v", err)
+	}
+	if err := state.Remove(); err != nil {
+		t.Errorf("Expected removing a missing state file to succeed, got %v", err)
+	}
+}
+
+func TestMigrationState_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migration.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	if _, err := LoadMigrationState(path, "test/v2"); err == nil {
+		t.Error("Expected a corrupt state file to be reported")
+	}
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// FileOptions configures the file-backed storage mode
+type FileOptions struct {
+	// Dir is the data directory; file-backed storage is disabled when it is empty
+	Dir string
+
+	FsyncPolicy         string
+	FsyncInterval       time.Duration
+	CompactionInterval  time.Duration
+	CompactionThreshold int
+}
+
+func NewFileOptions() *FileOptions {
+	return &FileOptions{
+		FsyncPolicy:         string(FsyncAlways),
+		FsyncInterval:       time.Second,
+		CompactionInterval:  10 * time.Minute,
+		CompactionThreshold: 10000,
+	}
+}
+
+func (o *FileOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Dir, "storage-dir", o.Dir,
+		"Directory to persist objects in when etcd is not configured. If empty, objects are kept in memory only.")
+	fs.StringVar(&o.FsyncPolicy, "storage-fsync", o.FsyncPolicy,
+		"When to flush the write-ahead log to disk: 'always' before acknowledging each write, "+
+			"'interval' every --storage-fsync-interval, or 'never' to leave it to the operating system.")
+	fs.DurationVar(&o.FsyncInterval, "storage-fsync-interval", o.FsyncInterval,
+		"How often the write-ahead log is flushed with --storage-fsync=interval.")
+	fs.DurationVar(&o.CompactionInterval, "storage-compaction-interval", o.CompactionInterval,
+		"How often the write-ahead log is compacted into a snapshot. Zero disables periodic compaction.")
+	fs.IntVar(&o.CompactionThreshold, "storage-compaction-threshold", o.CompactionThreshold,
+		"Number of write-ahead log records that triggers a compaction. Zero disables it.")
+}
+
+func (o *FileOptions) Validate() []error {
+	var errs []error
+	switch FsyncPolicy(o.FsyncPolicy) {
+	case FsyncAlways, FsyncNever:
+	case FsyncInterval:
+		if o.FsyncInterval <= 0 {
+			errs = append(errs, fmt.Errorf("--storage-fsync-interval must be positive with --storage-fsync=interval"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("--storage-fsync must be one of 'always', 'interval' or 'never', got %q", o.FsyncPolicy))
+	}
+	if o.CompactionInterval < 0 {
+		errs = append(errs, fmt.Errorf("--storage-compaction-interval must not be negative"))
+	}
+	if o.CompactionThreshold < 0 {
+		errs = append(errs, fmt.Errorf("--storage-compaction-threshold must not be negative"))
+	}
+	return errs
+}
+
+// JournalOptions returns the options for the journals of the individual resources
+func (o *FileOptions) JournalOptions() JournalOptions {
+	return JournalOptions{
+		FsyncPolicy:         FsyncPolicy(o.FsyncPolicy),
+		FsyncInterval:       o.FsyncInterval,
+		CompactionThreshold: o.CompactionThreshold,
+	}
+}
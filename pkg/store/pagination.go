@@ -0,0 +1,211 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+const (
+	// DefaultSnapshotTTL is how long the state a paginated list started from stays
+	// available to its continue tokens, matching etcd's default compaction interval
+	DefaultSnapshotTTL = 5 * time.Minute
+
+	// DefaultMaxSnapshots is the number of list snapshots retained at once
+	DefaultMaxSnapshots = 16
+
+	expiredContinueMessage = "The provided continue parameter is too old to display a consistent list result. " +
+		"You can start a new list without the continue parameter, or use the continue token in this response " +
+		"to retrieve the remainder of the results. Continuing with the provided token results in an inconsistent " +
+		"list - objects that were created, modified, or deleted between the time the first chunk was returned " +
+		"and now may show up in the list."
+)
+
+// Page is one page of a list
+type Page struct {
+	// Objects are shared with the storage and must be copied before they are handed out
+	Objects            []runtime.Object
+	Revision           int64
+	Continue           string
+	RemainingItemCount *int64
+}
+
+type keyedObject struct {
+	key string
+	obj runtime.Object
+}
+
+type listSnapshot struct {
+	objects []keyedObject
+	created time.Time
+}
+
+// Snapshots paginates lists of one resource the way etcd does: objects are returned in key
+// order and continue tokens are bound to the revision the first page was read at. The objects
+// stored at that revision are retained for a while so later pages show the same state; once
+// they are gone, continuing fails with 410 Expired. Stored objects must never be modified in
+// place, since snapshots share them.
+type Snapshots struct {
+	ttl time.Duration
+	max int
+	now func() time.Time
+
+	mu        sync.Mutex
+	snapshots map[int64]*listSnapshot
+}
+
+func NewSnapshots() *Snapshots {
+	return &Snapshots{
+		ttl:       DefaultSnapshotTTL,
+		max:       DefaultMaxSnapshots,
+		now:       time.Now,
+		snapshots: make(map[int64]*listSnapshot),
+	}
+}
+
+// Paginate returns the page of objects in namespace, or in all namespaces if namespace is
+// empty, selected by predicate including its limit and continue token. current returns the
+// objects stored at revision; callers must hold the lock that keeps them from changing.
+func (s *Snapshots) Paginate(namespace string, predicate storage.SelectionPredicate, revision int64,
+	current func() []runtime.Object) (*Page, error) {
+	keyPrefix := "/"
+	if namespace != "" {
+		keyPrefix += namespace + "/"
+	}
+
+	var (
+		objects  []keyedObject
+		startKey string
+		retained bool
+	)
+	if predicate.Continue != "" {
+		key, continueRevision, err := storage.DecodeContinue(predicate.Continue, keyPrefix)
+		if err != nil {
+			return nil, errors.NewBadRequest(fmt.Sprintf("invalid continue token: %v", err))
+		}
+		startKey = key
+
+		// A negative revision continues from the current state, as offered by an expired token
+		if continueRevision > 0 {
+			if objects, retained = s.get(continueRevision); !retained {
+				return nil, expiredContinueError(key, keyPrefix)
+			}
+			revision = continueRevision
+		}
+	}
+	if !retained {
+		var err error
+		if objects, err = sortByKey(current()); err != nil {
+			return nil, errors.NewInternalError(err)
+		}
+	}
+
+	page := &Page{Revision: revision}
+	var (
+		lastKey   string
+		remaining int64
+	)
+	for _, object := range objects {
+		if object.key < startKey || !strings.HasPrefix(object.key, keyPrefix) {
+			continue
+		}
+		if matches, _ := predicate.Matches(object.obj); !matches {
+			continue
+		}
+		if predicate.Limit > 0 && int64(len(page.Objects)) == predicate.Limit {
+			remaining++
+			continue
+		}
+		page.Objects = append(page.Objects, object.obj)
+		lastKey = object.key
+	}
+
+	if remaining > 0 {
+		token, err := storage.EncodeContinue(lastKey+"\x00", keyPrefix, revision)
+		if err != nil {
+			return nil, errors.NewInternalError(err)
+		}
+		page.Continue = token
+
+		// Counts are only exact without selectors, as in etcd
+		if predicate.Empty() {
+			page.RemainingItemCount = &remaining
+		}
+		if !retained {
+			s.add(revision, objects)
+		}
+	}
+	return page, nil
+}
+
+func (s *Snapshots) add(revision int64, objects []keyedObject) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire()
+	if _, ok := s.snapshots[revision]; ok {
+		return
+	}
+	if len(s.snapshots) >= s.max {
+		oldest := int64(-1)
+		for r := range s.snapshots {
+			if oldest < 0 || r < oldest {
+				oldest = r
+			}
+		}
+		delete(s.snapshots, oldest)
+	}
+	s.snapshots[revision] = &listSnapshot{objects: objects, created: s.now()}
+}
+
+func (s *Snapshots) get(revision int64) ([]keyedObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire()
+	snap, ok := s.snapshots[revision]
+	if !ok {
+		return nil, false
+	}
+	return snap.objects, true
+}
+
+func (s *Snapshots) expire() {
+	for revision, snap := range s.snapshots {
+		if s.now().Sub(snap.created) > s.ttl {
+			delete(s.snapshots, revision)
+		}
+	}
+}
+
+func sortByKey(objs []runtime.Object) ([]keyedObject, error) {
+	objects := make([]keyedObject, 0, len(objs))
+	for _, obj := range objs {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, keyedObject{key: "/" + Key(accessor.GetNamespace(), accessor.GetName()), obj: obj})
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].key < objects[j].key
+	})
+	return objects, nil
+}
+
+// expiredContinueError is the 410 for a continue token whose snapshot is gone. It carries a
+// token that continues from the same key in the current state.
+func expiredContinueError(key, keyPrefix string) error {
+	err := errors.NewResourceExpired(expiredContinueMessage)
+	if token, encodeErr := storage.EncodeContinue(key, keyPrefix, -1); encodeErr == nil {
+		err.ErrStatus.ListMeta.Continue = token
+	}
+	return err
+}
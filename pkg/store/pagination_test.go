@@ -0,0 +1,164 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+func newPaginationObjects(namespace string, names ...string) []runtime.Object {
+	var objects []runtime.Object
+	for _, name := range names {
+		objects = append(objects, &metav1.PartialObjectMetadata{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"name": name}},
+		})
+	}
+	return objects
+}
+
+func pageNames(page *Page) []string {
+	var names []string
+	for _, obj := range page.Objects {
+		names = append(names, obj.(*metav1.PartialObjectMetadata).Name)
+	}
+	return names
+}
+
+func testPredicate(limit int64, continueToken string) storage.SelectionPredicate {
+	return storage.SelectionPredicate{
+		Label:    labels.Everything(),
+		Field:    fields.Everything(),
+		Limit:    limit,
+		Continue: continueToken,
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}
+}
+
+func TestSnapshots_Paginate(t *testing.T) {
+	s := NewSnapshots()
+	objects := append(newPaginationObjects("default", "e", "c", "a", "d", "b"), newPaginationObjects("other", "a")...)
+	current := func() []runtime.Object { return objects }
+
+	page, err := s.Paginate("default", testPredicate(2, ""), 7, current)
+	if err != nil {
+		t.Fatalf("Failed to paginate: %v", err)
+	}
+	if names := pageNames(page); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Expected first page [a b], got %v", names)
+	}
+	if page.Continue == "" || page.RemainingItemCount == nil || *page.RemainingItemCount != 3 {
+		t.Fatalf("Expected a continue token and 3 remaining items, got %q and %v", page.Continue, page.RemainingItemCount)
+	}
+
+	// Later pages read the snapshot even though the objects changed
+	objects = newPaginationObjects("default", "a", "z")
+	var names []string
+	for token := page.Continue; token != ""; token = page.Continue {
+		if page, err = s.Paginate("default", testPredicate(2, token), 9, current); err != nil {
+			t.Fatalf("Failed to paginate: %v", err)
+		}
+		if page.Revision != 7 {
+			t.Errorf("Expected pages at revision 7, got %d", page.Revision)
+		}
+		names = append(names, pageNames(page)...)
+	}
+	if !reflect.DeepEqual(names, []string{"c", "d", "e"}) {
+		t.Errorf("Expected remaining pages [c d e], got %v", names)
+	}
+	if page.RemainingItemCount != nil {
+		t.Errorf("Expected no remaining item count on the last page, got %d", *page.RemainingItemCount)
+	}
+}
+
+func TestSnapshots_PaginateWithSelector(t *testing.T) {
+	s := NewSnapshots()
+	objects := newPaginationObjects("default", "a", "b", "c", "d")
+	current := func() []runtime.Object { return objects }
+
+	predicate := testPredicate(1, "")
+	predicate.Label = labels.SelectorFromSet(labels.Set{"name": "c"})
+	page, err := s.Paginate("", predicate, 1, current)
+	if err != nil {
+		t.Fatalf("Failed to paginate: %v", err)
+	}
+	if names := pageNames(page); !reflect.DeepEqual(names, []string{"c"}) || page.Continue != "" {
+		t.Errorf("Expected a single full page with [c], got %v and %q", names, page.Continue)
+	}
+
+	predicate.Label = labels.Everything()
+	predicate.Field = fields.OneTermNotEqualSelector("metadata.name", "a")
+	if page, err = s.Paginate("", predicate, 1, current); err != nil {
+		t.Fatalf("Failed to paginate: %v", err)
+	}
+	if page.Continue == "" || page.RemainingItemCount != nil {
+		t.Errorf("Expected a continue token without remaining item count, got %q and %v", page.Continue, page.RemainingItemCount)
+	}
+}
+
+func TestSnapshots_ExpiredContinue(t *testing.T) {
+	s := NewSnapshots()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	objects := newPaginationObjects("default", "a", "b", "c")
+	current := func() []runtime.Object { return objects }
+
+	page, err := s.Paginate("default", testPredicate(1, ""), 3, current)
+	if err != nil {
+		t.Fatalf("Failed to paginate: %v", err)
+	}
+
+	now = now.Add(DefaultSnapshotTTL + time.Second)
+	_, err = s.Paginate("default", testPredicate(1, page.Continue), 4, current)
+	if !errors.IsResourceExpired(err) {
+		t.Fatalf("Expected 410 Expired for a stale continue token, got %v", err)
+	}
+
+	// The error offers a token continuing inconsistently from the current state
+	token := err.(*errors.StatusError).ErrStatus.ListMeta.Continue
+	if token == "" {
+		t.Fatal("Expected the expired error to carry a continue token")
+	}
+	if page, err = s.Paginate("default", testPredicate(5, token), 4, current); err != nil {
+		t.Fatalf("Failed to continue inconsistently: %v", err)
+	}
+	if names := pageNames(page); !reflect.DeepEqual(names, []string{"b", "c"}) || page.Revision != 4 {
+		t.Errorf("Expected [b c] at revision 4, got %v at %d", names, page.Revision)
+	}
+
+	_, err = s.Paginate("default", testPredicate(1, "not-a-token"), 4, current)
+	if !errors.IsBadRequest(err) {
+		t.Errorf("Expected BadRequest for an invalid continue token, got %v", err)
+	}
+}
+
+func TestSnapshots_MaxSnapshots(t *testing.T) {
+	s := NewSnapshots()
+	objects := newPaginationObjects("default", "a", "b")
+	current := func() []runtime.Object { return objects }
+
+	var tokens []string
+	for revision := int64(1); revision <= DefaultMaxSnapshots+1; revision++ {
+		page, err := s.Paginate("default", testPredicate(1, ""), revision, current)
+		if err != nil {
+			t.Fatalf("Failed to paginate: %v", err)
+		}
+		tokens = append(tokens, page.Continue)
+	}
+
+	if _, err := s.Paginate("default", testPredicate(1, tokens[0]), 100, current); !errors.IsResourceExpired(err) {
+		t.Errorf("Expected the oldest snapshot to be evicted, got %v", err)
+	}
+	if _, err := s.Paginate("default", testPredicate(1, tokens[len(tokens)-1]), 100, current); err != nil {
+		t.Errorf("Expected the newest snapshot to be retained: %v", err)
+	}
+	if len(s.snapshots) != DefaultMaxSnapshots {
+		t.Errorf("Expected %d snapshots, got %d", DefaultMaxSnapshots, len(s.snapshots))
+	}
+}
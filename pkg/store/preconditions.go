@@ -0,0 +1,38 @@
+package store
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// OptimisticLockErrorMsg is the message of the conflict returned when a write is based on a stale resourceVersion
+const OptimisticLockErrorMsg = "the object has been modified; please apply your changes to the latest version and try again"
+
+// errOptimisticLock is the cause of the conflicts NewOptimisticLockError returns
+var errOptimisticLock = stderrors.New(OptimisticLockErrorMsg)
+
+// NewOptimisticLockError returns the conflict reported for a stale resourceVersion
+func NewOptimisticLockError(resource schema.GroupResource, name string) error {
+	return errors.NewConflict(resource, name, errOptimisticLock)
+}
+
+// CheckPreconditions verifies the UID and resourceVersion preconditions against the stored object
+func CheckPreconditions(resource schema.GroupResource, preconditions *metav1.Preconditions, obj metav1.Object) error {
+	if preconditions == nil {
+		return nil
+	}
+	if preconditions.UID != nil && *preconditions.UID != obj.GetUID() {
+		return errors.NewConflict(resource, obj.GetName(), fmt.Errorf(
+			"Precondition failed: UID in precondition: %v, UID in object meta: %v", *preconditions.UID, obj.GetUID()))
+	}
+	if preconditions.ResourceVersion != nil && *preconditions.ResourceVersion != obj.GetResourceVersion() {
+		return errors.NewConflict(resource, obj.GetName(), fmt.Errorf(
+			"Precondition failed: ResourceVersion in precondition: %v, ResourceVersion in object meta: %v",
+			*preconditions.ResourceVersion, obj.GetResourceVersion()))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// causes returns the fields and messages of the causes of a Status
+func causes(obj map[string]interface{}) []string {
+	var result []string
+	details, _ := obj["details"].(map[string]interface{})
+	entries, _ := details["causes"].([]interface{})
+	for _, entry := range entries {
+		cause := entry.(map[string]interface{})
+		result = append(result, cause["field"].(string)+": "+cause["message"].(string))
+	}
+	return result
+}
+
+func TestValidationRules_Gadget(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Rules are declared in the v1beta1 schema and enforced for every version
+	for path, version := range map[string]string{
+		v1alpha1GadgetsPath: `"1.0.0"`,
+		v1beta1GadgetsPath:  `{"major":1,"minor":0,"patch":0}`,
+	} {
+		code, obj := doRequest(t, ts, http.MethodPost, path, "application/json",
+			`{"metadata":{"name":"disabled"},"spec":{"type":"sensor","version":`+version+`,"enabled":false,"priority":5}}`)
+		if code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected a disabled gadget with a priority to be rejected through %s, got %d: %v", path, code, obj)
+		}
+		if c := causes(obj); len(c) != 1 || !strings.HasPrefix(c[0], "spec.priority: ") ||
+			!strings.Contains(c[0], "must be 0 when enabled is false") {
+			t.Errorf("Expected the rule to be reported for spec.priority, got %v", c)
+		}
+	}
+
+	code, obj := doRequest(t, ts, http.MethodPost, v1beta1GadgetsPath, "application/json",
+		`{"metadata":{"name":"g"},"spec":{"type":"sensor","version":{"major":1,"minor":0,"patch":0},"enabled":true,"priority":5}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected an enabled gadget with a priority to be created, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, v1beta1GadgetsPath+"/g", "application/merge-patch+json",
+		`{"spec":{"enabled":false}}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected disabling a gadget with a priority to be rejected, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, v1beta1GadgetsPath+"/g", "application/merge-patch+json",
+		`{"spec":{"enabled":false,"priority":0}}`)
+	if code != http.StatusOK {
+		t.Errorf("Expected disabling a gadget and resetting its priority to succeed, got %d: %v", code, obj)
+	}
+}
+
+func TestValidationRules_WidgetTransition(t *testing.T) {
+	ts := newTestServer(t)
+	path := v1beta1WidgetsPath + "/w"
+
+	code, obj := doRequest(t, ts, http.MethodPost, v1beta1WidgetsPath, "application/json",
+		`{"metadata":{"name":"w"},"spec":{"name":"w","size":"1500m"}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected the widget to be created, got %d: %v", code, obj)
+	}
+
+	code, obj = doRequest(t, ts, http.MethodPatch, path, "application/merge-patch+json", `{"spec":{"size":"2"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected the widget to grow, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, path, "application/merge-patch+json", `{"spec":{"size":"1999m"}}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected the widget not to shrink, got %d: %v", code, obj)
+	}
+	if c := causes(obj); len(c) != 1 || c[0] != `spec.size: Invalid value: "1999m": cannot shrink` {
+		t.Errorf("Expected the transition rule to be reported for spec.size, got %v", c)
+	}
+
+	// Server-side apply and dry runs are validated the same way
+	code, obj = apply(t, ts, path, "fieldManager=test&dryRun=All&force=true",
+		`{"apiVersion":"things.myorg.io/v1beta1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"w","size":"1"}}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected applying a smaller size to be rejected, got %d: %v", code, obj)
+	}
+
+	// Updates that leave the size alone pass, as do status updates
+	code, obj = doRequest(t, ts, http.MethodPatch, path, "application/merge-patch+json", `{"spec":{"description":"same size"}}`)
+	if code != http.StatusOK {
+		t.Errorf("Expected an update keeping the size to succeed, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodPatch, path+"/status", "application/merge-patch+json", `{"status":{"phase":"Ready"}}`)
+	if code != http.StatusOK {
+		t.Errorf("Expected a status update to succeed, got %d: %v", code, obj)
+	}
+}
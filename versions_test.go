@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"example.com/mytest-apiserver/pkg/apis/gadgets"
+	"example.com/mytest-apiserver/pkg/apis/widgets"
+	v1alpha1widgets "example.com/mytest-apiserver/pkg/apis/widgets/v1alpha1"
+	v1beta1widgets "example.com/mytest-apiserver/pkg/apis/widgets/v1beta1"
+	"example.com/mytest-apiserver/pkg/store"
+)
+
+const (
+	v1beta1WidgetsPath  = "/apis/things.myorg.io/v1beta1/namespaces/default/widgets"
+	v1alpha1GadgetsPath = "/apis/things.myorg.io/v1alpha1/namespaces/default/gadgets"
+	v1beta1GadgetsPath  = "/apis/things.myorg.io/v1beta1/namespaces/default/gadgets"
+)
+
+func TestServeVersions_Widget(t *testing.T) {
+	ts := newTestServer(t)
+
+	code, obj := doRequest(t, ts, http.MethodPost, v1beta1WidgetsPath, "application/json",
+		`{"apiVersion":"things.myorg.io/v1beta1","kind":"Widget","metadata":{"name":"w"},"spec":{"name":"w","size":"1500m"}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected the widget to be created through v1beta1, got %d: %v", code, obj)
+	}
+
+	// v1alpha1 rounds the size up and keeps the exact one in an annotation
+	code, obj = doRequest(t, ts, http.MethodGet, widgetsPath+"/w", "", "")
+	if code != http.StatusOK || obj["apiVersion"] != "things.myorg.io/v1alpha1" {
+		t.Fatalf("Expected the widget to be served as v1alpha1, got %d: %v", code, obj)
+	}
+	metadata := obj["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if size := obj["spec"].(map[string]interface{})["size"]; size != float64(2) || annotations[v1alpha1widgets.SizeAnnotation] != "1500m" {
+		t.Fatalf("Expected size 2 annotated with 1500m, got %v and %v", size, annotations)
+	}
+
+	// An update through v1alpha1 that leaves the size alone keeps the exact size
+	obj["spec"].(map[string]interface{})["description"] = "updated through v1alpha1"
+	body, _ := json.Marshal(obj)
+	if code, obj = doRequest(t, ts, http.MethodPut, widgetsPath+"/w", "application/json", string(body)); code != http.StatusOK {
+		t.Fatalf("Expected the widget to be updated through v1alpha1, got %d: %v", code, obj)
+	}
+	code, obj = doRequest(t, ts, http.MethodGet, v1beta1WidgetsPath+"/w", "", "")
+	if code != http.StatusOK || obj["apiVersion"] != "things.myorg.io/v1beta1" {
+		t.Fatalf("Expected the widget to be served as v1beta1, got %d: %v", code, obj)
+	}
+	spec := obj["spec"].(map[string]interface{})
+	if spec["size"] != "1500m" || spec["description"] != "updated through v1alpha1" {
+		t.Errorf("Expected size 1500m to survive the v1alpha1 update, got %v", spec)
+	}
+	if annotations, ok := obj["metadata"].(map[string]interface{})["annotations"]; ok {
+		t.Errorf("Expected the size annotation not to be stored, got %v", annotations)
+	}
+
+	// Both versions select by the same size
+	code, obj = doRequest(t, ts, http.MethodGet, widgetsPath+"?fieldSelector=spec.size%3D1500m", "", "")
+	if items, _ := obj["items"].([]interface{}); code != http.StatusOK || len(items) != 1 {
+		t.Errorf("Expected the widget to be selected by its exact size, got %d: %v", code, obj)
+	}
+}
+
+func TestServeVersions_Gadget(t *testing.T) {
+	ts := newTestServer(t)
+
+	code, obj := doRequest(t, ts, http.MethodPost, v1alpha1GadgetsPath, "application/json",
+		`{"apiVersion":"things.myorg.io/v1alpha1","kind":"Gadget","metadata":{"name":"g"},"spec":{"type":"sensor","version":"1.2.3-rc.1+build.5"}}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected the gadget to be created through v1alpha1, got %d: %v", code, obj)
+	}
+
+	code, obj = doRequest(t, ts, http.MethodGet, v1beta1GadgetsPath+"/g", "", "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected the gadget to be served as v1beta1, got %d: %v", code, obj)
+	}
+	version, _ := obj["spec"].(map[string]interface{})["version"].(map[string]interface{})
+	if version["major"] != float64(1) || version["minor"] != float64(2) || version["patch"] != float64(3) ||
+		version["preRelease"] != "rc.1" || version["build"] != "build.5" {
+		t.Errorf("Expected the version broken down into its parts, got %v", version)
+	}
+
+	// v1beta1 versions are validated as the semantic versions they make up
+	code, obj = apply(t, ts, v1beta1GadgetsPath+"/g", "fieldManager=a&force=true",
+		`{"apiVersion":"things.myorg.io/v1beta1","kind":"Gadget","metadata":{"name":"g"},"spec":{"version":{"major":2,"minor":0,"patch":0,"preRelease":"not valid"}}}`)
+	if code != http.StatusUnprocessableEntity || !strings.Contains(obj["message"].(string), "spec.version") {
+		t.Errorf("Expected 422 for an invalid pre-release, got %d: %v", code, obj)
+	}
+	code, obj = apply(t, ts, v1beta1GadgetsPath+"/g", "fieldManager=a&force=true",
+		`{"apiVersion":"things.myorg.io/v1beta1","kind":"Gadget","metadata":{"name":"g"},"spec":{"version":{"major":2,"minor":0,"patch":1}}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected apply through v1beta1 to succeed, got %d: %v", code, obj)
+	}
+
+	// The parts of the version the apply left out are kept, as for any other field
+	code, obj = doRequest(t, ts, http.MethodGet, v1alpha1GadgetsPath+"/g", "", "")
+	if code != http.StatusOK || obj["spec"].(map[string]interface{})["version"] != "2.0.1-rc.1+build.5" {
+		t.Errorf("Expected version 2.0.1-rc.1+build.5 through v1alpha1, got %d: %v", code, obj)
+	}
+}
+
+func TestNewStorage_StorageVersion(t *testing.T) {
+	fileOptions := store.NewFileOptions()
+	fileOptions.Dir = t.TempDir()
+	ctx := context.Background()
+
+	widgetStorage, gadgetStorage, err := newStorage(nil, fileOptions, v1beta1widgets.SchemeGroupVersion)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if _, err := widgetStorage.Create(ctx, &widgets.Widget{
+		ObjectMeta: metav1.ObjectMeta{Name: "w", Namespace: "default"},
+		Spec:       widgets.WidgetSpec{Name: "w", Size: resource.MustParse("1500m")},
+	}); err != nil {
+		t.Fatalf("Failed to create widget: %v", err)
+	}
+	widgetStorage.Destroy()
+	gadgetStorage.Destroy()
+
+	data, err := os.ReadFile(filepath.Join(fileOptions.Dir, "widgets", "snapshot.json"))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if !strings.Contains(string(data), `"apiVersion":"things.myorg.io/v1beta1"`) {
+		t.Errorf("Expected the widget to be persisted as v1beta1, got %s", data)
+	}
+
+	// Objects persisted in another version are read after the storage version changes
+	widgetStorage, gadgetStorage, err = newStorage(nil, fileOptions, defaultStorageVersion)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer widgetStorage.Destroy()
+	defer gadgetStorage.Destroy()
+	widget, err := widgetStorage.Get(ctx, "default", "w")
+	if err != nil {
+		t.Fatalf("Failed to get widget: %v", err)
+	}
+	if widget.Spec.Size.Cmp(resource.MustParse("1500m")) != 0 {
+		t.Errorf("Expected size 1500m, got %s", widget.Spec.Size.String())
+	}
+	if _, ok := gadgetStorage.(*gadgets.GadgetStorage); !ok {
+		t.Errorf("Expected file backed gadget storage, got %T", gadgetStorage)
+	}
+}
+
+func TestStorageVersion(t *testing.T) {
+	for _, version := range []string{"v1alpha1", "v1beta1"} {
+		if _, err := storageVersion(version); err != nil {
+			t.Errorf("Expected %s to be a valid storage version: %v", version, err)
+		}
+	}
+	if _, err := storageVersion("v1"); err == nil {
+		t.Error("Expected an unserved version to be rejected")
+	}
+}